@@ -0,0 +1,81 @@
+package backend
+
+import (
+	"fmt"
+	"sort"
+	"time"
+	"github.com/kherud/goblog/config"
+	"github.com/kherud/goblog/backend/models"
+)
+
+/**
+A month of the archive together with the number of posts published within it.
+ */
+type ArchiveMonth struct {
+	Year  int
+	Month time.Month
+	Count int
+}
+
+/**
+Returns the path of the archive page of the month, e.g. /archive/2026/10.
+ */
+func (month ArchiveMonth) Path() string {
+	return fmt.Sprintf("/archive/%d/%02d", month.Year, int(month.Month))
+}
+
+/**
+Parses the stored date of a post or comment (see config.DATE_FORMAT) in the local time zone.
+ */
+func ParseEntryDate(date string) (time.Time, error) {
+	return time.ParseInLocation(config.DATE_FORMAT, date, time.Local)
+}
+
+/**
+Groups the passed posts by the month they were published in. The newest month comes first.
+Posts with a date that can't be parsed are ignored.
+ */
+func ArchiveMonths(entries []models.Entry) (months []ArchiveMonth) {
+	counts := map[ArchiveMonth]int{}
+	for _, entry := range entries {
+		date, err := ParseEntryDate(entry.Date)
+		if err != nil {
+			continue
+		}
+		month := ArchiveMonth{Year: date.Year(), Month: date.Month()}
+		if counts[month] == 0 {
+			months = append(months, month)
+		}
+		counts[month]++
+	}
+	for idx := range months {
+		months[idx].Count = counts[ArchiveMonth{Year: months[idx].Year, Month: months[idx].Month}]
+	}
+	sort.Slice(months, func(i, j int) bool {
+		if months[i].Year != months[j].Year {
+			return months[i].Year > months[j].Year
+		}
+		return months[i].Month > months[j].Month
+	})
+	return
+}
+
+/**
+Filters a slice of posts by the year and month (1 - 12) they were published in. If month is 0 the whole year is kept.
+The filtered posts are ordered by their date, newest first. Posts with a date that can't be parsed are dropped.
+ */
+func FilterPostsByDate(entries []models.Entry, year, month int) (result []models.Entry) {
+	dates := map[uint32]time.Time{}
+	for _, entry := range entries {
+		date, err := ParseEntryDate(entry.Date)
+		if err != nil || date.Year() != year || (month != 0 && int(date.Month()) != month) {
+			continue
+		}
+		dates[entry.Id] = date
+		result = append(result, entry)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return dates[result[i].Id].After(dates[result[j].Id])
+	})
+	return
+}
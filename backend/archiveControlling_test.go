@@ -0,0 +1,47 @@
+package backend
+
+import (
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+	"github.com/kherud/goblog/backend/models"
+)
+
+var archiveTestEntries = []models.Entry{
+	{Id: 1, Date: "03.10.2026 - 12:00"},
+	{Id: 2, Date: "15.10.2026 - 08:30"},
+	{Id: 3, Date: "31.12.2025 - 23:59"},
+	{Id: 4, Date: "01.02.2026 - 00:00"},
+	{Id: 5, Date: "invalid"},
+}
+
+func TestParseEntryDate(t *testing.T) {
+	date, err := ParseEntryDate("15.10.2026 - 08:30")
+	assert.NoError(t, err)
+	assert.EqualValues(t, date, time.Date(2026, time.October, 15, 8, 30, 0, 0, time.Local))
+	_, err = ParseEntryDate("2026-10-15")
+	assert.Error(t, err)
+}
+
+func TestArchiveMonths(t *testing.T) {
+	months := ArchiveMonths(archiveTestEntries)
+	assert.EqualValues(t, []ArchiveMonth{
+		{Year: 2026, Month: time.October, Count: 2},
+		{Year: 2026, Month: time.February, Count: 1},
+		{Year: 2025, Month: time.December, Count: 1},
+	}, months)
+	assert.EqualValues(t, months[1].Path(), "/archive/2026/02")
+	assert.Empty(t, ArchiveMonths(nil))
+}
+
+func TestFilterPostsByDate(t *testing.T) {
+	result := FilterPostsByDate(archiveTestEntries, 2026, 0)
+	assert.Len(t, result, 3)
+	assert.EqualValues(t, result[0].Id, 2)
+	assert.EqualValues(t, result[2].Id, 4)
+	result = FilterPostsByDate(archiveTestEntries, 2026, 10)
+	assert.Len(t, result, 2)
+	assert.EqualValues(t, result[1].Id, 1)
+	assert.Len(t, FilterPostsByDate(archiveTestEntries, 2025, 12), 1)
+	assert.Empty(t, FilterPostsByDate(archiveTestEntries, 2026, 11))
+}
@@ -60,9 +60,13 @@ func CheckAuthentication(r *http.Request) (models.User, bool) {
 
 /**
 Splits the value of a session cookie into username and the correspondent id.
+A malformed value without separator results in an empty username and id.
  */
 func processCookie(cookieValue string) (string, string) {
 	credentials := strings.Split(cookieValue, "#")
+	if len(credentials) < 2 {
+		return "", ""
+	}
 	username := credentials[0]
 	sessionId := credentials[1]
 	return username, sessionId
@@ -123,6 +123,7 @@ func TestProcessCookie(t *testing.T){
 		{"Konstantin#12345678", "Konstantin", "12345678"},
 		{"#12345678", "", "12345678"},
 		{"Konstantin#", "Konstantin", ""},
+		{"Konstantin", "", ""},
 	}
 	for _, test := range tests {
 		resultA, resultB := processCookie(test.test)
@@ -0,0 +1,255 @@
+package backend
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"github.com/kherud/goblog/backend/models"
+	"github.com/kherud/goblog/util"
+)
+
+/**
+A category together with its depth within the category tree. Top level categories have a depth of 0.
+ */
+type CategoryOption struct {
+	models.Category
+	Depth int
+}
+
+/**
+Returns a single category by its slug. If it doesn't exist an error is returned.
+ */
+func GetCategory(slug string) (models.Category, error) {
+	for _, category := range GetCategories() {
+		if category.Slug == slug {
+			return category, nil
+		}
+	}
+	return models.Category{}, errors.New("category not found")
+}
+
+/**
+Returns the path from the top level category down to the category of the passed slug, e.g. Engineering > Backend.
+Returns an empty slice if the category doesn't exist. Cyclic parents of corrupted data are cut off.
+ */
+func CategoryPath(slug string) (path []models.Category) {
+	categories := GetCategories()
+	visited := map[string]bool{}
+	for slug != "" && !visited[slug] {
+		visited[slug] = true
+		idx := findCategory(categories, slug)
+		if idx < 0 {
+			break
+		}
+		path = append([]models.Category{categories[idx]}, path...)
+		slug = categories[idx].Parent
+	}
+	return
+}
+
+/**
+Returns the slugs of a category and all of its (indirect) subcategories.
+ */
+func CategoryDescendants(slug string) map[string]bool {
+	return categoryDescendants(GetCategories(), slug)
+}
+
+/**
+Returns all categories in the order of the category tree, subcategories directly below their parent.
+Categories with a parent that doesn't exist are treated as top level categories.
+ */
+func CategoryOptions() []CategoryOption {
+	categories := GetCategories()
+	var options []CategoryOption
+	visited := map[string]bool{}
+	var appendChildren func(parent string, depth int)
+	appendChildren = func(parent string, depth int) {
+		for _, category := range categories {
+			isChild := category.Parent == parent
+			if parent == "" { // orphans are displayed at the top level
+				isChild = category.Parent == "" || findCategory(categories, category.Parent) < 0
+			}
+			if isChild && !visited[category.Slug] {
+				visited[category.Slug] = true
+				options = append(options, CategoryOption{Category: category, Depth: depth})
+				appendChildren(category.Slug, depth+1)
+			}
+		}
+	}
+	appendChildren("", 0)
+	return options
+}
+
+/**
+Returns the direct subcategories of a category.
+ */
+func Subcategories(slug string) (children []models.Category) {
+	for _, category := range GetCategories() {
+		if category.Parent == slug && slug != "" {
+			children = append(children, category)
+		}
+	}
+	return
+}
+
+/**
+Filters a slice of posts by a category (param: slug). Posts are kept if their primary or a secondary category is the
+category itself or one of its subcategories. The filtered slice keeps the original order.
+ */
+func FilterPostsByCategory(entries []models.Entry, slug string) (result []models.Entry) {
+	slugs := CategoryDescendants(slug)
+	for _, entry := range entries {
+		for _, category := range postCategories(entry) {
+			if slugs[category] {
+				result = append(result, entry)
+				break
+			}
+		}
+	}
+	return
+}
+
+/**
+Creates a category by parsing the POST form of an http(s) request (name, parent: slug, description) if the request is authenticated by an admin.
+Returns a string that is determined to be displayed in the frontend. If it is empty everything went well.
+ */
+func CreateCategory(r *http.Request) string {
+	user, loggedIn := CheckAuthentication(r)
+	if err := r.ParseForm(); err != nil || !loggedIn {
+		return "Something went wrong.\n"
+	} else if !user.Admin {
+		return "Only admins can change categories.\n"
+	}
+	name := strings.TrimSpace(r.FormValue("name"))
+	slug := util.Slugify(name)
+	if slug == "" {
+		return "The name must contain at least one letter or digit.\n"
+	}
+	categories := GetCategories()
+	if findCategory(categories, slug) >= 0 {
+		return "A category with this name already exists.\n"
+	}
+	parent := r.FormValue("parent")
+	if parent != "" && findCategory(categories, parent) < 0 {
+		return "The parent category could not be found.\n"
+	}
+	category := models.Category{Name: name, Slug: slug, Parent: parent, Description: strings.TrimSpace(r.FormValue("description"))}
+	saveCategoriesJson(append(categories, category))
+	return ""
+}
+
+/**
+Deletes a category by parsing the POST form of an http(s) request (category: slug) if the request is authenticated by an admin.
+Its subcategories are moved to its parent. Posts with the category as primary category are moved to the parent as well,
+secondary assignments are removed.
+Returns a string that is determined to be displayed in the frontend. If it is empty everything went well.
+ */
+func DeleteCategory(r *http.Request) string {
+	user, loggedIn := CheckAuthentication(r)
+	if err := r.ParseForm(); err != nil || !loggedIn {
+		return "Something went wrong.\n"
+	} else if !user.Admin {
+		return "Only admins can change categories.\n"
+	}
+	categories := GetCategories()
+	idx := findCategory(categories, r.FormValue("category"))
+	if idx < 0 {
+		return "The category could not be found.\n"
+	}
+	deleted := categories[idx]
+	categories = append(categories[:idx], categories[idx+1:]...)
+	for idx := range categories {
+		if categories[idx].Parent == deleted.Slug {
+			categories[idx].Parent = deleted.Parent
+		}
+	}
+	saveCategoriesJson(categories)
+	entries := GetEntries()
+	var changed []uint32
+	for idx, entry := range entries {
+		secondaries := removeSlug(entry.Categories, deleted.Slug)
+		if entry.Category == deleted.Slug || len(secondaries) != len(entry.Categories) {
+			if entry.Category == deleted.Slug {
+				entries[idx].Category = deleted.Parent
+				secondaries = removeSlug(secondaries, deleted.Parent)
+			}
+			entries[idx].Categories = secondaries
+			changed = append(changed, entry.Id)
+		}
+	}
+	if len(changed) > 0 {
+		saveEntriesIndexed(entries, changed...)
+	}
+	return ""
+}
+
+/**
+Extracts the primary (category) and secondary categories (secondary) of a post from the POST form of an http(s) request.
+Categories that don't exist are dropped, as well as secondary categories equal to the primary one.
+ */
+func assembleCategories(r *http.Request) (primary string, secondaries []string) {
+	categories := GetCategories()
+	if slug := r.FormValue("category"); findCategory(categories, slug) >= 0 {
+		primary = slug
+	}
+	for _, slug := range r.Form["secondary"] {
+		if slug != primary && findCategory(categories, slug) >= 0 && !containsSlug(secondaries, slug) {
+			secondaries = append(secondaries, slug)
+		}
+	}
+	return
+}
+
+/**
+Returns the slugs of the primary and all secondary categories of a post.
+ */
+func postCategories(entry models.Entry) []string {
+	if entry.Category == "" {
+		return entry.Categories
+	}
+	return append([]string{entry.Category}, entry.Categories...)
+}
+
+func categoryDescendants(categories []models.Category, slug string) map[string]bool {
+	if slug == "" {
+		return map[string]bool{}
+	}
+	slugs := map[string]bool{slug: true}
+	for added := true; added; { // repeat until no further subcategory is found
+		added = false
+		for _, category := range categories {
+			if slugs[category.Parent] && !slugs[category.Slug] {
+				slugs[category.Slug] = true
+				added = true
+			}
+		}
+	}
+	return slugs
+}
+
+func findCategory(categories []models.Category, slug string) int {
+	for idx, category := range categories {
+		if slug != "" && category.Slug == slug {
+			return idx
+		}
+	}
+	return -1
+}
+
+func containsSlug(slugs []string, slug string) bool {
+	for _, existing := range slugs {
+		if existing == slug {
+			return true
+		}
+	}
+	return false
+}
+
+func removeSlug(slugs []string, slug string) (result []string) {
+	for _, existing := range slugs {
+		if existing != slug {
+			result = append(result, existing)
+		}
+	}
+	return
+}
@@ -0,0 +1,153 @@
+package backend
+
+import (
+	"fmt"
+	"testing"
+	"net/url"
+	"os"
+	"path/filepath"
+	"github.com/stretchr/testify/assert"
+	"github.com/kherud/goblog/config"
+	"github.com/kherud/goblog/backend/models"
+)
+
+var categoryTestCategories = []models.Category{
+	{Name: "Engineering", Slug: "engineering"},
+	{Name: "Life", Slug: "life"},
+	{Name: "Backend", Slug: "backend", Parent: "engineering", Description: "Servers and databases"},
+	{Name: "Go", Slug: "go", Parent: "backend"},
+	{Name: "Orphan", Slug: "orphan", Parent: "missing"},
+}
+
+var categoryTestEntries = []models.Entry{
+	{Id: 1, Title: "Goroutines", Text: "Concurrency", Category: "go"},
+	{Id: 2, Title: "Hiking", Text: "Mountains", Category: "life", Categories: []string{"backend"}},
+	{Id: 3, Title: "Uncategorized", Text: "Nothing"},
+	{Id: 4, Title: "Databases", Text: "Indexes", Category: "backend", Categories: []string{"engineering", "life"}},
+}
+
+func useCategoryTestData() {
+	config.ENTRIES_FILE_PATH = config.TEST_TEMP_PATH
+	saveEntriesJson(categoryTestEntries)
+	saveCategoriesJson(categoryTestCategories)
+}
+
+func resetCategoryTestData() {
+	os.Remove(config.TEST_TEMP_PATH)
+	os.Remove(config.CATEGORIES_TEST_PATH)
+	config.ENTRIES_FILE_PATH = filepath.Join("test_data", "entries.json")
+}
+
+func TestCategoryPath(t *testing.T) {
+	useCategoryTestData()
+	defer resetCategoryTestData()
+	path := CategoryPath("go")
+	assert.Len(t, path, 3)
+	assert.EqualValues(t, path[0].Slug, "engineering")
+	assert.EqualValues(t, path[2].Slug, "go")
+	assert.Len(t, CategoryPath("orphan"), 1)
+	assert.Empty(t, CategoryPath("unknown"))
+	assert.Empty(t, CategoryPath(""))
+	saveCategoriesJson([]models.Category{{Name: "A", Slug: "a", Parent: "b"}, {Name: "B", Slug: "b", Parent: "a"}})
+	assert.Len(t, CategoryPath("a"), 2)
+}
+
+func TestCategoryOptions(t *testing.T) {
+	useCategoryTestData()
+	defer resetCategoryTestData()
+	var slugs []string
+	var depths []int
+	for _, option := range CategoryOptions() {
+		slugs = append(slugs, option.Slug)
+		depths = append(depths, option.Depth)
+	}
+	assert.EqualValues(t, []string{"engineering", "backend", "go", "life", "orphan"}, slugs)
+	assert.EqualValues(t, []int{0, 1, 2, 0, 0}, depths)
+	assert.EqualValues(t, map[string]bool{"backend": true, "go": true}, CategoryDescendants("backend"))
+	assert.Len(t, Subcategories("engineering"), 1)
+	assert.Empty(t, Subcategories(""))
+}
+
+func TestFilterPostsByCategory(t *testing.T) {
+	useCategoryTestData()
+	defer resetCategoryTestData()
+	assert.Len(t, FilterPostsByCategory(categoryTestEntries, "engineering"), 3)
+	assert.Len(t, FilterPostsByCategory(categoryTestEntries, "backend"), 3)
+	assert.Len(t, FilterPostsByCategory(categoryTestEntries, "go"), 1)
+	assert.Len(t, FilterPostsByCategory(categoryTestEntries, "life"), 2)
+	assert.Empty(t, FilterPostsByCategory(categoryTestEntries, "unknown"))
+	assert.Empty(t, FilterPostsByCategory(categoryTestEntries, ""))
+}
+
+func TestSearchCategory(t *testing.T) {
+	useCategoryTestData()
+	defer resetCategoryTestData()
+	assert.Len(t, FilterPosts(categoryTestEntries, "category:backend"), 3)
+	assert.Len(t, FilterPosts(categoryTestEntries, "category:go OR hiking"), 2)
+	assert.Len(t, FilterPosts(categoryTestEntries, "category:engineering -indexes"), 2)
+	assert.Empty(t, FilterPosts(categoryTestEntries, "category:unknown"))
+	RebuildSearchIndex()
+	results := SearchPosts("category:Backend")
+	assert.Len(t, results, 3)
+}
+
+func TestCreateCategory(t *testing.T) {
+	useCategoryTestData()
+	defer resetCategoryTestData()
+	assert.EqualValues(t, CreateCategory(tagRequest(url.Values{"name": {"Frontend"}}, false)), "Something went wrong.\n")
+	assert.EqualValues(t, CreateCategory(tagRequest(url.Values{"name": {" ! "}}, true)),
+		"The name must contain at least one letter or digit.\n")
+	assert.EqualValues(t, CreateCategory(tagRequest(url.Values{"name": {"backend"}}, true)),
+		"A category with this name already exists.\n")
+	assert.EqualValues(t, CreateCategory(tagRequest(url.Values{"name": {"Frontend"}, "parent": {"unknown"}}, true)),
+		"The parent category could not be found.\n")
+	assert.Empty(t, CreateCategory(tagRequest(url.Values{"name": {" Frontend "}, "parent": {"engineering"},
+		"description": {"Browsers"}}, true)))
+	category, err := GetCategory("frontend")
+	assert.NoError(t, err)
+	assert.EqualValues(t, models.Category{Name: "Frontend", Slug: "frontend", Parent: "engineering", Description: "Browsers"}, category)
+}
+
+func TestDeleteCategory(t *testing.T) {
+	useCategoryTestData()
+	defer resetCategoryTestData()
+	assert.EqualValues(t, DeleteCategory(tagRequest(url.Values{"category": {"backend"}}, false)), "Something went wrong.\n")
+	assert.EqualValues(t, DeleteCategory(tagRequest(url.Values{"category": {"unknown"}}, true)), "The category could not be found.\n")
+	assert.Empty(t, DeleteCategory(tagRequest(url.Values{"category": {"backend"}}, true)))
+	_, err := GetCategory("backend")
+	assert.Error(t, err)
+	goCategory, _ := GetCategory("go")
+	assert.EqualValues(t, goCategory.Parent, "engineering")
+	entries := GetEntries()
+	assert.EqualValues(t, entries[0].Category, "go")
+	assert.Empty(t, entries[1].Categories)
+	assert.EqualValues(t, entries[3].Category, "engineering")
+	assert.EqualValues(t, []string{"life"}, entries[3].Categories)
+}
+
+func TestChangeCategoryNoAdmin(t *testing.T) {
+	useCategoryTestData()
+	defer resetCategoryTestData()
+	defer revokeAdmin()()
+	assert.EqualValues(t, CreateCategory(tagRequest(url.Values{"name": {"Frontend"}}, true)), "Only admins can change categories.\n")
+	assert.EqualValues(t, DeleteCategory(tagRequest(url.Values{"category": {"backend"}}, true)), "Only admins can change categories.\n")
+	_, err := GetCategory("frontend")
+	assert.Error(t, err)
+	_, err = GetCategory("backend")
+	assert.NoError(t, err)
+}
+
+func TestAssembleCategories(t *testing.T) {
+	useCategoryTestData()
+	defer resetCategoryTestData()
+	req := tagRequest(url.Values{"text": {"Categorized"}, "category": {"backend"},
+		"secondary": {"backend", "life", "unknown", "life"}}, true)
+	postId := CreatePost(req)
+	post, err := GetPost(fmt.Sprint(postId))
+	assert.NoError(t, err)
+	assert.EqualValues(t, post.Category, "backend")
+	assert.EqualValues(t, []string{"life"}, post.Categories)
+	req = tagRequest(url.Values{"text": {"Unknown"}, "category": {"unknown"}}, true)
+	post, _ = GetPost(fmt.Sprint(CreatePost(req)))
+	assert.Empty(t, post.Category)
+}
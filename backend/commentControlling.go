@@ -0,0 +1,117 @@
+package backend
+
+import (
+	"github.com/kherud/goblog/backend/models"
+)
+
+/**
+A comment together with its replies as they are displayed below a post.
+Top level comments have a depth of 0, each reply level increases it by one.
+ */
+type CommentThread struct {
+	Comment models.Comment
+	Replies []CommentThread
+	Depth   int
+}
+
+/**
+Arranges the flat comment slice of a post into threads of replies.
+Top level comments keep their order (newest first), replies are ordered chronologically below their parent.
+Replies beyond the maximum depth (param: maxDepth) are not nested any further but listed below their ancestor at the maximum depth.
+Comments of the spam queue are left out. Comments referencing a parent that doesn't exist are treated as top level comments.
+ */
+func BuildCommentThreads(comments []models.Comment, maxDepth int) []CommentThread {
+	comments = withoutSpam(comments)
+	children := map[uint32][]models.Comment{}
+	for idx := len(comments) - 1; idx >= 0; idx-- { // comments are stored newest first, replies are displayed oldest first
+		comment := comments[idx]
+		if comment.ParentId != 0 && containsComment(comments, comment.ParentId) {
+			children[comment.ParentId] = append(children[comment.ParentId], comment)
+		}
+	}
+	visited := map[uint32]bool{}
+	var threads []CommentThread
+	for _, comment := range comments {
+		if comment.ParentId == 0 || !containsComment(comments, comment.ParentId) {
+			threads = append(threads, buildThread(comment, children, visited, 0, maxDepth)...)
+		}
+	}
+	return threads
+}
+
+/**
+Recursively assembles the thread of a single comment.
+If the comment is located at the maximum depth its replies are returned as siblings instead of being nested.
+The visited map guards against cyclic references of corrupted data.
+ */
+func buildThread(comment models.Comment, children map[uint32][]models.Comment, visited map[uint32]bool, depth, maxDepth int) []CommentThread {
+	if visited[comment.Id] {
+		return nil
+	}
+	visited[comment.Id] = true
+	thread := CommentThread{Comment: comment, Depth: depth}
+	if depth < maxDepth {
+		for _, reply := range children[comment.Id] {
+			thread.Replies = append(thread.Replies, buildThread(reply, children, visited, depth+1, maxDepth)...)
+		}
+		return []CommentThread{thread}
+	}
+	threads := []CommentThread{thread}
+	for _, reply := range children[comment.Id] {
+		threads = append(threads, buildThread(reply, children, visited, depth, maxDepth)...)
+	}
+	return threads
+}
+
+/**
+Returns a new slice of all verified comments, which are the ones visible to readers.
+Replies to unverified comments are attached to their closest verified ancestor instead, thus they don't vanish along with their parent.
+If none exists they become top level comments.
+ */
+func VerifiedComments(comments []models.Comment) (result []models.Comment) {
+	parents := map[uint32]uint32{}
+	verified := map[uint32]bool{}
+	for _, comment := range comments {
+		parents[comment.Id] = comment.ParentId
+		verified[comment.Id] = comment.Verified
+	}
+	for _, comment := range comments {
+		if !comment.Verified {
+			continue
+		}
+		parent := comment.ParentId
+		for steps := 0; parent != 0 && !verified[parent] && steps < len(comments); steps++ { // bounded by corrupted cyclic data
+			parent = parents[parent]
+		}
+		if !verified[parent] {
+			parent = 0
+		}
+		comment.ParentId = parent
+		result = append(result, comment)
+	}
+	return
+}
+
+/**
+Returns a new slice of all comments that aren't located in the spam queue.
+ */
+func withoutSpam(comments []models.Comment) (result []models.Comment) {
+	for _, comment := range comments {
+		if !comment.Spam {
+			result = append(result, comment)
+		}
+	}
+	return
+}
+
+/**
+Checks whether a comment with the passed id exists within a slice of comments.
+ */
+func containsComment(comments []models.Comment, commentId uint32) bool {
+	for _, comment := range comments {
+		if comment.Id == commentId {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package backend
+
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/kherud/goblog/backend/models"
+)
+
+// Comments are stored newest first
+var testThreadComments = []models.Comment{
+	{Text: "reply 3", Id: 6, ParentId: 5},
+	{Text: "reply 2", Id: 5, ParentId: 4},
+	{Text: "top 2", Id: 7},
+	{Text: "reply 1b", Id: 3, ParentId: 1},
+	{Text: "orphan", Id: 8, ParentId: 99},
+	{Text: "reply 1a", Id: 4, ParentId: 1},
+	{Text: "top 1", Id: 1},
+}
+
+func TestBuildCommentThreads(t *testing.T) {
+	threads := BuildCommentThreads(testThreadComments, 3)
+	assert.True(t, len(threads) == 3)
+	assert.EqualValues(t, threads[0].Comment.Id, 7)
+	assert.EqualValues(t, threads[1].Comment.Id, 8) // unknown parent -> top level
+	assert.EqualValues(t, threads[2].Comment.Id, 1)
+	replies := threads[2].Replies
+	assert.True(t, len(replies) == 2)
+	assert.EqualValues(t, replies[0].Comment.Id, 4) // replies are ordered chronologically
+	assert.EqualValues(t, replies[1].Comment.Id, 3)
+	assert.True(t, replies[0].Depth == 1)
+	assert.EqualValues(t, replies[0].Replies[0].Comment.Id, 5)
+	assert.EqualValues(t, replies[0].Replies[0].Replies[0].Comment.Id, 6)
+	assert.True(t, replies[0].Replies[0].Replies[0].Depth == 3)
+}
+
+func TestBuildCommentThreadsMaxDepth(t *testing.T) {
+	threads := BuildCommentThreads(testThreadComments, 1)
+	assert.True(t, len(threads) == 3)
+	replies := threads[2].Replies
+	// deeper replies are listed below their ancestor at the maximum depth
+	assert.True(t, len(replies) == 4)
+	expectedIds := []uint32{4, 5, 6, 3}
+	for idx, reply := range replies {
+		assert.EqualValues(t, reply.Comment.Id, expectedIds[idx])
+		assert.True(t, reply.Depth == 1)
+		assert.Empty(t, reply.Replies)
+	}
+	flat := BuildCommentThreads(testThreadComments, 0)
+	assert.True(t, len(flat) == len(testThreadComments))
+}
+
+func TestBuildCommentThreadsCyclic(t *testing.T) {
+	comments := []models.Comment{{Text: "a", Id: 1, ParentId: 2}, {Text: "b", Id: 2, ParentId: 1}}
+	threads := BuildCommentThreads(comments, 3)
+	assert.Empty(t, threads)
+	assert.Empty(t, BuildCommentThreads(nil, 3))
+}
+
+func TestVerifiedComments(t *testing.T) {
+	comments := []models.Comment{
+		{Text: "reply 2", Id: 4, ParentId: 3, Verified: true},
+		{Text: "reply 1", Id: 3, ParentId: 2, Verified: true},
+		{Text: "unverified", Id: 2, ParentId: 1},
+		{Text: "unverified top", Id: 5},
+		{Text: "reply to unverified top", Id: 6, ParentId: 5, Verified: true},
+		{Text: "top", Id: 1, Verified: true},
+	}
+	verified := VerifiedComments(comments)
+	assert.True(t, len(verified) == 4)
+	assert.EqualValues(t, verified[0].ParentId, 3)
+	assert.EqualValues(t, verified[1].ParentId, 1) // attached to the closest verified ancestor
+	assert.EqualValues(t, verified[2].ParentId, 0) // no verified ancestor -> top level
+	assert.EqualValues(t, comments[1].ParentId, 2)  // the passed comments remain unchanged
+	threads := BuildCommentThreads(verified, 3)
+	assert.True(t, len(threads) == 2)
+	assert.EqualValues(t, threads[1].Replies[0].Comment.Id, 3)
+	cyclic := []models.Comment{{Id: 1, ParentId: 2, Verified: true}, {Id: 2, ParentId: 3}, {Id: 3, ParentId: 2}}
+	assert.EqualValues(t, VerifiedComments(cyclic)[0].ParentId, 0)
+}
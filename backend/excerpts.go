@@ -0,0 +1,67 @@
+package backend
+
+import (
+	"regexp"
+	"strings"
+	"github.com/kherud/goblog/config"
+	"github.com/kherud/goblog/backend/models"
+)
+
+// marks the end of the excerpt within the text of a post, everything above is displayed within previews
+const MoreMarker = "<!--more-->"
+
+var (
+	markdownImagePattern    = regexp.MustCompile(`!\[[^\]]*\]\([^)]*\)`)
+	markdownLinkPattern     = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	markdownLinePattern     = regexp.MustCompile(`(?m)^[ \t]*(#{1,6}[ \t]+|>[ \t]?|[-*+][ \t]+|\d+\.[ \t]+)`)
+	markdownEmphasisPattern = regexp.MustCompile("(\\*{1,3}|_{2,3}|~~|`+)")
+)
+
+/**
+The excerpt of a post displayed within previews and whether the post continues beyond it (read more).
+ */
+type Summary struct {
+	Text string
+	More bool
+}
+
+/**
+Returns the excerpt of a post: the explicit excerpt if it was set, otherwise the text above the more marker (see MoreMarker).
+Posts without either are shortened to config.EXCERPT_WORDS words. Markdown is reduced to plain text beforehand.
+ */
+func PostSummary(entry models.Entry) Summary {
+	if excerpt := strings.TrimSpace(entry.Excerpt); excerpt != "" {
+		return Summary{Text: excerpt, More: true}
+	}
+	if idx := strings.Index(entry.Text, MoreMarker); idx >= 0 {
+		return Summary{Text: plainText(entry.Text[:idx]), More: strings.TrimSpace(entry.Text[idx+len(MoreMarker):]) != ""}
+	}
+	text := plainText(entry.Text)
+	shortened := excerpt(text, config.EXCERPT_WORDS)
+	return Summary{Text: shortened, More: shortened != text}
+}
+
+/**
+Estimates the minutes it takes to read a post (see config.READING_WORDS_PER_MINUTE). Every post takes at least one minute.
+ */
+func ReadingTime(entry models.Entry) int {
+	words := len(strings.Fields(plainText(entry.Text)))
+	minutes := (words + config.READING_WORDS_PER_MINUTE - 1) / config.READING_WORDS_PER_MINUTE
+	if minutes < 1 {
+		return 1
+	}
+	return minutes
+}
+
+/**
+Reduces markdown to plain text: embedded images and the more marker are dropped, links are replaced by their text
+and the markup of headings, quotes, lists and emphasis is removed. Whitespace is collapsed.
+ */
+func plainText(text string) string {
+	text = strings.Replace(text, MoreMarker, " ", -1)
+	text = markdownImagePattern.ReplaceAllString(text, " ")
+	text = markdownLinkPattern.ReplaceAllString(text, "$1")
+	text = markdownLinePattern.ReplaceAllString(text, "")
+	text = markdownEmphasisPattern.ReplaceAllString(text, "")
+	return strings.Join(strings.Fields(text), " ")
+}
@@ -0,0 +1,43 @@
+package backend
+
+import (
+	"strings"
+	"testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/kherud/goblog/config"
+	"github.com/kherud/goblog/backend/models"
+)
+
+func TestPostSummary(t *testing.T) {
+	summary := PostSummary(models.Entry{Text: "A **short** post."})
+	assert.EqualValues(t, summary.Text, "A short post.")
+	assert.False(t, summary.More)
+	long := strings.Repeat("word ", config.EXCERPT_WORDS+10)
+	summary = PostSummary(models.Entry{Text: long})
+	assert.EqualValues(t, summary.Text, strings.TrimSpace(strings.Repeat("word ", config.EXCERPT_WORDS))+" …")
+	assert.True(t, summary.More)
+	// the more marker separates the excerpt regardless of its length
+	summary = PostSummary(models.Entry{Text: "# Intro\nFirst [part](https://example.com)\n" + MoreMarker + "\nSecond part"})
+	assert.EqualValues(t, summary.Text, "Intro First part")
+	assert.True(t, summary.More)
+	summary = PostSummary(models.Entry{Text: "Only part" + MoreMarker + "  "})
+	assert.False(t, summary.More)
+	// an explicit excerpt takes precedence
+	summary = PostSummary(models.Entry{Text: "First" + MoreMarker + "Second", Excerpt: " Written by hand "})
+	assert.EqualValues(t, summary.Text, "Written by hand")
+	assert.True(t, summary.More)
+}
+
+func TestReadingTime(t *testing.T) {
+	assert.EqualValues(t, ReadingTime(models.Entry{}), 1)
+	assert.EqualValues(t, ReadingTime(models.Entry{Text: "Hello Hola Hallo"}), 1)
+	assert.EqualValues(t, ReadingTime(models.Entry{Text: strings.Repeat("word ", config.READING_WORDS_PER_MINUTE+1)}), 2)
+	assert.EqualValues(t, ReadingTime(models.Entry{Text: strings.Repeat("![image](/media/a.png) ", 500)}), 1)
+}
+
+func TestPlainText(t *testing.T) {
+	assert.EqualValues(t, plainText("## Title\n> Quote with `code`\n- one\n1. two\n![cat](/media/cat.png) __bold__ *it* ~~no~~"),
+		"Title Quote with code one two bold it no")
+	assert.EqualValues(t, plainText("[Notes](/media/notes.pdf)"+MoreMarker+"rest"), "Notes rest")
+	assert.EqualValues(t, plainText("snake_case stays"), "snake_case stays")
+}
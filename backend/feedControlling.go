@@ -0,0 +1,334 @@
+package backend
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+	"github.com/kherud/goblog/config"
+	"github.com/kherud/goblog/backend/models"
+)
+
+/**
+Restricts the posts of a feed to a tag, a category (slugs) or an author (user name). Empty fields aren't applied.
+ */
+type FeedFilter struct {
+	Tag      string
+	Category string
+	Author   string
+}
+
+/**
+A feed independent of its output format (see Feed.RSS, Feed.Atom and Feed.JSON).
+ */
+type Feed struct {
+	Title       string
+	Description string
+	Link        string // page the feed belongs to
+	FeedLink    string // url of the feed itself
+	Updated     time.Time
+	Items       []FeedItem
+}
+
+/**
+A single post within a feed. Either the html content (full text) or a plain text summary (excerpt) is set.
+ */
+type FeedItem struct {
+	Id         string
+	Title      string
+	Link       string
+	Author     string
+	Published  time.Time
+	Updated    time.Time // date of the last edit, the date of publication if it wasn't edited
+	Categories []string
+	Content    string
+	Summary    string
+}
+
+/**
+Assembles the feed of the newest posts (see config.FEED_ITEMS) matching the filter. The feed link is the absolute url of the feed.
+Returns an error if the tag, category or author of the filter doesn't exist.
+ */
+func BuildFeed(filter FeedFilter, feedLink string) (Feed, error) {
+	feed := Feed{Title: config.BLOG_TITLE, Description: config.BLOG_DESCRIPTION, Link: BaseUrl() + "/", FeedLink: feedLink}
+	entries := GetEntries()
+	switch {
+	case filter.Tag != "":
+		tag, err := GetTag(filter.Tag)
+		if err != nil {
+			return Feed{}, err
+		}
+		entries = FilterPostsByTag(entries, tag.Slug)
+		feed.Title += " - " + tag.Name
+		feed.Link += "?tag=" + url.QueryEscape(tag.Slug)
+	case filter.Category != "":
+		category, err := GetCategory(filter.Category)
+		if err != nil {
+			return Feed{}, err
+		}
+		entries = FilterPostsByCategory(entries, category.Slug)
+		feed.Title += " - " + category.Name
+		feed.Link += "?category=" + url.QueryEscape(category.Slug)
+	case filter.Author != "":
+		author, err := findAuthor(filter.Author)
+		if err != nil {
+			return Feed{}, err
+		}
+		entries = filterPostsByAuthor(entries, author.Id)
+		feed.Title += " - " + author.UserName
+	}
+	for _, entry := range entries {
+		published, err := ParseEntryDate(entry.Date)
+		if err != nil {
+			continue
+		}
+		item := FeedItem{
+			Id:         fmt.Sprintf("%v/?id=%v", BaseUrl(), entry.Id),
+			Title:      entry.Title,
+			Link:       fmt.Sprintf("%v/?id=%v", BaseUrl(), entry.Id),
+			Author:     entry.Author,
+			Published:  published,
+			Updated:    published,
+			Categories: entry.Keywords,
+		}
+		if modified, err := ParseEntryDate(entry.Modified); err == nil {
+			item.Updated = latest(published, modified)
+		}
+		if config.FEED_FULL_CONTENT {
+			item.Content = textToHtml(entry.Text)
+		} else {
+			item.Summary = excerpt(PostSummary(entry).Text, config.FEED_EXCERPT_WORDS)
+		}
+		feed.Items = append(feed.Items, item)
+	}
+	sort.SliceStable(feed.Items, func(i, j int) bool {
+		return feed.Items[i].Published.After(feed.Items[j].Published)
+	})
+	if len(feed.Items) > config.FEED_ITEMS {
+		feed.Items = feed.Items[:config.FEED_ITEMS]
+	}
+	for _, item := range feed.Items {
+		feed.Updated = latest(feed.Updated, item.Updated)
+	}
+	return feed, nil
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Dc      string     `xml:"xmlns:dc,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	AtomLink      xmlLink   `xml:"atom:link"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	Guid        string   `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+	Creator     string   `xml:"dc:creator"`
+	Categories  []string `xml:"category"`
+	Description string   `xml:"description"`
+}
+
+type xmlLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+/**
+Renders the feed as RSS 2.0 document.
+ */
+func (feed Feed) RSS() ([]byte, error) {
+	channel := rssChannel{
+		Title:       feed.Title,
+		Link:        feed.Link,
+		Description: feed.Description,
+		AtomLink:    xmlLink{Href: feed.FeedLink, Rel: "self", Type: "application/rss+xml"},
+	}
+	if !feed.Updated.IsZero() {
+		channel.LastBuildDate = feed.Updated.Format(time.RFC1123Z)
+	}
+	for _, item := range feed.Items {
+		description := item.Content
+		if description == "" {
+			description = item.Summary
+		}
+		channel.Items = append(channel.Items, rssItem{Title: item.Title, Link: item.Link, Guid: item.Id,
+			PubDate: item.Published.Format(time.RFC1123Z), Creator: item.Author, Categories: item.Categories,
+			Description: description})
+	}
+	return marshalXml(rssFeed{Version: "2.0", Atom: "http://www.w3.org/2005/Atom", Dc: "http://purl.org/dc/elements/1.1/",
+		Channel: channel})
+}
+
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	Id       string      `xml:"id"`
+	Updated  string      `xml:"updated"`
+	Links    []xmlLink   `xml:"link"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	Id         string         `xml:"id"`
+	Link       xmlLink        `xml:"link"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Author     string         `xml:"author>name"`
+	Categories []atomCategory `xml:"category"`
+	Content    *atomText      `xml:"content,omitempty"`
+	Summary    *atomText      `xml:"summary,omitempty"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+/**
+Renders the feed as Atom 1.0 document.
+ */
+func (feed Feed) Atom() ([]byte, error) {
+	result := atomFeed{
+		Title:    feed.Title,
+		Subtitle: feed.Description,
+		Id:       feed.FeedLink,
+		Updated:  feedUpdated(feed).Format(time.RFC3339),
+		Links:    []xmlLink{{Href: feed.FeedLink, Rel: "self", Type: "application/atom+xml"}, {Href: feed.Link, Rel: "alternate"}},
+	}
+	for _, item := range feed.Items {
+		entry := atomEntry{Title: item.Title, Id: item.Id, Link: xmlLink{Href: item.Link, Rel: "alternate"},
+			Published: item.Published.Format(time.RFC3339), Updated: item.Updated.Format(time.RFC3339), Author: item.Author}
+		for _, category := range item.Categories {
+			entry.Categories = append(entry.Categories, atomCategory{Term: category})
+		}
+		if item.Content != "" {
+			entry.Content = &atomText{Type: "html", Body: item.Content}
+		} else {
+			entry.Summary = &atomText{Type: "text", Body: item.Summary}
+		}
+		result.Entries = append(result.Entries, entry)
+	}
+	return marshalXml(result)
+}
+
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageUrl string         `json:"home_page_url"`
+	FeedUrl     string         `json:"feed_url"`
+	Description string         `json:"description,omitempty"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	Id            string           `json:"id"`
+	Url           string           `json:"url"`
+	Title         string           `json:"title"`
+	ContentHtml   string           `json:"content_html,omitempty"`
+	ContentText   string           `json:"content_text,omitempty"`
+	DatePublished string           `json:"date_published"`
+	DateModified  string           `json:"date_modified"`
+	Authors       []jsonFeedAuthor `json:"authors,omitempty"`
+	Tags          []string         `json:"tags,omitempty"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+/**
+Renders the feed as JSON Feed 1.1 document.
+ */
+func (feed Feed) JSON() ([]byte, error) {
+	result := jsonFeed{Version: "https://jsonfeed.org/version/1.1", Title: feed.Title, HomePageUrl: feed.Link,
+		FeedUrl: feed.FeedLink, Description: feed.Description, Items: []jsonFeedItem{}}
+	for _, item := range feed.Items {
+		result.Items = append(result.Items, jsonFeedItem{Id: item.Id, Url: item.Link, Title: item.Title,
+			ContentHtml: item.Content, ContentText: item.Summary, DatePublished: item.Published.Format(time.RFC3339),
+			DateModified: item.Updated.Format(time.RFC3339), Authors: []jsonFeedAuthor{{Name: item.Author}}, Tags: item.Categories})
+	}
+	return json.MarshalIndent(result, "", "  ")
+}
+
+func marshalXml(document interface{}) ([]byte, error) {
+	raw, err := xml.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), raw...), nil
+}
+
+/**
+Returns the date of the newest post or edit of a feed. Empty feeds use the unix epoch, since Atom requires a date.
+ */
+func feedUpdated(feed Feed) time.Time {
+	if feed.Updated.IsZero() {
+		return time.Unix(0, 0).UTC()
+	}
+	return feed.Updated
+}
+
+/**
+Converts the plain text of a post to html by escaping it and preserving its line breaks. The more marker is dropped.
+ */
+func textToHtml(text string) string {
+	text = strings.Replace(text, MoreMarker, "", -1)
+	text = strings.Replace(html.EscapeString(text), "\r\n", "\n", -1)
+	return strings.Replace(text, "\n", "<br>\n", -1)
+}
+
+/**
+Returns the first words of a text. An ellipsis is appended if the text was shortened.
+ */
+func excerpt(text string, words int) string {
+	fields := strings.Fields(text)
+	if len(fields) <= words {
+		return strings.Join(fields, " ")
+	}
+	return strings.Join(fields[:words], " ") + " …"
+}
+
+/**
+Returns the user with the passed user name, ignoring case.
+ */
+func findAuthor(name string) (models.User, error) {
+	for _, user := range GetUsers() {
+		if strings.EqualFold(user.UserName, name) {
+			return user, nil
+		}
+	}
+	return models.User{}, errors.New("author not found")
+}
+
+func filterPostsByAuthor(entries []models.Entry, authorId uint32) (result []models.Entry) {
+	for _, entry := range entries {
+		if entry.AuthorId == authorId {
+			result = append(result, entry)
+		}
+	}
+	return
+}
@@ -0,0 +1,116 @@
+package backend
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+	"github.com/kherud/goblog/config"
+	"github.com/kherud/goblog/backend/models"
+)
+
+func TestBuildFeed(t *testing.T) {
+	feed, err := BuildFeed(FeedFilter{}, BaseUrl()+"/feed.xml")
+	assert.NoError(t, err)
+	assert.EqualValues(t, feed.Title, config.BLOG_TITLE)
+	assert.Len(t, feed.Items, 7)
+	assert.EqualValues(t, feed.Items[0].Title, "Post #41")
+	assert.EqualValues(t, feed.Items[0].Published, feed.Updated)
+	assert.EqualValues(t, feed.Items[0].Link, BaseUrl()+"/?id=708643541")
+	for idx := 1; idx < len(feed.Items); idx++ {
+		assert.False(t, feed.Items[idx].Published.After(feed.Items[idx-1].Published))
+	}
+	hello := feed.Items[3]
+	assert.EqualValues(t, hello.Title, "Hi friend")
+	assert.EqualValues(t, hello.Content, "Hello<br>\nHola<br>\nHallo")
+	assert.Empty(t, hello.Summary)
+}
+
+// edited posts keep their date of publication, but the feed has to announce the edit
+func TestBuildFeedEdited(t *testing.T) {
+	useTagTestEntries()
+	defer resetTagTestEntries()
+	saveEntriesJson([]models.Entry{{Id: 1, Title: "Edited", Text: "Old", Author: "Konstantin", AuthorId: 689017489, Date: "03.01.2018 - 17:19"}})
+	assert.True(t, UpdatePost(tagRequest(url.Values{"text": {"New"}, "title": {"Edited"}}, true), "1"))
+	published, _ := ParseEntryDate("03.01.2018 - 17:19")
+	feed, err := BuildFeed(FeedFilter{}, BaseUrl()+"/atom.xml")
+	assert.NoError(t, err)
+	assert.EqualValues(t, feed.Items[0].Published, published)
+	assert.True(t, feed.Items[0].Updated.After(published))
+	assert.EqualValues(t, feed.Updated, feed.Items[0].Updated)
+	atom, _ := feed.Atom()
+	assert.Contains(t, string(atom), "<published>"+published.Format(time.RFC3339)+"</published>")
+	assert.Contains(t, string(atom), "<updated>"+feed.Updated.Format(time.RFC3339)+"</updated>")
+	jsonFeed, _ := feed.JSON()
+	assert.Contains(t, string(jsonFeed), `"date_modified": "`+feed.Updated.Format(time.RFC3339)+`"`)
+}
+
+func TestBuildFeedFiltered(t *testing.T) {
+	feed, err := BuildFeed(FeedFilter{Tag: "asd"}, "")
+	assert.NoError(t, err)
+	assert.Len(t, feed.Items, 2)
+	assert.EqualValues(t, feed.Title, config.BLOG_TITLE+" - asd")
+	assert.EqualValues(t, feed.Link, BaseUrl()+"/?tag=asd")
+	feed, err = BuildFeed(FeedFilter{Author: "konstant"}, "")
+	assert.NoError(t, err)
+	assert.Len(t, feed.Items, 1)
+	assert.EqualValues(t, feed.Items[0].Author, "Konstant")
+	_, err = BuildFeed(FeedFilter{Tag: "unknown"}, "")
+	assert.Error(t, err)
+	useTagTestEntries()
+	defer resetTagTestEntries()
+	saveEntriesJson([]models.Entry{{Id: 1, Title: "Sharp", Keywords: []string{"C#"}}})
+	feed, err = BuildFeed(FeedFilter{Tag: "c#"}, "")
+	assert.NoError(t, err)
+	assert.EqualValues(t, feed.Link, BaseUrl()+"/?tag=c%23")
+	_, err = BuildFeed(FeedFilter{Category: "unknown"}, "")
+	assert.Error(t, err)
+	_, err = BuildFeed(FeedFilter{Author: "unknown"}, "")
+	assert.Error(t, err)
+}
+
+func TestBuildFeedExcerpt(t *testing.T) {
+	config.FEED_FULL_CONTENT, config.FEED_EXCERPT_WORDS, config.FEED_ITEMS = false, 2, 4
+	defer func() {
+		config.FEED_FULL_CONTENT, config.FEED_EXCERPT_WORDS, config.FEED_ITEMS = true, 50, 20
+	}()
+	feed, err := BuildFeed(FeedFilter{}, "")
+	assert.NoError(t, err)
+	assert.Len(t, feed.Items, 4)
+	assert.Empty(t, feed.Items[3].Content)
+	assert.EqualValues(t, feed.Items[3].Summary, "Hello Hola …")
+}
+
+func TestFeedFormats(t *testing.T) {
+	feed, _ := BuildFeed(FeedFilter{Tag: "asd"}, BaseUrl()+"/feed.xml?tag=asd")
+	rss, err := feed.RSS()
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(rss), xml.Header))
+	assert.Contains(t, string(rss), `<rss version="2.0"`)
+	assert.Contains(t, string(rss), `<atom:link href="`+BaseUrl()+`/feed.xml?tag=asd" rel="self" type="application/rss+xml"></atom:link>`)
+	assert.Contains(t, string(rss), "<description>Hello&lt;br&gt;")
+	assert.Contains(t, string(rss), "<category>fgh</category>")
+	assert.Contains(t, string(rss), "<dc:creator>Konstantin</dc:creator>")
+	atom, err := feed.Atom()
+	assert.NoError(t, err)
+	assert.Contains(t, string(atom), `<feed xmlns="http://www.w3.org/2005/Atom">`)
+	assert.Contains(t, string(atom), `<content type="html">Hello&lt;br&gt;`)
+	assert.Contains(t, string(atom), "<author>\n      <name>Konstantin</name>")
+	raw, err := feed.JSON()
+	assert.NoError(t, err)
+	var document map[string]interface{}
+	assert.NoError(t, json.Unmarshal(raw, &document))
+	assert.EqualValues(t, document["version"], "https://jsonfeed.org/version/1.1")
+	assert.Len(t, document["items"], 2)
+	empty, _ := Feed{}.JSON()
+	assert.Contains(t, string(empty), `"items": []`)
+}
+
+func TestExcerpt(t *testing.T) {
+	assert.EqualValues(t, excerpt("one  two\nthree", 5), "one two three")
+	assert.EqualValues(t, excerpt("one two three", 2), "one two …")
+	assert.EqualValues(t, textToHtml("<b>\r\nx"), "&lt;b&gt;<br>\nx")
+}
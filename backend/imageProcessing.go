@@ -0,0 +1,359 @@
+package backend
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"github.com/kherud/goblog/config"
+)
+
+// guards the generation of image variants, thus concurrent requests don't write the same file twice
+var variantMutex sync.Mutex
+
+// returned for images exceeding config.MAX_IMAGE_WIDTH or config.MAX_IMAGE_HEIGHT
+var errImageTooLarge = errors.New("image exceeds the maximum dimensions")
+
+// returned for gifs exceeding config.MAX_GIF_FRAMES
+var errTooManyFrames = errors.New("gif exceeds the maximum amount of frames")
+
+/**
+Checks whether images of a media type are available in different sizes and can be chosen as cover image.
+Gifs are only stripped of their metadata (see processImage), thus animations are kept.
+ */
+func ProcessableImage(mediaType string) bool {
+	return mediaType == "image/jpeg" || mediaType == "image/png"
+}
+
+/**
+Decodes and re-encodes an uploaded image, which drops all metadata like EXIF (e.g. GPS positions), png text chunks or gif comments.
+The EXIF orientation of jpegs is applied to the pixels beforehand, thus photos keep being displayed upright. All frames of gifs are kept.
+Images that can't be decoded or exceed the maximum dimensions (see checkImageSize) return an error, thus their metadata is never stored.
+The same applies to gifs with more frames than config.MAX_GIF_FRAMES, which are counted before any of them is decoded.
+Content of other media types is returned unchanged.
+ */
+func processImage(content []byte, mediaType string) ([]byte, error) {
+	if !strings.HasPrefix(mediaType, "image/") {
+		return content, nil
+	}
+	if _, err := checkImageSize(bytes.NewReader(content)); err != nil {
+		return nil, err
+	}
+	if mediaType == "image/gif" {
+		if frames, err := countGifFrames(content); err != nil {
+			return nil, err
+		} else if frames > config.MAX_GIF_FRAMES {
+			return nil, errTooManyFrames
+		}
+		animation, err := gif.DecodeAll(bytes.NewReader(content))
+		if err != nil {
+			return nil, err
+		}
+		var buffer bytes.Buffer
+		err = gif.EncodeAll(&buffer, animation)
+		return buffer.Bytes(), err
+	}
+	img, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	if mediaType == "image/jpeg" {
+		img = applyOrientation(img, jpegOrientation(content))
+	}
+	return encodeImage(img, mediaType)
+}
+
+/**
+Returns the path of a variant of an uploaded image (size: name of config.IMAGE_VARIANTS, e.g. thumbnail).
+Variants are generated on the first request and cached on disk below the media directory.
+Images that are already smaller than the variant as well as files that aren't processable images return the path of the original.
+ */
+func MediaVariant(file, size string) (string, error) {
+	media, path, err := GetMediaFile(file)
+	if err != nil || size == "" || !ProcessableImage(media.Type) {
+		return path, err
+	}
+	width, found := config.IMAGE_VARIANTS[size]
+	if !found {
+		return "", errors.New("unknown image size")
+	}
+	variantPath := variantFilePath(file, size)
+	if _, err := os.Stat(variantPath); err == nil {
+		return variantPath, nil
+	}
+	// only the header is decoded to compare the width, thus originals smaller than the variant are cheap to serve
+	original, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	dimensions, err := checkImageSize(original)
+	original.Close()
+	if err != nil {
+		return "", err
+	} else if dimensions.Width <= width {
+		return path, nil
+	}
+	variantMutex.Lock()
+	defer variantMutex.Unlock()
+	if _, err := os.Stat(variantPath); err == nil { // generated by a concurrent request in the meantime
+		return variantPath, nil
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	img, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return "", err
+	}
+	resized, err := encodeImage(resizeImage(img, width), media.Type)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(variantPath), os.ModePerm); err != nil {
+		return "", err
+	}
+	// write to a temporary file first, thus an interrupted write never leaves a broken variant behind
+	if err := ioutil.WriteFile(variantPath+".tmp", resized, 0644); err != nil {
+		return "", err
+	}
+	return variantPath, os.Rename(variantPath+".tmp", variantPath)
+}
+
+/**
+Assembles the srcset attribute of an uploaded image (param: url), listing all variants with their width
+ordered from the smallest to the largest, e.g. "/media/<file>?size=thumbnail 150w, /media/<file>?size=medium 640w".
+ */
+func ImageSrcset(url string) string {
+	var sizes []string
+	for size := range config.IMAGE_VARIANTS {
+		sizes = append(sizes, size)
+	}
+	sort.Slice(sizes, func(i, j int) bool {
+		return config.IMAGE_VARIANTS[sizes[i]] < config.IMAGE_VARIANTS[sizes[j]]
+	})
+	var candidates []string
+	for _, size := range sizes {
+		candidates = append(candidates, fmt.Sprintf("%v?size=%v %vw", url, size, config.IMAGE_VARIANTS[size]))
+	}
+	return strings.Join(candidates, ", ")
+}
+
+/**
+Removes all cached variants of an uploaded image.
+ */
+func removeVariants(file string) {
+	for size := range config.IMAGE_VARIANTS {
+		os.Remove(variantFilePath(file, size))
+	}
+}
+
+/**
+Decodes only the header of an image and returns its dimensions. Images exceeding config.MAX_IMAGE_WIDTH or
+config.MAX_IMAGE_HEIGHT return errImageTooLarge, thus they are never decoded completely.
+ */
+func checkImageSize(reader io.Reader) (image.Config, error) {
+	dimensions, _, err := image.DecodeConfig(reader)
+	if err != nil {
+		return dimensions, err
+	}
+	if dimensions.Width > config.MAX_IMAGE_WIDTH || dimensions.Height > config.MAX_IMAGE_HEIGHT {
+		return dimensions, errImageTooLarge
+	}
+	return dimensions, nil
+}
+
+/**
+Counts the frames of a gif by walking its blocks without decoding them. Returns an error if the gif is malformed.
+ */
+func countGifFrames(content []byte) (int, error) {
+	errMalformed := errors.New("gif: malformed block structure")
+	if len(content) < 13 {
+		return 0, errMalformed
+	}
+	position := 13 + colorTableSize(content[10]) // header and logical screen descriptor
+	frames := 0
+	for position < len(content) {
+		switch content[position] {
+		case 0x21: // extension: introducer, label and data sub-blocks
+			position += 2
+		case 0x2C: // image descriptor: separator, dimensions, flags, local color table, lzw code size and data sub-blocks
+			if position+10 > len(content) {
+				return 0, errMalformed
+			}
+			position += 10 + colorTableSize(content[position+9]) + 1
+			frames++
+		case 0x3B: // trailer
+			return frames, nil
+		default:
+			return 0, errMalformed
+		}
+		for position < len(content) && content[position] != 0 { // skip sub-blocks up to the terminator
+			position += int(content[position]) + 1
+		}
+		position++
+	}
+	return 0, errMalformed
+}
+
+// size of a gif color table in bytes, which is present if the highest bit of the flags is set
+func colorTableSize(flags byte) int {
+	if flags&0x80 == 0 {
+		return 0
+	}
+	return 3 << (flags&0x07 + 1)
+}
+
+func variantFilePath(file, size string) string {
+	extension := filepath.Ext(file)
+	return filepath.Join(config.MEDIA_PATH, "variants", strings.TrimSuffix(file, extension)+"-"+size+extension)
+}
+
+func encodeImage(img image.Image, mediaType string) ([]byte, error) {
+	var buffer bytes.Buffer
+	var err error
+	if mediaType == "image/png" {
+		err = png.Encode(&buffer, img)
+	} else {
+		err = jpeg.Encode(&buffer, img, &jpeg.Options{Quality: config.JPEG_QUALITY})
+	}
+	return buffer.Bytes(), err
+}
+
+/**
+Scales an image down to the passed width keeping its aspect ratio. Every pixel of the result is the average of
+the source pixels it covers (box filter), which is sufficient for downscaling photos.
+ */
+func resizeImage(img image.Image, width int) image.Image {
+	bounds := img.Bounds()
+	height := bounds.Dy() * width / bounds.Dx()
+	if height < 1 {
+		height = 1
+	}
+	result := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		top, bottom := bounds.Min.Y+y*bounds.Dy()/height, bounds.Min.Y+(y+1)*bounds.Dy()/height
+		for x := 0; x < width; x++ {
+			left, right := bounds.Min.X+x*bounds.Dx()/width, bounds.Min.X+(x+1)*bounds.Dx()/width
+			var r, g, b, a, count uint64
+			for sy := top; sy < bottom || sy == top; sy++ {
+				for sx := left; sx < right || sx == left; sx++ {
+					pixel := color.NRGBAModel.Convert(img.At(sx, sy)).(color.NRGBA)
+					r, g, b, a = r+uint64(pixel.R), g+uint64(pixel.G), b+uint64(pixel.B), a+uint64(pixel.A)
+					count++
+				}
+			}
+			result.SetNRGBA(x, y, color.NRGBA{R: uint8(r / count), G: uint8(g / count), B: uint8(b / count), A: uint8(a / count)})
+		}
+	}
+	return result
+}
+
+/**
+Reads the orientation (1 - 8) of the EXIF metadata of a jpeg. Returns 1 (upright) if it isn't set or can't be read.
+ */
+func jpegOrientation(content []byte) int {
+	if len(content) < 4 || content[0] != 0xFF || content[1] != 0xD8 {
+		return 1
+	}
+	for offset := 2; offset+4 <= len(content) && content[offset] == 0xFF; {
+		marker := content[offset+1]
+		length := int(binary.BigEndian.Uint16(content[offset+2 : offset+4]))
+		if marker == 0xDA || length < 2 || offset+2+length > len(content) { // image data starts, no further metadata
+			return 1
+		}
+		segment := content[offset+4 : offset+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return exifOrientation(segment[6:])
+		}
+		offset += 2 + length
+	}
+	return 1
+}
+
+/**
+Reads the orientation tag (0x0112) of the first image file directory of EXIF data (tiff structure).
+ */
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	directory := int(order.Uint32(tiff[4:8]))
+	if directory < 0 || directory+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[directory : directory+2]))
+	for idx := 0; idx < entries; idx++ {
+		entry := directory + 2 + idx*12
+		if entry+12 > len(tiff) {
+			break
+		}
+		if order.Uint16(tiff[entry:entry+2]) == 0x0112 {
+			if orientation := int(order.Uint16(tiff[entry+8 : entry+10])); orientation >= 1 && orientation <= 8 {
+				return orientation
+			}
+			break
+		}
+	}
+	return 1
+}
+
+/**
+Rotates and flips an image according to an EXIF orientation, thus it is displayed upright without the metadata.
+ */
+func applyOrientation(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	resultWidth, resultHeight := width, height
+	if orientation >= 5 { // rotated by 90 degrees
+		resultWidth, resultHeight = height, width
+	}
+	result := image.NewNRGBA(image.Rect(0, 0, resultWidth, resultHeight))
+	for y := 0; y < resultHeight; y++ {
+		for x := 0; x < resultWidth; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // mirrored horizontally
+				sx, sy = width-1-x, y
+			case 3: // rotated by 180 degrees
+				sx, sy = width-1-x, height-1-y
+			case 4: // mirrored vertically
+				sx, sy = x, height-1-y
+			case 5: // mirrored along the main diagonal
+				sx, sy = y, x
+			case 6: // has to be rotated clockwise
+				sx, sy = y, height-1-x
+			case 7: // mirrored along the anti diagonal
+				sx, sy = width-1-y, height-1-x
+			case 8: // has to be rotated counterclockwise
+				sx, sy = width-1-y, x
+			}
+			result.Set(x, y, img.At(bounds.Min.X+sx, bounds.Min.Y+sy))
+		}
+	}
+	return result
+}
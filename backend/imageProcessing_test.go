@@ -0,0 +1,206 @@
+package backend
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/url"
+	"os"
+	"testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/kherud/goblog/config"
+)
+
+var (
+	testRed  = color.NRGBA{R: 255, A: 255}
+	testBlue = color.NRGBA{B: 255, A: 255}
+)
+
+/**
+Creates an image whose left half is red and whose right half is blue.
+ */
+func testImage(width, height int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if x < width/2 {
+				img.SetNRGBA(x, y, testRed)
+			} else {
+				img.SetNRGBA(x, y, testBlue)
+			}
+		}
+	}
+	return img
+}
+
+/**
+Encodes a jpeg containing an EXIF segment with the passed orientation and a fake GPS marker.
+ */
+func testJpegWithExif(img image.Image, orientation uint16) []byte {
+	var buffer bytes.Buffer
+	jpeg.Encode(&buffer, img, &jpeg.Options{Quality: 100})
+	tiff := []byte("II\x2A\x00\x08\x00\x00\x00\x01\x00\x12\x01\x03\x00\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00GPS")
+	binary.LittleEndian.PutUint16(tiff[18:20], orientation)
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	header := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(header[2:], uint16(len(segment)+2))
+	content := append([]byte{}, buffer.Bytes()[:2]...)
+	content = append(append(content, header...), segment...)
+	return append(content, buffer.Bytes()[2:]...)
+}
+
+func assertColor(t *testing.T, actual color.Color, expected color.NRGBA) {
+	pixel := color.NRGBAModel.Convert(actual).(color.NRGBA)
+	assert.InDelta(t, float64(pixel.R), float64(expected.R), 40)
+	assert.InDelta(t, float64(pixel.G), float64(expected.G), 40)
+	assert.InDelta(t, float64(pixel.B), float64(expected.B), 40)
+}
+
+func TestJpegOrientation(t *testing.T) {
+	content := testJpegWithExif(testImage(16, 8), 6)
+	assert.EqualValues(t, jpegOrientation(content), 6)
+	var plain bytes.Buffer
+	jpeg.Encode(&plain, testImage(16, 8), nil)
+	assert.EqualValues(t, jpegOrientation(plain.Bytes()), 1)
+	assert.EqualValues(t, jpegOrientation([]byte("no jpeg")), 1)
+	assert.EqualValues(t, jpegOrientation(content[:30]), 1)
+}
+
+func TestProcessImage(t *testing.T) {
+	content := testJpegWithExif(testImage(32, 16), 6)
+	processed, err := processImage(content, "image/jpeg")
+	assert.NoError(t, err)
+	assert.False(t, bytes.Contains(processed, []byte("Exif")))
+	assert.False(t, bytes.Contains(processed, []byte("GPS")))
+	img, err := jpeg.Decode(bytes.NewReader(processed))
+	assert.NoError(t, err)
+	// rotated clockwise: the red left half is on top now
+	assert.EqualValues(t, img.Bounds().Dx(), 16)
+	assert.EqualValues(t, img.Bounds().Dy(), 32)
+	assertColor(t, img.At(8, 4), testRed)
+	assertColor(t, img.At(8, 28), testBlue)
+	_, err = processImage([]byte("\x89PNG\r\n\x1a\nbroken"), "image/png")
+	assert.Error(t, err)
+	unchanged, err := processImage([]byte("Some notes"), "text/plain")
+	assert.NoError(t, err)
+	assert.EqualValues(t, unchanged, []byte("Some notes"))
+	_, err = processImage([]byte("RIFF\x00\x00\x00\x00WEBPVP"), "image/webp")
+	assert.Error(t, err)
+}
+
+func TestProcessImageGif(t *testing.T) {
+	frame := image.NewPaletted(image.Rect(0, 0, 4, 4), color.Palette{testRed, testBlue})
+	var buffer bytes.Buffer
+	gif.EncodeAll(&buffer, &gif.GIF{Image: []*image.Paletted{frame, frame}, Delay: []int{10, 10}})
+	// comment extension placed in front of the trailer
+	content := append(append([]byte{}, buffer.Bytes()[:buffer.Len()-1]...), []byte("\x21\xFE\x03GPS\x00\x3B")...)
+	processed, err := processImage(content, "image/gif")
+	assert.NoError(t, err)
+	assert.False(t, bytes.Contains(processed, []byte("GPS")))
+	animation, err := gif.DecodeAll(bytes.NewReader(processed))
+	assert.NoError(t, err)
+	assert.Len(t, animation.Image, 2)
+}
+
+func TestProcessImageTooLarge(t *testing.T) {
+	defer func(width int) { config.MAX_IMAGE_WIDTH = width }(config.MAX_IMAGE_WIDTH)
+	config.MAX_IMAGE_WIDTH = 100
+	var buffer bytes.Buffer
+	png.Encode(&buffer, testImage(101, 1))
+	_, err := processImage(buffer.Bytes(), "image/png")
+	assert.EqualValues(t, err, errImageTooLarge)
+	_, uploadErr := UploadMedia(uploadRequest("wide.png", buffer.Bytes(), true))
+	assert.EqualValues(t, uploadErr, "The image must not be larger than 100x8192 pixels.\n")
+	buffer.Reset()
+	png.Encode(&buffer, testImage(100, 1))
+	_, err = processImage(buffer.Bytes(), "image/png")
+	assert.NoError(t, err)
+}
+
+func TestProcessImageTooManyFrames(t *testing.T) {
+	defer func(frames int) { config.MAX_GIF_FRAMES = frames }(config.MAX_GIF_FRAMES)
+	config.MAX_GIF_FRAMES = 2
+	frame := image.NewPaletted(image.Rect(0, 0, 4, 4), color.Palette{testRed, testBlue})
+	var buffer bytes.Buffer
+	gif.EncodeAll(&buffer, &gif.GIF{Image: []*image.Paletted{frame, frame, frame}, Delay: []int{10, 10, 10}})
+	frames, err := countGifFrames(buffer.Bytes())
+	assert.NoError(t, err)
+	assert.EqualValues(t, frames, 3)
+	_, err = processImage(buffer.Bytes(), "image/gif")
+	assert.EqualValues(t, err, errTooManyFrames)
+	_, uploadErr := UploadMedia(uploadRequest("long.gif", buffer.Bytes(), true))
+	assert.EqualValues(t, uploadErr, "The gif must not have more than 2 frames.\n")
+	_, err = countGifFrames(buffer.Bytes()[:buffer.Len()-10]) // truncated
+	assert.Error(t, err)
+	config.MAX_GIF_FRAMES = 3
+	_, err = processImage(buffer.Bytes(), "image/gif")
+	assert.NoError(t, err)
+}
+
+func TestApplyOrientation(t *testing.T) {
+	img := testImage(2, 1)
+	for orientation, expected := range map[int][]color.NRGBA{
+		1: {testRed, testBlue}, 2: {testBlue, testRed}, 3: {testBlue, testRed}, 4: {testRed, testBlue},
+		5: {testRed, testBlue}, 6: {testRed, testBlue}, 7: {testBlue, testRed}, 8: {testBlue, testRed},
+	} {
+		result := applyOrientation(img, orientation)
+		if orientation >= 5 {
+			assert.EqualValues(t, result.Bounds().Dx(), 1)
+			assert.EqualValues(t, result.At(0, 0), expected[0])
+			assert.EqualValues(t, result.At(0, 1), expected[1])
+		} else {
+			assert.EqualValues(t, result.Bounds().Dx(), 2)
+			assert.EqualValues(t, result.At(0, 0), expected[0])
+			assert.EqualValues(t, result.At(1, 0), expected[1])
+		}
+	}
+}
+
+func TestResizeImage(t *testing.T) {
+	resized := resizeImage(testImage(100, 50), 10)
+	assert.EqualValues(t, resized.Bounds(), image.Rect(0, 0, 10, 5))
+	assert.EqualValues(t, resized.At(0, 0), testRed)
+	assert.EqualValues(t, resized.At(9, 4), testBlue)
+	assert.EqualValues(t, resizeImage(testImage(100, 1), 10).Bounds().Dy(), 1)
+}
+
+func TestMediaVariant(t *testing.T) {
+	defer resetMedia()
+	var buffer bytes.Buffer
+	png.Encode(&buffer, testImage(400, 200))
+	media, err := UploadMedia(uploadRequest("wide.png", buffer.Bytes(), true))
+	assert.Empty(t, err)
+	path, variantErr := MediaVariant(media.File, "thumbnail")
+	assert.NoError(t, variantErr)
+	assert.EqualValues(t, path, variantFilePath(media.File, "thumbnail"))
+	file, _ := os.Open(path)
+	thumbnail, decodeErr := png.Decode(file)
+	file.Close()
+	assert.NoError(t, decodeErr)
+	assert.EqualValues(t, thumbnail.Bounds(), image.Rect(0, 0, 150, 75))
+	cached, _ := MediaVariant(media.File, "thumbnail")
+	assert.EqualValues(t, cached, path)
+	// the original is smaller than the large variant
+	original, _ := MediaVariant(media.File, "large")
+	_, originalPath, _ := GetMediaFile(media.File)
+	assert.EqualValues(t, original, originalPath)
+	_, variantErr = MediaVariant(media.File, "huge")
+	assert.Error(t, variantErr)
+	_, variantErr = MediaVariant("unknown.png", "thumbnail")
+	assert.Error(t, variantErr)
+	assert.Empty(t, DeleteMedia(tagRequest(url.Values{"media": {media.File}}, true)))
+	_, statErr := os.Stat(path)
+	assert.True(t, os.IsNotExist(statErr))
+}
+
+func TestImageSrcset(t *testing.T) {
+	assert.EqualValues(t, ImageSrcset("/media/a.jpg"),
+		"/media/a.jpg?size=thumbnail 150w, /media/a.jpg?size=medium 640w, /media/a.jpg?size=large 1280w")
+	assert.True(t, ProcessableImage("image/png"))
+	assert.False(t, ProcessableImage("image/gif"))
+	assert.EqualValues(t, config.IMAGE_VARIANTS["thumbnail"], 150)
+}
@@ -0,0 +1,78 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"github.com/kherud/goblog/config"
+	"github.com/kherud/goblog/util"
+)
+
+type requestIdKey struct{}
+
+/**
+Replaces the default logger (see log/slog) by one writing to the passed output using the configured level and format
+(see config.LOG_LEVEL and config.LOG_FORMAT). Records logged with the context of a request include its id (see WithRequestId).
+ */
+func ConfigureLogging(output io.Writer) error {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(config.LOG_LEVEL)); err != nil {
+		return fmt.Errorf("unknown log level %q", config.LOG_LEVEL)
+	}
+	options := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch strings.ToLower(config.LOG_FORMAT) {
+	case "text":
+		handler = slog.NewTextHandler(output, options)
+	case "json":
+		handler = slog.NewJSONHandler(output, options)
+	default:
+		return fmt.Errorf("unknown log format %q", config.LOG_FORMAT)
+	}
+	slog.SetDefault(slog.New(requestIdHandler{handler}))
+	return nil
+}
+
+/**
+Returns a copy of the request whose context carries the passed id, thus all records logged with it can be correlated.
+If the id is empty a new one is created.
+ */
+func WithRequestId(r *http.Request, id string) *http.Request {
+	if id == "" {
+		id = util.CreateSessionId()[:16]
+	}
+	return r.WithContext(context.WithValue(r.Context(), requestIdKey{}, id))
+}
+
+/**
+Returns the id of the request a context belongs to or an empty string if there is none.
+ */
+func RequestId(ctx context.Context) string {
+	id, _ := ctx.Value(requestIdKey{}).(string)
+	return id
+}
+
+/**
+Adds the id of the request (if any) to every record that is logged with its context.
+ */
+type requestIdHandler struct {
+	slog.Handler
+}
+
+func (handler requestIdHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := RequestId(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	return handler.Handler.Handle(ctx, record)
+}
+
+func (handler requestIdHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return requestIdHandler{handler.Handler.WithAttrs(attrs)}
+}
+
+func (handler requestIdHandler) WithGroup(name string) slog.Handler {
+	return requestIdHandler{handler.Handler.WithGroup(name)}
+}
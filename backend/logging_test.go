@@ -0,0 +1,44 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http/httptest"
+	"testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/kherud/goblog/config"
+)
+
+func TestConfigureLogging(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+	defer func() { config.LOG_LEVEL, config.LOG_FORMAT = "info", "text" }()
+	var output bytes.Buffer
+	config.LOG_LEVEL, config.LOG_FORMAT = "warn", "json"
+	assert.NoError(t, ConfigureLogging(&output))
+	r := WithRequestId(httptest.NewRequest("GET", "/", nil), "abc")
+	slog.InfoContext(r.Context(), "Skipped")
+	slog.WarnContext(r.Context(), "Logged", "post", 1)
+	slog.Warn("Without request")
+	lines := bytes.Split(bytes.TrimSpace(output.Bytes()), []byte("\n"))
+	assert.Len(t, lines, 2)
+	var record map[string]interface{}
+	assert.NoError(t, json.Unmarshal(lines[0], &record))
+	assert.EqualValues(t, record["msg"], "Logged")
+	assert.EqualValues(t, record["level"], "WARN")
+	assert.EqualValues(t, record["request_id"], "abc")
+	assert.EqualValues(t, record["post"], 1)
+	assert.NotContains(t, string(lines[1]), "request_id")
+	config.LOG_LEVEL = "verbose"
+	assert.Error(t, ConfigureLogging(&output))
+	config.LOG_LEVEL, config.LOG_FORMAT = "debug", "xml"
+	assert.Error(t, ConfigureLogging(&output))
+}
+
+func TestRequestId(t *testing.T) {
+	assert.Empty(t, RequestId(context.Background()))
+	r := WithRequestId(httptest.NewRequest("GET", "/", nil), "")
+	assert.Len(t, RequestId(r.Context()), 16)
+	assert.NotEqual(t, RequestId(r.Context()), RequestId(WithRequestId(r, "").Context()))
+}
@@ -0,0 +1,195 @@
+package backend
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"github.com/kherud/goblog/config"
+	"github.com/kherud/goblog/backend/models"
+)
+
+// names of stored media files: sha256 hash of the content and the extension of its media type
+var mediaFilePattern = regexp.MustCompile(`^[0-9a-f]{64}\.[a-z0-9]+$`)
+
+/**
+An uploaded file together with the url it is served at and whether it is an image (embeddable within posts).
+ */
+type MediaInfo struct {
+	models.Media
+	Url   string
+	Image bool
+}
+
+/**
+Returns all uploaded files of the media library, the newest first.
+ */
+func ListMedia() (library []MediaInfo) {
+	for _, media := range GetMedia() {
+		library = append(library, MediaInfo{Media: media, Url: MediaUrl(media), Image: strings.HasPrefix(media.Type, "image/")})
+	}
+	return
+}
+
+/**
+Returns all uploaded images that can be chosen as cover image of a post (processable images, see ProcessableImage).
+ */
+func CoverImages() (images []MediaInfo) {
+	for _, media := range ListMedia() {
+		if ProcessableImage(media.Type) {
+			images = append(images, media)
+		}
+	}
+	return
+}
+
+/**
+Stores an uploaded file by parsing the multipart form of an http(s) request (file) if the request is authenticated.
+Files are content-addressed: they are named by the sha256 hash of their content, thus uploading the same file twice
+only stores it once. The media type is detected by the content and has to be contained in config.MEDIA_TYPES.
+Images are stripped of their metadata before they are stored (see processImage), ones exceeding the maximum dimensions are rejected.
+Returns the stored media and a string that is determined to be displayed in the frontend. If it is empty everything went well.
+ */
+func UploadMedia(r *http.Request) (models.Media, string) {
+	user, loggedIn := CheckAuthentication(r)
+	if !loggedIn {
+		return models.Media{}, "Something went wrong.\n"
+	}
+	if r.Body != nil {
+		// leave some room for the other parts of the multipart form
+		r.Body = http.MaxBytesReader(nil, r.Body, config.MAX_UPLOAD_SIZE+1<<20)
+	}
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		return models.Media{}, "The file is too large or the upload failed.\n"
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		return models.Media{}, "Please choose a file.\n"
+	}
+	defer file.Close()
+	if header.Size > config.MAX_UPLOAD_SIZE {
+		return models.Media{}, "The file is too large.\n"
+	}
+	content, err := ioutil.ReadAll(file)
+	if err != nil || int64(len(content)) > config.MAX_UPLOAD_SIZE {
+		return models.Media{}, "The file is too large or the upload failed.\n"
+	}
+	mediaType := strings.TrimSpace(strings.Split(http.DetectContentType(content), ";")[0])
+	extension, allowed := config.MEDIA_TYPES[mediaType]
+	if !allowed {
+		return models.Media{}, "This type of file is not allowed.\n"
+	}
+	if content, err = processImage(content, mediaType); err == errImageTooLarge {
+		return models.Media{}, fmt.Sprintf("The image must not be larger than %vx%v pixels.\n", config.MAX_IMAGE_WIDTH, config.MAX_IMAGE_HEIGHT)
+	} else if err == errTooManyFrames {
+		return models.Media{}, fmt.Sprintf("The gif must not have more than %v frames.\n", config.MAX_GIF_FRAMES)
+	} else if err != nil {
+		return models.Media{}, "The image could not be processed.\n"
+	}
+	hash := sha256.Sum256(content)
+	media := models.Media{
+		File:       hex.EncodeToString(hash[:]) + extension,
+		Name:       filepath.Base(header.Filename),
+		Type:       mediaType,
+		Size:       int64(len(content)),
+		Date:       time.Now().Local().Format(config.DATE_FORMAT),
+		UploaderId: user.Id,
+	}
+	library := GetMedia()
+	if idx := findMedia(library, media.File); idx >= 0 {
+		return library[idx], "" // already uploaded
+	}
+	if err := os.MkdirAll(config.MEDIA_PATH, os.ModePerm); err != nil {
+		return models.Media{}, "Something went wrong.\n"
+	}
+	if err := ioutil.WriteFile(filepath.Join(config.MEDIA_PATH, media.File), content, 0644); err != nil {
+		return models.Media{}, "Something went wrong.\n"
+	}
+	saveMediaJson(append([]models.Media{media}, library...))
+	return media, ""
+}
+
+/**
+Deletes an uploaded file and its cached variants by parsing the POST form of an http(s) request (media: file name)
+if the request is authenticated by its uploader or an admin.
+Posts using the file as cover image lose their cover.
+Returns a string that is determined to be displayed in the frontend. If it is empty everything went well.
+ */
+func DeleteMedia(r *http.Request) string {
+	user, loggedIn := CheckAuthentication(r)
+	if err := r.ParseForm(); err != nil || !loggedIn {
+		return "Something went wrong.\n"
+	}
+	library := GetMedia()
+	idx := findMedia(library, r.FormValue("media"))
+	if idx < 0 {
+		return "The file could not be found.\n"
+	} else if library[idx].UploaderId != user.Id && !user.Admin {
+		return "Only the uploader or an admin can delete this file.\n"
+	}
+	deleted := library[idx].File
+	os.Remove(filepath.Join(config.MEDIA_PATH, deleted))
+	removeVariants(deleted)
+	saveMediaJson(append(library[:idx], library[idx+1:]...))
+	entries := GetEntries()
+	var changed []uint32
+	for idx, entry := range entries {
+		if entry.Cover == deleted { // posts using the file as cover image fall back to the default header
+			entries[idx].Cover, entries[idx].CoverAlt = "", ""
+			changed = append(changed, entry.Id)
+		}
+	}
+	if len(changed) > 0 {
+		saveEntriesIndexed(entries, changed...)
+	}
+	return ""
+}
+
+/**
+Extracts the cover image (cover: file name of an uploaded image) and its alternative text (cover_alt) of a post
+from the POST form of an http(s) request. Files that don't exist or aren't processable images are dropped.
+ */
+func assembleCover(r *http.Request) (cover, alt string) {
+	media, _, err := GetMediaFile(r.FormValue("cover"))
+	if err != nil || !ProcessableImage(media.Type) {
+		return "", ""
+	}
+	return media.File, strings.TrimSpace(r.FormValue("cover_alt"))
+}
+
+/**
+Returns a single uploaded file by its file name and the path it is stored at. If it doesn't exist an error is returned.
+ */
+func GetMediaFile(file string) (models.Media, string, error) {
+	if !mediaFilePattern.MatchString(file) {
+		return models.Media{}, "", errors.New("invalid media file name")
+	}
+	library := GetMedia()
+	if idx := findMedia(library, file); idx >= 0 {
+		return library[idx], filepath.Join(config.MEDIA_PATH, file), nil
+	}
+	return models.Media{}, "", errors.New("media not found")
+}
+
+/**
+Returns the url an uploaded file is served at, e.g. /media/<hash>.png.
+ */
+func MediaUrl(media models.Media) string {
+	return "/media/" + media.File
+}
+
+func findMedia(library []models.Media, file string) int {
+	for idx, media := range library {
+		if media.File == file {
+			return idx
+		}
+	}
+	return -1
+}
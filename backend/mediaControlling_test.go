@@ -0,0 +1,154 @@
+package backend
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/kherud/goblog/config"
+	"github.com/kherud/goblog/backend/models"
+)
+
+var testPng = func() []byte {
+	var buffer bytes.Buffer
+	png.Encode(&buffer, testImage(4, 4))
+	return buffer.Bytes()
+}()
+
+func uploadRequest(name string, content []byte, loggedIn bool) *http.Request {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", name)
+	part.Write(content)
+	writer.Close()
+	req, _ := http.NewRequest("POST", "https://localhost:8080/?upload", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	if loggedIn {
+		req.AddCookie(&http.Cookie{Name: "Session", Value: "Konstantin#Test"})
+	}
+	return req
+}
+
+func resetMedia() {
+	os.Remove(config.MEDIA_FILE_PATH)
+	os.RemoveAll(config.MEDIA_PATH)
+}
+
+func TestUploadMedia(t *testing.T) {
+	defer resetMedia()
+	_, err := UploadMedia(uploadRequest("image.png", testPng, false))
+	assert.EqualValues(t, err, "Something went wrong.\n")
+	media, err := UploadMedia(uploadRequest("../image.png", testPng, true))
+	assert.Empty(t, err)
+	assert.EqualValues(t, media.Type, "image/png")
+	assert.EqualValues(t, media.Name, "image.png")
+	assert.Len(t, media.File, 68)
+	assert.EqualValues(t, MediaUrl(media), "/media/"+media.File)
+	stored, err2 := ioutil.ReadFile(filepath.Join(config.MEDIA_PATH, media.File))
+	assert.NoError(t, err2)
+	assert.EqualValues(t, media.Size, len(stored))
+	_, err2 = png.Decode(bytes.NewReader(stored))
+	assert.NoError(t, err2)
+	// the same content is only stored once
+	again, err := UploadMedia(uploadRequest("copy.png", testPng, true))
+	assert.Empty(t, err)
+	assert.EqualValues(t, again, media)
+	assert.Len(t, GetMedia(), 1)
+	library := ListMedia()
+	assert.True(t, library[0].Image)
+	assert.EqualValues(t, library[0].Url, MediaUrl(media))
+}
+
+func TestUploadMediaInvalid(t *testing.T) {
+	defer resetMedia()
+	_, err := UploadMedia(uploadRequest("page.html", []byte("<html><script>alert(1)</script></html>"), true))
+	assert.EqualValues(t, err, "This type of file is not allowed.\n")
+	config.MAX_UPLOAD_SIZE = 16
+	defer func() { config.MAX_UPLOAD_SIZE = 10 << 20 }()
+	_, err = UploadMedia(uploadRequest("image.png", testPng, true))
+	assert.EqualValues(t, err, "The file is too large.\n")
+	req, _ := http.NewRequest("POST", "https://localhost:8080/?upload", nil)
+	req.AddCookie(&http.Cookie{Name: "Session", Value: "Konstantin#Test"})
+	_, err = UploadMedia(req)
+	assert.NotEmpty(t, err)
+	assert.Empty(t, GetMedia())
+}
+
+func TestGetMediaFile(t *testing.T) {
+	defer resetMedia()
+	media, _ := UploadMedia(uploadRequest("notes.txt", []byte("Some notes"), true))
+	assert.EqualValues(t, media.Type, "text/plain")
+	found, path, err := GetMediaFile(media.File)
+	assert.NoError(t, err)
+	assert.EqualValues(t, found, media)
+	assert.EqualValues(t, path, filepath.Join(config.MEDIA_PATH, media.File))
+	_, _, err = GetMediaFile("../media.json")
+	assert.Error(t, err)
+	_, _, err = GetMediaFile(media.File[:60] + "0000.txt")
+	assert.Error(t, err)
+}
+
+func TestDeleteMedia(t *testing.T) {
+	defer resetMedia()
+	media, _ := UploadMedia(uploadRequest("image.png", testPng, true))
+	assert.EqualValues(t, DeleteMedia(tagRequest(url.Values{"media": {media.File}}, false)), "Something went wrong.\n")
+	assert.EqualValues(t, DeleteMedia(tagRequest(url.Values{"media": {"unknown"}}, true)), "The file could not be found.\n")
+	assert.Empty(t, DeleteMedia(tagRequest(url.Values{"media": {media.File}}, true)))
+	assert.Empty(t, GetMedia())
+	_, err := os.Stat(filepath.Join(config.MEDIA_PATH, media.File))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestDeleteMediaNoAdmin(t *testing.T) {
+	defer resetMedia()
+	raw, _ := ioutil.ReadFile(config.USERS_FILE_PATH)
+	defer ioutil.WriteFile(config.USERS_FILE_PATH, raw, 0644)
+	users := GetUsers()
+	users[0].Admin = false
+	saveUsersJson(users)
+	media, _ := UploadMedia(uploadRequest("image.png", testPng, true))
+	library := GetMedia()
+	library[0].UploaderId = 976620356 // uploaded by 'Konstant'
+	saveMediaJson(library)
+	assert.EqualValues(t, DeleteMedia(tagRequest(url.Values{"media": {media.File}}, true)), "Only the uploader or an admin can delete this file.\n")
+	assert.Len(t, GetMedia(), 1)
+	library[0].UploaderId = users[0].Id
+	saveMediaJson(library)
+	assert.Empty(t, DeleteMedia(tagRequest(url.Values{"media": {media.File}}, true)))
+	assert.Empty(t, GetMedia())
+}
+
+func TestCoverImage(t *testing.T) {
+	defer resetMedia()
+	config.ENTRIES_FILE_PATH = config.TEST_TEMP_PATH
+	defer func() {
+		os.Remove(config.TEST_TEMP_PATH)
+		config.ENTRIES_FILE_PATH = filepath.Join("test_data", "entries.json")
+	}()
+	saveEntriesJson([]models.Entry{testEntry})
+	image, _ := UploadMedia(uploadRequest("cover.png", testPng, true))
+	notes, _ := UploadMedia(uploadRequest("notes.txt", []byte("Some notes"), true))
+	assert.Len(t, CoverImages(), 1)
+	assert.EqualValues(t, CoverImages()[0].File, image.File)
+	postId := CreatePost(tagRequest(url.Values{"text": {"Covered"}, "cover": {image.File}, "cover_alt": {" A red and blue square "}}, true))
+	post, err := GetPost(fmt.Sprint(postId))
+	assert.NoError(t, err)
+	assert.EqualValues(t, post.Cover, image.File)
+	assert.EqualValues(t, post.CoverAlt, "A red and blue square")
+	// only uploaded images can be chosen as cover
+	post, _ = GetPost(fmt.Sprint(CreatePost(tagRequest(url.Values{"text": {"Notes"}, "cover": {notes.File}, "cover_alt": {"Notes"}}, true))))
+	assert.Empty(t, post.Cover)
+	assert.Empty(t, post.CoverAlt)
+	// deleting the image removes the cover of the post
+	assert.Empty(t, DeleteMedia(tagRequest(url.Values{"media": {image.File}}, true)))
+	post, _ = GetPost(fmt.Sprint(postId))
+	assert.Empty(t, post.Cover)
+	assert.Empty(t, post.CoverAlt)
+}
@@ -0,0 +1,121 @@
+package backend
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+	"github.com/kherud/goblog/config"
+	"github.com/kherud/goblog/backend/models"
+)
+
+// GET parameters identifying the content of a page, others (e.g. search queries or tracking parameters) aren't part of its canonical url
+var canonicalParameters = []string{"id", "tag", "category", "page"}
+
+/**
+Metadata of a page used for the html head: Open Graph and Twitter Card tags, the canonical url and schema.org JSON-LD.
+Dates are formatted according to RFC 3339 and empty if unknown.
+ */
+type PageMeta struct {
+	Title       string
+	Description string
+	Image       string
+	ImageAlt    string
+	Author      string
+	Canonical   string
+	Type        string // Open Graph type: website or article
+	Published   string
+	Modified    string
+	Keywords    []string
+	JSONLD      string
+}
+
+/**
+Returns the metadata of a page that doesn't display a single post (param: url of the request, e.g. /?tag=go).
+The canonical url consists of the path and the parameters identifying the content of the page (see canonicalParameters).
+ */
+func SiteMeta(requestUrl *url.URL) PageMeta {
+	return PageMeta{
+		Title:       config.BLOG_TITLE,
+		Description: config.BLOG_DESCRIPTION,
+		Image:       BaseUrl() + config.META_IMAGE,
+		Canonical:   canonicalUrl(requestUrl),
+		Type:        "website",
+	}
+}
+
+func canonicalUrl(requestUrl *url.URL) string {
+	parameters, query := requestUrl.Query(), url.Values{}
+	for _, name := range canonicalParameters {
+		if value := parameters.Get(name); value != "" {
+			query.Set(name, value)
+		}
+	}
+	canonical := BaseUrl() + requestUrl.EscapedPath()
+	if requestUrl.EscapedPath() == "" {
+		canonical += "/"
+	}
+	if len(query) > 0 {
+		canonical += "?" + query.Encode()
+	}
+	return canonical
+}
+
+/**
+Returns the metadata of a post including a schema.org BlogPosting as JSON-LD. The cover image of the post is shared if set.
+The description is the excerpt of the post (see PostSummary) shortened to config.META_DESCRIPTION_WORDS words.
+ */
+func PostMeta(entry models.Entry) PageMeta {
+	meta := PageMeta{
+		Title:       entry.Title,
+		Description: excerpt(PostSummary(entry).Text, config.META_DESCRIPTION_WORDS),
+		Image:       BaseUrl() + config.META_IMAGE,
+		Author:      entry.Author,
+		Canonical:   fmt.Sprintf("%v/?id=%v", BaseUrl(), entry.Id),
+		Type:        "article",
+		Published:   formatEntryDate(entry.Date),
+		Modified:    formatEntryDate(entry.Modified),
+		Keywords:    entry.Keywords,
+	}
+	if meta.Modified == "" {
+		meta.Modified = meta.Published
+	}
+	if entry.Cover != "" {
+		meta.Image = BaseUrl() + "/media/" + entry.Cover + "?size=large"
+		meta.ImageAlt = entry.CoverAlt
+	}
+	posting := map[string]interface{}{
+		"@context":         "https://schema.org",
+		"@type":            "BlogPosting",
+		"headline":         meta.Title,
+		"description":      meta.Description,
+		"image":            meta.Image,
+		"url":              meta.Canonical,
+		"mainEntityOfPage": map[string]string{"@type": "WebPage", "@id": meta.Canonical},
+		"author":           map[string]string{"@type": "Person", "name": meta.Author},
+		"publisher":        map[string]string{"@type": "Organization", "name": config.BLOG_TITLE},
+	}
+	if meta.Published != "" {
+		posting["datePublished"] = meta.Published
+		posting["dateModified"] = meta.Modified
+	}
+	if len(entry.Keywords) > 0 {
+		posting["keywords"] = strings.Join(entry.Keywords, ", ")
+	}
+	// json.Marshal escapes <, > and &, thus the result can't terminate the surrounding script element
+	raw, _ := json.Marshal(posting)
+	meta.JSONLD = string(raw)
+	return meta
+}
+
+/**
+Converts a stored date (see config.DATE_FORMAT) to RFC 3339. Returns an empty string if the date can't be parsed.
+ */
+func formatEntryDate(date string) string {
+	parsed, err := ParseEntryDate(date)
+	if err != nil {
+		return ""
+	}
+	return parsed.Format(time.RFC3339)
+}
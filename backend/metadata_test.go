@@ -0,0 +1,64 @@
+package backend
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/kherud/goblog/config"
+	"github.com/kherud/goblog/backend/models"
+)
+
+func TestSiteMeta(t *testing.T) {
+	requestUrl, _ := url.Parse("/?tag=go&utm_source=feed")
+	meta := SiteMeta(requestUrl)
+	assert.EqualValues(t, meta.Title, config.BLOG_TITLE)
+	assert.EqualValues(t, meta.Canonical, BaseUrl()+"/?tag=go")
+	for uri, canonical := range map[string]string{
+		"/?search=go&more=5":        "/",
+		"/archive/2018/01?ref=mail": "/archive/2018/01",
+		"/?category=c%23":           "/?category=c%23",
+		"":                          "/",
+	} {
+		requestUrl, _ = url.Parse(uri)
+		assert.EqualValues(t, SiteMeta(requestUrl).Canonical, BaseUrl()+canonical)
+	}
+	assert.EqualValues(t, meta.Type, "website")
+	assert.Empty(t, meta.JSONLD)
+}
+
+func TestPostMeta(t *testing.T) {
+	entry := models.Entry{Id: 42, Title: "Hello </script>", Text: "One two three", Author: "Konstantin",
+		Date: "03.01.2018 - 17:19", Keywords: []string{"go", "web"}}
+	meta := PostMeta(entry)
+	assert.EqualValues(t, meta.Canonical, BaseUrl()+"/?id=42")
+	assert.EqualValues(t, meta.Type, "article")
+	assert.EqualValues(t, meta.Description, "One two three")
+	assert.EqualValues(t, meta.Published, formatEntryDate("03.01.2018 - 17:19"))
+	assert.EqualValues(t, meta.Modified, meta.Published)
+	assert.NotContains(t, meta.JSONLD, "</script>")
+	var posting map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(meta.JSONLD), &posting))
+	assert.EqualValues(t, posting["@type"], "BlogPosting")
+	assert.EqualValues(t, posting["headline"], "Hello </script>")
+	assert.EqualValues(t, posting["keywords"], "go, web")
+	assert.EqualValues(t, posting["author"], map[string]interface{}{"@type": "Person", "name": "Konstantin"})
+	entry.Modified = "05.01.2018 - 10:00"
+	entry.Date = "invalid"
+	meta = PostMeta(entry)
+	assert.Empty(t, meta.Published)
+	assert.EqualValues(t, meta.Modified, formatEntryDate("05.01.2018 - 10:00"))
+	assert.NoError(t, json.Unmarshal([]byte(meta.JSONLD), &posting))
+}
+
+func TestPostMetaCover(t *testing.T) {
+	meta := PostMeta(models.Entry{Id: 42, Title: "Covered", Date: "03.01.2018 - 17:19"})
+	assert.EqualValues(t, meta.Image, BaseUrl()+config.META_IMAGE)
+	assert.Empty(t, meta.ImageAlt)
+	file := strings.Repeat("a", 64) + ".png"
+	meta = PostMeta(models.Entry{Id: 42, Title: "Covered", Date: "03.01.2018 - 17:19", Cover: file, CoverAlt: "A square"})
+	assert.EqualValues(t, meta.Image, BaseUrl()+"/media/"+file+"?size=large")
+	assert.EqualValues(t, meta.ImageAlt, "A square")
+	assert.Contains(t, meta.JSONLD, file)
+}
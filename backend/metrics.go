@@ -0,0 +1,233 @@
+package backend
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Outcomes of comment submissions (see ObserveComment)
+const (
+	CommentPublished   = "published"
+	CommentPending     = "pending"
+	CommentSpam        = "spam"
+	CommentRateLimited = "rate_limited"
+	CommentInvalid     = "invalid"
+)
+
+// http methods recorded by their name, any other method is recorded as "other"
+var requestMethods = map[string]bool{
+	"GET": true, "HEAD": true, "POST": true, "PUT": true, "PATCH": true, "DELETE": true, "CONNECT": true, "OPTIONS": true, "TRACE": true,
+}
+
+// upper bounds of the duration buckets in seconds
+var durationBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+var (
+	requestsTotal   = newMetric("goblog_http_requests_total", "Number of answered http requests.", "counter", "route", "method", "status")
+	requestDuration = newHistogram("goblog_http_request_duration_seconds", "Duration of answering http requests.", durationBuckets, "route")
+	loginsTotal     = newMetric("goblog_logins_total", "Number of login attempts.", "counter", "result")
+	commentsTotal   = newMetric("goblog_comments_total", "Number of comment submissions.", "counter", "outcome")
+	storageDuration = newHistogram("goblog_storage_duration_seconds", "Duration of reading and writing data files.", durationBuckets, "operation", "file")
+	storageBytes    = newMetric("goblog_storage_bytes", "Size of a data file when it was last read or written.", "gauge", "file")
+	metrics         = []interface{ write(io.Writer) }{requestsTotal, requestDuration, loginsTotal, commentsTotal, storageDuration, storageBytes}
+)
+
+/**
+A counter or gauge holding one value per combination of label values.
+ */
+type metric struct {
+	name, help, kind string
+	labels           []string
+	mutex            sync.Mutex
+	values           map[string]float64 // key: label values joined by \xff
+}
+
+/**
+A histogram holding the bucket counts, the sum and the count of observations per combination of label values.
+ */
+type histogram struct {
+	name, help string
+	labels     []string
+	buckets    []float64
+	mutex      sync.Mutex
+	series     map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	counts []uint64 // cumulative counts per bucket
+	sum    float64
+	count  uint64
+}
+
+func newMetric(name, help, kind string, labels ...string) *metric {
+	return &metric{name: name, help: help, kind: kind, labels: labels, values: map[string]float64{}}
+}
+
+func newHistogram(name, help string, buckets []float64, labels ...string) *histogram {
+	return &histogram{name: name, help: help, labels: labels, buckets: buckets, series: map[string]*histogramSeries{}}
+}
+
+func (metric *metric) add(value float64, labelValues ...string) {
+	metric.mutex.Lock()
+	defer metric.mutex.Unlock()
+	metric.values[strings.Join(labelValues, "\xff")] += value
+}
+
+func (metric *metric) set(value float64, labelValues ...string) {
+	metric.mutex.Lock()
+	defer metric.mutex.Unlock()
+	metric.values[strings.Join(labelValues, "\xff")] = value
+}
+
+func (metric *metric) value(labelValues ...string) float64 {
+	metric.mutex.Lock()
+	defer metric.mutex.Unlock()
+	return metric.values[strings.Join(labelValues, "\xff")]
+}
+
+func (histogram *histogram) observe(value float64, labelValues ...string) {
+	histogram.mutex.Lock()
+	defer histogram.mutex.Unlock()
+	key := strings.Join(labelValues, "\xff")
+	series, found := histogram.series[key]
+	if !found {
+		series = &histogramSeries{counts: make([]uint64, len(histogram.buckets))}
+		histogram.series[key] = series
+	}
+	for idx, bound := range histogram.buckets {
+		if value <= bound {
+			series.counts[idx]++
+		}
+	}
+	series.sum += value
+	series.count++
+}
+
+func (metric *metric) write(w io.Writer) {
+	metric.mutex.Lock()
+	defer metric.mutex.Unlock()
+	fmt.Fprintf(w, "# HELP %v %v\n# TYPE %v %v\n", metric.name, metric.help, metric.name, metric.kind)
+	for _, key := range sortedKeys(metric.values) {
+		fmt.Fprintf(w, "%v%v %v\n", metric.name, formatLabels(metric.labels, key, ""), formatValue(metric.values[key]))
+	}
+}
+
+func (histogram *histogram) write(w io.Writer) {
+	histogram.mutex.Lock()
+	defer histogram.mutex.Unlock()
+	fmt.Fprintf(w, "# HELP %v %v\n# TYPE %v histogram\n", histogram.name, histogram.help, histogram.name)
+	keys := make([]string, 0, len(histogram.series))
+	for key := range histogram.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		series := histogram.series[key]
+		for idx, bound := range histogram.buckets {
+			fmt.Fprintf(w, "%v_bucket%v %v\n", histogram.name, formatLabels(histogram.labels, key, formatValue(bound)), series.counts[idx])
+		}
+		fmt.Fprintf(w, "%v_bucket%v %v\n", histogram.name, formatLabels(histogram.labels, key, "+Inf"), series.count)
+		fmt.Fprintf(w, "%v_sum%v %v\n", histogram.name, formatLabels(histogram.labels, key, ""), formatValue(series.sum))
+		fmt.Fprintf(w, "%v_count%v %v\n", histogram.name, formatLabels(histogram.labels, key, ""), series.count)
+	}
+}
+
+/**
+Records an answered http request (param: route, e.g. /?id or /static, method, status code and duration).
+Non-standard methods are grouped, thus arbitrary requests can't create an unlimited number of metrics.
+ */
+func ObserveRequest(route, method string, status int, duration time.Duration) {
+	if !requestMethods[method] {
+		method = "other"
+	}
+	requestsTotal.add(1, route, method, strconv.Itoa(status))
+	requestDuration.observe(duration.Seconds(), route)
+}
+
+/**
+Records a login attempt.
+ */
+func ObserveLogin(success bool) {
+	if success {
+		loginsTotal.add(1, "success")
+	} else {
+		loginsTotal.add(1, "failure")
+	}
+}
+
+/**
+Records the outcome of a comment submission, e.g. CommentSpam.
+ */
+func ObserveComment(outcome string) {
+	commentsTotal.add(1, outcome)
+}
+
+/**
+Records reading or writing a data file (param: operation read or write, path of the file, duration and size in bytes).
+ */
+func ObserveStorage(operation, path string, duration time.Duration, size int) {
+	file := filepath.Base(path)
+	storageDuration.observe(duration.Seconds(), operation, file)
+	storageBytes.set(float64(size), file)
+}
+
+/**
+Writes all metrics in the text based exposition format of Prometheus, including the number of users with a stored session.
+Sessions are only removed on logout, their cookies expire on the client (see SetSession), thus the number includes expired ones.
+ */
+func WriteMetrics(w io.Writer) {
+	for _, metric := range metrics {
+		metric.write(w)
+	}
+	sessions := 0
+	for _, user := range GetUsers() {
+		if user.Session != "" {
+			sessions++
+		}
+	}
+	fmt.Fprintf(w, "# HELP goblog_stored_sessions Number of users with a stored session, including ones whose cookie expired.\n"+
+		"# TYPE goblog_stored_sessions gauge\ngoblog_stored_sessions %v\n", sessions)
+}
+
+func formatLabels(names []string, key, bucket string) string {
+	var pairs []string
+	if len(names) > 0 {
+		for idx, value := range strings.Split(key, "\xff") {
+			pairs = append(pairs, names[idx]+"=\""+escapeLabel(value)+"\"")
+		}
+	}
+	if bucket != "" {
+		pairs = append(pairs, "le=\""+bucket+"\"")
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func escapeLabel(value string) string {
+	return strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n").Replace(value)
+}
+
+func formatValue(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func sortedKeys(values map[string]float64) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,69 @@
+package backend
+
+import (
+	"bytes"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+	"github.com/kherud/goblog/backend/models"
+	"github.com/kherud/goblog/config"
+)
+
+func TestMetricFormat(t *testing.T) {
+	counter := newMetric("test_total", "Test counter.", "counter", "name")
+	counter.add(1, "a\"b")
+	counter.add(2, "a\"b")
+	var output bytes.Buffer
+	counter.write(&output)
+	assert.EqualValues(t, output.String(), "# HELP test_total Test counter.\n# TYPE test_total counter\ntest_total{name=\"a\\\"b\"} 3\n")
+	histogram := newHistogram("test_seconds", "Test histogram.", []float64{0.1, 1})
+	histogram.observe(0.05)
+	histogram.observe(0.5)
+	histogram.observe(5)
+	output.Reset()
+	histogram.write(&output)
+	assert.EqualValues(t, output.String(), "# HELP test_seconds Test histogram.\n# TYPE test_seconds histogram\n"+
+		"test_seconds_bucket{le=\"0.1\"} 1\ntest_seconds_bucket{le=\"1\"} 2\ntest_seconds_bucket{le=\"+Inf\"} 3\n"+
+		"test_seconds_sum 5.55\ntest_seconds_count 3\n")
+}
+
+func TestObserveComment(t *testing.T) {
+	published, pending, invalid := commentsTotal.value(CommentPublished), commentsTotal.value(CommentPending), commentsTotal.value(CommentInvalid)
+	config.ENTRIES_FILE_PATH = config.TEST_TEMP_PATH
+	defer func() { config.ENTRIES_FILE_PATH = config.ENTRIES_TEST_PATH }()
+	defer os.Remove(config.TEST_TEMP_PATH)
+	saveEntriesJson([]models.Entry{testEntry})
+	SaveComment(tagRequest(url.Values{"text": {"Metrics"}}, true), "976620356")
+	SaveComment(tagRequest(url.Values{"text": {"Metrics"}}, false), "976620356")
+	SaveComment(tagRequest(url.Values{"text": {"Metrics"}}, false), "0")
+	SaveComment(tagRequest(url.Values{}, false), "976620356")
+	assert.EqualValues(t, commentsTotal.value(CommentPublished), published+1)
+	assert.EqualValues(t, commentsTotal.value(CommentPending), pending+1)
+	assert.EqualValues(t, commentsTotal.value(CommentInvalid), invalid+2)
+}
+
+func TestObserveRequestMethod(t *testing.T) {
+	before := requestsTotal.value("/", "other", "200")
+	ObserveRequest("/", "FOO", 200, time.Millisecond)
+	ObserveRequest("/", "BAR", 200, time.Millisecond)
+	assert.EqualValues(t, requestsTotal.value("/", "other", "200"), before+2)
+	assert.EqualValues(t, requestsTotal.value("/", "FOO", "200"), 0)
+}
+
+func TestWriteMetrics(t *testing.T) {
+	ObserveRequest("/?id", "GET", 200, 20*time.Millisecond)
+	ObserveLogin(false)
+	GetUsers()
+	var output bytes.Buffer
+	WriteMetrics(&output)
+	metrics := output.String()
+	assert.True(t, strings.Contains(metrics, "goblog_http_requests_total{route=\"/?id\",method=\"GET\",status=\"200\"}"))
+	assert.True(t, strings.Contains(metrics, "goblog_http_request_duration_seconds_bucket{route=\"/?id\",le=\"0.025\"}"))
+	assert.True(t, strings.Contains(metrics, "goblog_logins_total{result=\"failure\"}"))
+	assert.True(t, strings.Contains(metrics, "goblog_storage_duration_seconds_count{operation=\"read\",file=\"users.json\"}"))
+	assert.True(t, strings.Contains(metrics, "goblog_storage_bytes{file=\"users.json\"} "))
+	assert.True(t, strings.Contains(metrics, "goblog_stored_sessions 1\n"))
+}
@@ -0,0 +1,8 @@
+package models
+
+type Category struct {
+	Name        string `json:"name"`
+	Slug        string `json:"slug"`
+	Parent      string `json:"parent"`
+	Description string `json:"description"`
+}
@@ -1,10 +1,14 @@
 package models
 
 type Comment struct {
-	Text     string `json:"text"`
-	Author   string `json:"author"`
-	Date     string `json:"date"`
-	Verified bool   `json:"verified"`
-	Id       uint32 `json:"id"`
+	Text        string  `json:"text"`
+	Author      string  `json:"author"`
+	Date        string  `json:"date"`
+	Verified    bool    `json:"verified"`
+	Id          uint32  `json:"id"`
+	ParentId    uint32  `json:"parent_id"`
+	AuthorReply bool    `json:"author_reply"`
+	Spam        bool    `json:"spam"`
+	SpamScore   float64 `json:"spam_score"`
+	Trained     string  `json:"trained,omitempty"`
 }
-
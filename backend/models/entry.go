@@ -1,12 +1,18 @@
 package models
 
 type Entry struct {
-	Title    string    `json:"title"`
-	Text     string    `json:"text"`
-	Author   string    `json:"author"`
-	AuthorId uint32    `json:"author_id"`
-	Date     string    `json:"date"`
-	Id       uint32    `json:"id"`
-	Comments []Comment `json:"comments"`
-	Keywords []string  `json:"keywords"`
+	Title      string    `json:"title"`
+	Text       string    `json:"text"`
+	Excerpt    string    `json:"excerpt"`
+	Author     string    `json:"author"`
+	AuthorId   uint32    `json:"author_id"`
+	Date       string    `json:"date"`
+	Modified   string    `json:"modified"`
+	Id         uint32    `json:"id"`
+	Comments   []Comment `json:"comments"`
+	Keywords   []string  `json:"keywords"`
+	Category   string    `json:"category"`
+	Categories []string  `json:"categories"`
+	Cover      string    `json:"cover"`
+	CoverAlt   string    `json:"cover_alt"`
 }
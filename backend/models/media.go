@@ -0,0 +1,10 @@
+package models
+
+type Media struct {
+	File       string `json:"file"`
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	Size       int64  `json:"size"`
+	Date       string `json:"date"`
+	UploaderId uint32 `json:"uploader_id"`
+}
@@ -0,0 +1,8 @@
+package models
+
+type Notification struct {
+	Recipient string `json:"recipient"`
+	Subject   string `json:"subject"`
+	Body      string `json:"body"`
+	Date      string `json:"date"`
+}
@@ -0,0 +1,13 @@
+package models
+
+type SearchIndex struct {
+	Fingerprint string            `json:"fingerprint"`
+	Documents   []IndexedDocument `json:"documents"`
+}
+
+type IndexedDocument struct {
+	PostId   uint32         `json:"post_id"`
+	Language string         `json:"language"`
+	Terms    map[string]int `json:"terms"`
+	Length   int            `json:"length"`
+}
@@ -0,0 +1,5 @@
+package models
+
+type Settings struct {
+	Theme string `json:"theme"`
+}
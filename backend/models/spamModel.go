@@ -0,0 +1,8 @@
+package models
+
+type SpamModel struct {
+	SpamComments int            `json:"spam_comments"`
+	HamComments  int            `json:"ham_comments"`
+	SpamTokens   map[string]int `json:"spam_tokens"`
+	HamTokens    map[string]int `json:"ham_tokens"`
+}
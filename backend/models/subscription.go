@@ -0,0 +1,9 @@
+package models
+
+type Subscription struct {
+	Email     string `json:"email"`
+	PostId    uint32 `json:"post_id"`
+	CommentId uint32 `json:"comment_id"`
+	Token     string `json:"token"`
+	Confirmed bool   `json:"confirmed"`
+}
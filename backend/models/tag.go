@@ -0,0 +1,7 @@
+package models
+
+type Tag struct {
+	Name        string `json:"name"`
+	Slug        string `json:"slug"`
+	Description string `json:"description"`
+}
@@ -0,0 +1,256 @@
+package backend
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"sync"
+	"time"
+	"github.com/kherud/goblog/backend/models"
+	"github.com/kherud/goblog/config"
+)
+
+// Notification settings of an author
+const (
+	NotifyInstant = "instant"
+	NotifyDigest  = "digest"
+)
+
+// Serializes modifications of the digest queue
+var digestMutex sync.Mutex
+
+/**
+Notifications are only sent if an smtp server is configured.
+ */
+func NotificationsEnabled() bool {
+	return config.SMTP_HOST != ""
+}
+
+/**
+Returns the url the blog is reachable at without a trailing slash. Used to create absolute links.
+ */
+func BaseUrl() string {
+	if config.BASE_URL != "" {
+		return strings.TrimSuffix(config.BASE_URL, "/")
+	}
+	return "https://localhost:" + config.DEFAULT_PORT
+}
+
+/**
+Confirms the reply subscription belonging to the token of a double-opt-in link.
+Returns a boolean that represents whether a subscription was found.
+ */
+func ConfirmSubscription(token string) bool {
+	subscriptions := GetSubscriptions()
+	for idx, subscription := range subscriptions {
+		if token != "" && subscription.Token == token {
+			subscriptions[idx].Confirmed = true
+			saveSubscriptionsJson(subscriptions)
+			return true
+		}
+	}
+	return false
+}
+
+/**
+Deletes the reply subscription belonging to the token of an unsubscribe link.
+Returns a boolean that represents whether a subscription was found.
+ */
+func CancelSubscription(token string) bool {
+	subscriptions := GetSubscriptions()
+	for idx, subscription := range subscriptions {
+		if token != "" && subscription.Token == token {
+			subscriptions = append(subscriptions[:idx], subscriptions[idx+1:]...)
+			saveSubscriptionsJson(subscriptions)
+			return true
+		}
+	}
+	return false
+}
+
+/**
+Sends all notifications waiting for the digest, one email per recipient.
+Notifications that couldn't be sent remain queued for the next digest.
+ */
+func SendNotificationDigest() {
+	digestMutex.Lock()
+	defer digestMutex.Unlock()
+	queue := GetDigestQueue()
+	if len(queue) == 0 {
+		return
+	}
+	var recipients []string
+	grouped := map[string][]models.Notification{}
+	for _, notification := range queue {
+		if _, found := grouped[notification.Recipient]; !found {
+			recipients = append(recipients, notification.Recipient)
+		}
+		grouped[notification.Recipient] = append(grouped[notification.Recipient], notification)
+	}
+	var remaining []models.Notification
+	for _, recipient := range recipients {
+		var body strings.Builder
+		for _, notification := range grouped[recipient] {
+			fmt.Fprintf(&body, "%v (%v)\n\n%v\n\n", notification.Subject, notification.Date, notification.Body)
+		}
+		subject := fmt.Sprintf("Comment digest: %v new notifications", len(grouped[recipient]))
+		if err := sendMail(recipient, subject, body.String()); err != nil {
+			slog.Error("Notification digest could not be sent", "recipient", recipient, "error", err)
+			remaining = append(remaining, grouped[recipient]...)
+		}
+	}
+	saveDigestQueueJson(remaining)
+}
+
+/**
+Periodically sends the notification digest. Blocks forever, thus it is supposed to run in its own goroutine.
+ */
+func RunNotificationDigest() {
+	ticker := time.NewTicker(time.Duration(config.DIGEST_INTERVAL) * time.Minute)
+	for range ticker.C {
+		if NotificationsEnabled() {
+			SendNotificationDigest()
+		}
+	}
+}
+
+/**
+Handles all notifications of a newly saved comment:
+- the reader may subscribe to replies to the comment
+- the author of the post is notified instantly or within the next digest
+- readers subscribed to the parent comment are notified if the reply doesn't need to be verified
+ */
+func notifyNewComment(r *http.Request, post models.Entry, comment models.Comment) {
+	if !NotificationsEnabled() || comment.Spam {
+		return
+	}
+	subscribeToReplies(r, post, comment)
+	notifyAuthor(r.Context(), post, comment)
+	if comment.Verified {
+		notifyReply(r.Context(), post, comment)
+	}
+}
+
+/**
+Saves an unconfirmed reply subscription if the reader opted in by the comment form and sends the double-opt-in email.
+ */
+func subscribeToReplies(r *http.Request, post models.Entry, comment models.Comment) {
+	if r.FormValue("notify") != "on" {
+		return
+	}
+	address, err := mail.ParseAddress(r.FormValue("email"))
+	if err != nil {
+		return
+	}
+	subscription := models.Subscription{
+		Email:     address.Address,
+		PostId:    post.Id,
+		CommentId: comment.Id,
+		Token:     hex.EncodeToString(createSecret()), // unpredictable, since it allows to confirm and cancel the subscription
+	}
+	saveSubscriptionsJson(append(GetSubscriptions(), subscription))
+	body := fmt.Sprintf("You asked to be notified about replies to your comment on \"%v\".\n\n"+
+		"Please confirm your subscription by opening the following link:\n%v/?confirm=%v\n\n"+
+		"If you didn't ask for this, just ignore this email.\n", post.Title, BaseUrl(), subscription.Token)
+	dispatchMail(r.Context(), subscription.Email, "Please confirm your subscription", body)
+}
+
+/**
+Notifies the author of a post about a new comment according to his notification settings.
+Comments the author wrote himself are skipped.
+ */
+func notifyAuthor(ctx context.Context, post models.Entry, comment models.Comment) {
+	author, err := getUserById(post.AuthorId)
+	if err != nil || author.Email == "" || (comment.AuthorReply && comment.Author == author.UserName) {
+		return
+	}
+	subject := fmt.Sprintf("New comment on \"%v\"", post.Title)
+	body := fmt.Sprintf("%v wrote:\n\n%v\n\n%v/?id=%v\n", comment.Author, comment.Text, BaseUrl(), post.Id)
+	if !comment.Verified {
+		body += "\nThe comment awaits moderation.\n"
+	}
+	switch author.Notifications {
+	case NotifyInstant:
+		dispatchMail(ctx, author.Email, subject, body)
+	case NotifyDigest:
+		queueDigestNotification(models.Notification{
+			Recipient: author.Email,
+			Subject:   subject,
+			Body:      body,
+			Date:      comment.Date,
+		})
+	}
+}
+
+/**
+Notifies all readers that confirmed their subscription to the parent of a reply.
+ */
+func notifyReply(ctx context.Context, post models.Entry, reply models.Comment) {
+	if !NotificationsEnabled() || reply.ParentId == 0 {
+		return
+	}
+	for _, subscription := range GetSubscriptions() {
+		if subscription.Confirmed && subscription.PostId == post.Id && subscription.CommentId == reply.ParentId {
+			body := fmt.Sprintf("%v replied to your comment on \"%v\":\n\n%v\n\n%v/?id=%v\n\n"+
+				"Unsubscribe: %v/?unsubscribe=%v\n", reply.Author, post.Title, reply.Text, BaseUrl(), post.Id, BaseUrl(), subscription.Token)
+			dispatchMail(ctx, subscription.Email, fmt.Sprintf("New reply on \"%v\"", post.Title), body)
+		}
+	}
+}
+
+/**
+Appends a notification to the queue of the next digest.
+ */
+func queueDigestNotification(notification models.Notification) {
+	digestMutex.Lock()
+	defer digestMutex.Unlock()
+	saveDigestQueueJson(append(GetDigestQueue(), notification))
+}
+
+/**
+Sends an email in the background, thus requests aren't delayed by the smtp server.
+Failures are logged along with the request (param: context) that caused the notification.
+ */
+func dispatchMail(ctx context.Context, recipient, subject, body string) {
+	go func() {
+		if err := sendMail(recipient, subject, body); err != nil {
+			slog.ErrorContext(ctx, "Notification could not be sent", "recipient", recipient, "error", err)
+		}
+	}()
+}
+
+/**
+Sends a plain text email using the configured smtp server. Authenticates if a smtp user is configured.
+ */
+func sendMail(recipient, subject, body string) error {
+	var auth smtp.Auth
+	if config.SMTP_USER != "" {
+		auth = smtp.PlainAuth("", config.SMTP_USER, config.SMTP_PASSWORD, config.SMTP_HOST)
+	}
+	address := net.JoinHostPort(config.SMTP_HOST, config.SMTP_PORT)
+	return smtp.SendMail(address, auth, config.SMTP_FROM, []string{recipient}, composeMail(recipient, subject, body))
+}
+
+/**
+Assembles the headers and body of a plain text email. Non ascii subjects are encoded according to RFC 2047.
+ */
+func composeMail(recipient, subject, body string) []byte {
+	var message strings.Builder
+	message.WriteString("From: " + config.SMTP_FROM + "\r\n")
+	message.WriteString("To: " + recipient + "\r\n")
+	message.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", subject) + "\r\n")
+	message.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	message.WriteString("MIME-Version: 1.0\r\n")
+	message.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	message.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	body = strings.Replace(body, "\r\n", "\n", -1)
+	message.WriteString(strings.Replace(body, "\n", "\r\n", -1))
+	return []byte(message.String())
+}
@@ -0,0 +1,206 @@
+package backend
+
+import (
+	"testing"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"fmt"
+	"net"
+	"time"
+	"bufio"
+	"strings"
+	"net/url"
+	"net/http"
+	"path/filepath"
+	"github.com/kherud/goblog/backend/models"
+	"github.com/kherud/goblog/config"
+)
+
+// In-process stand-in for an smtp server that passes every received email to a channel
+type testSmtpServer struct {
+	listener net.Listener
+	messages chan string
+}
+
+func startTestSmtpServer(t *testing.T) *testSmtpServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	server := &testSmtpServer{listener: listener, messages: make(chan string, 10)}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.handle(conn)
+		}
+	}()
+	config.SMTP_HOST, config.SMTP_PORT, _ = net.SplitHostPort(listener.Addr().String())
+	return server
+}
+
+func (s *testSmtpServer) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	fmt.Fprint(conn, "220 localhost ESMTP\r\n")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			fmt.Fprint(conn, "250 localhost\r\n")
+		case command == "DATA":
+			fmt.Fprint(conn, "354 End data with <CR><LF>.<CR><LF>\r\n")
+			var data strings.Builder
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			s.messages <- data.String()
+			fmt.Fprint(conn, "250 OK\r\n")
+		case command == "QUIT":
+			fmt.Fprint(conn, "221 Bye\r\n")
+			return
+		default: // MAIL, RCPT, RSET, NOOP
+			fmt.Fprint(conn, "250 OK\r\n")
+		}
+	}
+}
+
+func (s *testSmtpServer) receive(t *testing.T) string {
+	select {
+	case message := <-s.messages:
+		return message
+	case <-time.After(2 * time.Second):
+		t.Error("no email received")
+		return ""
+	}
+}
+
+func (s *testSmtpServer) close() {
+	s.listener.Close()
+	config.SMTP_HOST = ""
+}
+
+// Replaces the users with a temporary copy in which the author of testEntry has the passed notification setting
+func useNotificationTestUsers(notifications string) {
+	users := GetUsers()
+	config.USERS_FILE_PATH = filepath.Join("test_data", "users_notification.json")
+	for idx := range users {
+		if users[idx].Id == testEntry.AuthorId {
+			users[idx].Email, users[idx].Notifications = "author@example.com", notifications
+		}
+	}
+	saveUsersJson(users)
+}
+
+func resetNotificationTestUsers() {
+	os.Remove(config.USERS_FILE_PATH)
+	config.USERS_FILE_PATH = config.USERS_TEST_PATH
+}
+
+func TestComposeMail(t *testing.T) {
+	message := string(composeMail("reader@example.com", "Neuer Kommentar zu \"Größe\"", "Line 1\nLine 2"))
+	assert.True(t, strings.Contains(message, "To: reader@example.com\r\n"))
+	assert.True(t, strings.Contains(message, "From: "+config.SMTP_FROM+"\r\n"))
+	assert.True(t, strings.Contains(message, "Subject: =?utf-8?q?"))
+	assert.True(t, strings.HasSuffix(message, "\r\n\r\nLine 1\r\nLine 2"))
+	injection := string(composeMail("reader@example.com", "Title\r\nBcc: victim@example.com", ""))
+	assert.False(t, strings.Contains(injection, "\r\nBcc:"))
+}
+
+func TestSendMail(t *testing.T) {
+	server := startTestSmtpServer(t)
+	defer server.close()
+	assert.True(t, NotificationsEnabled())
+	err := sendMail("reader@example.com", "Test subject", "Test body")
+	assert.NoError(t, err)
+	message := server.receive(t)
+	assert.True(t, strings.Contains(message, "Subject: Test subject"))
+	assert.True(t, strings.Contains(message, "Test body"))
+}
+
+func TestSaveCommentNotifications(t *testing.T) {
+	server := startTestSmtpServer(t)
+	defer server.close()
+	useNotificationTestUsers(NotifyInstant)
+	defer resetNotificationTestUsers()
+	config.ENTRIES_FILE_PATH = config.TEST_TEMP_PATH
+	saveEntriesJson([]models.Entry{testEntry})
+	req := &http.Request{
+		Form:   url.Values{"text": {"Reader comment"}, "email": {"reader@example.com"}, "notify": {"on"}},
+		Header: http.Header{},
+	}
+	SaveComment(req, "976620356")
+	messages := server.receive(t) + server.receive(t) // double-opt-in and author notification
+	assert.True(t, strings.Contains(messages, "To: author@example.com"))
+	assert.True(t, strings.Contains(messages, "Reader comment"))
+	assert.True(t, strings.Contains(messages, "awaits moderation"))
+	assert.True(t, strings.Contains(messages, "To: reader@example.com"))
+	subscriptions := GetSubscriptions()
+	assert.True(t, len(subscriptions) == 1)
+	assert.False(t, subscriptions[0].Confirmed)
+	assert.True(t, strings.Contains(messages, "?confirm="+subscriptions[0].Token))
+	assert.Len(t, subscriptions[0].Token, 64)
+	assert.True(t, ConfirmSubscription(subscriptions[0].Token))
+	assert.False(t, ConfirmSubscription(""))
+	// the author replies -> only the subscribed reader is notified
+	post, _ := GetPost("976620356")
+	req = &http.Request{
+		Form:   url.Values{"text": {"Author answer"}, "parent": {fmt.Sprint(post.Comments[0].Id)}},
+		Header: http.Header{},
+	}
+	req.AddCookie(&http.Cookie{Name: "Session", Value: "Konstantin#Test"})
+	SaveComment(req, "976620356")
+	message := server.receive(t)
+	assert.True(t, strings.Contains(message, "To: reader@example.com"))
+	assert.True(t, strings.Contains(message, "Author answer"))
+	assert.True(t, strings.Contains(message, "?unsubscribe="+subscriptions[0].Token))
+	assert.True(t, CancelSubscription(subscriptions[0].Token))
+	assert.False(t, CancelSubscription(subscriptions[0].Token))
+	assert.Empty(t, GetSubscriptions())
+	os.Remove(config.TEST_TEMP_PATH)
+	os.Remove(config.SUBSCRIPTIONS_TEST_PATH)
+	config.ENTRIES_FILE_PATH = filepath.Join("test_data", "entries.json")
+}
+
+func TestNotifyAuthorDigest(t *testing.T) {
+	server := startTestSmtpServer(t)
+	defer server.close()
+	useNotificationTestUsers(NotifyDigest)
+	defer resetNotificationTestUsers()
+	notifyAuthor(context.Background(), testEntry, models.Comment{Text: "First", Author: "Reader", Date: "Test"})
+	notifyAuthor(context.Background(), testEntry, models.Comment{Text: "Second", Author: "Reader", Date: "Test"})
+	queueDigestNotification(models.Notification{Recipient: "other@example.com", Subject: "Third", Body: "Third"})
+	assert.True(t, len(GetDigestQueue()) == 3)
+	SendNotificationDigest()
+	messages := server.receive(t) + server.receive(t) // one email per recipient
+	assert.True(t, strings.Contains(messages, "First"))
+	assert.True(t, strings.Contains(messages, "Second"))
+	assert.True(t, strings.Contains(messages, "Third"))
+	assert.True(t, strings.Contains(messages, "2 new notifications"))
+	assert.Empty(t, GetDigestQueue())
+	// notifications remain queued if the smtp server isn't reachable
+	server.listener.Close()
+	queueDigestNotification(models.Notification{Recipient: "other@example.com", Subject: "Fourth", Body: "Fourth"})
+	SendNotificationDigest()
+	assert.True(t, len(GetDigestQueue()) == 1)
+	os.Remove(config.DIGEST_TEST_PATH)
+}
+
+func TestBaseUrl(t *testing.T) {
+	assert.EqualValues(t, BaseUrl(), "https://localhost:"+config.DEFAULT_PORT)
+	config.BASE_URL = "https://blog.example.com/"
+	assert.EqualValues(t, BaseUrl(), "https://blog.example.com")
+	config.BASE_URL = ""
+}
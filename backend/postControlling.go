@@ -6,18 +6,26 @@ import (
 	"strconv"
 	"net/http"
 	"fmt"
+	"log/slog"
 	"unicode/utf8"
+	"strings"
 	"github.com/kherud/goblog/backend/models"
 	"github.com/kherud/goblog/util"
+	"github.com/kherud/goblog/config"
 )
 
 /**
 Extracts a comment from the POST form of an http(s) request.
 Saves the comment within the post of the transferred post id if all requirements are met.
 Comments are always prepended to the comment slice of the post. Thus they are chronologically displayed.
+If the form contains a parent comment id the comment is saved as a reply, which requires the parent to exist within the same post.
+Comments of logged in users don't need to be verified, the ones of the author of the post are marked as author replies.
+Comments of readers are scored by the spam filter and moved to the spam queue if they exceed the threshold.
+Afterwards the author of the post and subscribed readers are notified. The outcome of every submission is recorded (see ObserveComment).
  */
 func SaveComment(r *http.Request, postId string) {
 	if r.FormValue("text") == "" {
+		ObserveComment(CommentInvalid)
 		return
 	}
 	var author string
@@ -26,21 +34,49 @@ func SaveComment(r *http.Request, postId string) {
 	} else {
 		author = r.FormValue("name")
 	}
+	user, loggedIn := CheckAuthentication(r)
+	if loggedIn {
+		author = user.UserName
+	}
 	uintPostId, _ := strconv.ParseUint(postId, 10, 32) // parse string to uint32
+	parentId, _ := strconv.ParseUint(r.FormValue("parent"), 10, 32)
 	entries := GetEntries()
 	for idx, post := range entries {
 		if post.Id == uint32(uintPostId) {
-			date := time.Now().Local().Format("02.01.2006 - 15:04")
+			if parentId != 0 && !containsComment(post.Comments, uint32(parentId)) {
+				ObserveComment(CommentInvalid)
+				return
+			}
+			date := time.Now().Local().Format(config.DATE_FORMAT)
 			comment := models.Comment{
-				Text:   r.FormValue("text"),
-				Author: author,
-				Date:   date,
-				Id:     util.CreateHashId(date, author, r.FormValue("text")),
+				Text:        r.FormValue("text"),
+				Author:      author,
+				Date:        date,
+				Verified:    loggedIn,
+				Id:          util.CreateHashId(date, author, r.FormValue("text")),
+				ParentId:    uint32(parentId),
+				AuthorReply: loggedIn && user.Id == post.AuthorId,
+			}
+			if !loggedIn {
+				comment.SpamScore = ScoreComment(r, comment.Text)
+				comment.Spam = comment.SpamScore >= config.SPAM_THRESHOLD
 			}
 			entries[idx].Comments = append([]models.Comment{comment}, post.Comments...) // prepend
-			saveEntriesJson(entries)
+			saveEntriesIndexed(entries, post.Id)
+			slog.InfoContext(r.Context(), "Comment saved", "post", post.Id, "comment", comment.Id, "spam", comment.Spam)
+			switch {
+			case comment.Spam:
+				ObserveComment(CommentSpam)
+			case comment.Verified:
+				ObserveComment(CommentPublished)
+			default:
+				ObserveComment(CommentPending)
+			}
+			notifyNewComment(r, entries[idx], comment)
+			return
 		}
 	}
+	ObserveComment(CommentInvalid) // the post doesn't exist
 }
 
 /**
@@ -61,6 +97,7 @@ func GetPost(id string) (post models.Entry, err error) {
 /**
 Changes the status of a comment to verified if the request is authenticated and all requirements are met.
 Does so by parsing the POST form of a request and extracting the comment id and its affiliated post id.
+Verifying removes a comment from the spam queue, trains the spam classifier with it instead of an earlier spam verdict and notifies readers subscribed to its parent.
 Returns a boolean that represents whether the comment was successfully verified.
  */
 func VerifyComment(r *http.Request) bool {
@@ -78,7 +115,12 @@ func VerifyComment(r *http.Request) bool {
 			for commentIdx, comment := range entry.Comments { // search for comment
 				if strconv.Itoa(int(comment.Id)) == commentId {
 					entries[entryIdx].Comments[commentIdx].Verified = true
-					saveEntriesJson(entries)
+					entries[entryIdx].Comments[commentIdx].Spam = false
+					entries[entryIdx].Comments[commentIdx].Trained = learnVerdict(comment, false)
+					saveEntriesIndexed(entries, entry.Id)
+					if !comment.Verified {
+						notifyReply(r.Context(), entry, entries[entryIdx].Comments[commentIdx])
+					}
 					return true
 				}
 			}
@@ -115,7 +157,7 @@ func DeletePost(r *http.Request) bool {
 		for idx, entry := range entries {
 			if strconv.Itoa(int(entry.Id)) == postId && entry.AuthorId == user.Id { // check if it is the author's post
 				entries = append(entries[:idx], entries[idx+1:]...) // create new slice without element
-				saveEntriesJson(entries)
+				saveEntriesIndexed(entries, entry.Id)
 				return true
 			}
 		}
@@ -124,15 +166,14 @@ func DeletePost(r *http.Request) bool {
 }
 
 /**
-Filters a slice of posts by checking if their keywords contain a search phrase (keyword).
-The filtered slice then is returned.
+Filters a slice of posts by checking if their title, text, keywords, approved comments or author match a search query (see ParseSearchQuery).
+The filtered slice then is returned in its original order. Use SearchPosts to search all posts ranked by relevance.
  */
 func FilterPosts(entries []models.Entry, searchterm string) (result []models.Entry) {
+	query := ParseSearchQuery(searchterm)
 	for _, element := range entries {
-		for _, keyword := range element.Keywords {
-			if keyword == searchterm {
-				result = append(result, element)
-			}
+		if query.Matches(element) {
+			result = append(result, element)
 		}
 	}
 	return
@@ -141,7 +182,7 @@ func FilterPosts(entries []models.Entry, searchterm string) (result []models.Ent
 /**
 Applies edits to an existing post affiliated to the passed id if the request is authenticated.
 Does so by parsing the POST form of an http(s) request.
-Also updates the creation date of the post and therefore prepends it to all other existing posts.
+Also prepends the post to all other existing posts. It keeps its date of publication, the time of the edit is kept as modification date.
  */
 func UpdatePost(r *http.Request, postId string) bool {
 	user, loggedIn := CheckAuthentication(r)
@@ -152,9 +193,11 @@ func UpdatePost(r *http.Request, postId string) bool {
 				newPost := assemblePost(r, user)
 				newPost.Comments = entry.Comments // keep the old comments
 				newPost.Id = entry.Id // keep the id
+				newPost.Modified = newPost.Date
+				newPost.Date = entry.Date // keep the date of publication
 				subSlice := append(entries[:idx], entries[idx+1:]...) // delete old post
 				entries = append([]models.Entry{newPost}, subSlice...) // prepend updated post
-				saveEntriesJson(entries)
+				saveEntriesIndexed(entries, newPost.Id)
 				return true
 			}
 		}
@@ -168,7 +211,7 @@ If no text was transferred an empty instance is returned.
  */
 func assemblePost(r *http.Request, user models.User) models.Entry {
 	entries := GetEntries()
-	date := time.Now().Local().Format("02.01.2006 - 15:04")
+	date := time.Now().Local().Format(config.DATE_FORMAT)
 	var title string
 	if title = r.FormValue("title"); title == "" { // if no title was passed automatically create one
 		title = fmt.Sprintf("Post #%v", len(entries)+1)
@@ -177,14 +220,21 @@ func assemblePost(r *http.Request, user models.User) models.Entry {
 		return models.Entry{}
 	}
 	postId := util.CreateHashId(date, user.UserName, r.FormValue("text"))
+	category, categories := assembleCategories(r)
+	cover, coverAlt := assembleCover(r)
 	entry := models.Entry{
-		Text:     r.FormValue("text"),
-		Title:    title,
-		Author:   user.UserName,
-		AuthorId: user.Id,
-		Date:     date,
-		Id:       postId,
-		Keywords: r.Form["tag"],
+		Text:       r.FormValue("text"),
+		Excerpt:    strings.TrimSpace(r.FormValue("excerpt")),
+		Title:      title,
+		Author:     user.UserName,
+		AuthorId:   user.Id,
+		Date:       date,
+		Id:         postId,
+		Keywords:   r.Form["tag"],
+		Category:   category,
+		Categories: categories,
+		Cover:      cover,
+		CoverAlt:   coverAlt,
 	}
 	return entry
 }
@@ -192,6 +242,7 @@ func assemblePost(r *http.Request, user models.User) models.Entry {
 /**
 Loads all entries. If none exist so far a new slice with the passed entry is created and saved.
 Otherwise the entry is prepended to all other existing posts. Thus all posts are always chronologically displayed.
+The search index is updated accordingly.
  */
 func savePost(entry models.Entry) {
 	entries := GetEntries()
@@ -200,5 +251,5 @@ func savePost(entry models.Entry) {
 	} else {
 		entries = append([]models.Entry{entry}, entries...) // prepend
 	}
-	saveEntriesJson(entries)
+	saveEntriesIndexed(entries, entry.Id)
 }
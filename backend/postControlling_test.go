@@ -78,6 +78,58 @@ func TestSaveCommentValid(t *testing.T) {
 	config.ENTRIES_FILE_PATH = filepath.Join("test_data", "entries.json")
 }
 
+func TestSaveCommentReply(t *testing.T) {
+	config.ENTRIES_FILE_PATH = config.TEST_TEMP_PATH
+	saveEntriesJson([]models.Entry{testEntry})
+	tests := []struct {
+		Params url.Values
+		Login  bool
+	}{{Params: url.Values{"text": {"Reply"}, "name": {"TestTestTest"}, "parent": {"489017489"}}, Login: false},
+		{Params: url.Values{"text": {"Author reply"}, "name": {""}, "parent": {"489017489"}}, Login: true},
+		{Params: url.Values{"text": {"Invalid"}, "name": {""}, "parent": {"123"}}, Login: false},
+	}
+	for _, test := range tests {
+		req := &http.Request{
+			Form:   test.Params,
+			Header: http.Header{},
+		}
+		if test.Login {
+			cookie := &http.Cookie{Name: "Session", Value: "Konstantin#Test"}
+			req.AddCookie(cookie)
+		}
+		SaveComment(req, "976620356")
+	}
+	post, err := GetPost("976620356")
+	assert.Nil(t, err)
+	assert.True(t, len(post.Comments) == 3) // reply to an unknown parent is discarded
+	assert.EqualValues(t, post.Comments[1].ParentId, 489017489)
+	assert.False(t, post.Comments[1].AuthorReply)
+	assert.False(t, post.Comments[1].Verified)
+	assert.EqualValues(t, post.Comments[0].ParentId, 489017489)
+	assert.EqualValues(t, post.Comments[0].Author, "Konstantin")
+	assert.True(t, post.Comments[0].AuthorReply)
+	assert.True(t, post.Comments[0].Verified)
+	os.Remove(config.TEST_TEMP_PATH)
+	config.ENTRIES_FILE_PATH = filepath.Join("test_data", "entries.json")
+}
+
+func TestSaveCommentReplyOtherUser(t *testing.T) {
+	config.ENTRIES_FILE_PATH = config.TEST_TEMP_PATH
+	defer func() { config.ENTRIES_FILE_PATH = filepath.Join("test_data", "entries.json") }()
+	defer os.Remove(config.TEST_TEMP_PATH)
+	entry := testEntry
+	entry.AuthorId = 976620356 // written by another user
+	saveEntriesJson([]models.Entry{entry})
+	req := &http.Request{Form: url.Values{"text": {"Reply"}, "parent": {"489017489"}}, Header: http.Header{}}
+	req.AddCookie(&http.Cookie{Name: "Session", Value: "Konstantin#Test"})
+	SaveComment(req, "976620356")
+	post, err := GetPost("976620356")
+	assert.Nil(t, err)
+	assert.EqualValues(t, post.Comments[0].Author, "Konstantin")
+	assert.True(t, post.Comments[0].Verified)
+	assert.False(t, post.Comments[0].AuthorReply)
+}
+
 func TestGetPost(t *testing.T) {
 	post, err := GetPost("708643541")
 	assert.Nil(t, err)
@@ -254,6 +306,10 @@ func TestFilterPosts(t *testing.T){
 	assert.True(t, len(entriesFilter2) == 2)
 	assert.True(t, len(entriesFilter3) == 1)
 	assert.True(t, len(entriesFilter4) == 0)
+	// titles and texts are searched as well, posts with duplicate keywords are only returned once
+	assert.True(t, len(FilterPosts(entries, "hola")) == 1)
+	assert.True(t, len(FilterPosts(entries, "POST")) == 6)
+	assert.True(t, len(FilterPosts(append(entries[1:2], entries[1]), "asd")) == 2)
 }
 
 func TestUpdatePostInvalid(t *testing.T){
@@ -291,7 +347,9 @@ func TestUpdatePostValid(t *testing.T){
 	assert.NotEqual(t, post.Text, testEntry.Text)
 	assert.NotEqual(t, post.Title, testEntry.Title)
 	assert.NotEqual(t, post.Keywords, testEntry.Keywords)
-	assert.NotEqual(t, post.Date, testEntry.Date)
+	assert.Equal(t, post.Date, testEntry.Date)
+	assert.NotEqual(t, post.Modified, testEntry.Date)
+	assert.NotEmpty(t, post.Modified)
 	assert.Equal(t, post.Id, testEntry.Id)
 	os.Remove(config.TEST_TEMP_PATH)
 	config.ENTRIES_FILE_PATH = filepath.Join("test_data", "entries.json")
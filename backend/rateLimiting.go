@@ -0,0 +1,154 @@
+package backend
+
+import (
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+	"github.com/kherud/goblog/config"
+)
+
+/**
+Limits the rate of actions per key (e.g. client ip) using token buckets.
+Every bucket holds up to burst tokens and is refilled with rate tokens per second. Each action takes one token.
+ */
+type RateLimiter struct {
+	rate      float64
+	burst     float64
+	buckets   map[string]*tokenBucket
+	lastPrune time.Time
+	mutex     sync.Mutex
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+var (
+	commentLimitersOnce sync.Once
+	commentLimitMutex   sync.Mutex // checking and taking the tokens of both limiters happens at once
+	ipCommentLimiter    *RateLimiter
+	postCommentLimiter  *RateLimiter
+)
+
+/**
+Creates a rate limiter that allows perMinute actions per key on average and up to burst actions at once.
+ */
+func NewRateLimiter(perMinute float64, burst int) *RateLimiter {
+	return &RateLimiter{
+		rate:    perMinute / 60,
+		burst:   float64(burst),
+		buckets: map[string]*tokenBucket{},
+	}
+}
+
+/**
+Takes a token of the bucket belonging to the passed key if one is available.
+If the action is not allowed the duration until the next token is available is returned as well.
+ */
+func (l *RateLimiter) Allow(key string) (bool, time.Duration) {
+	return l.allow(key, time.Now())
+}
+
+func (l *RateLimiter) allow(key string, now time.Time) (bool, time.Duration) {
+	allowed, retryAfter := l.available(key, now)
+	if allowed {
+		l.take(key)
+	}
+	return allowed, retryAfter
+}
+
+/**
+Checks whether a token of the bucket belonging to the passed key is available without taking it.
+If none is available the duration until the next token is available is returned as well.
+ */
+func (l *RateLimiter) available(key string, now time.Time) (bool, time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.prune(now)
+	bucket, found := l.buckets[key]
+	if !found {
+		bucket = &tokenBucket{tokens: l.burst, updated: now}
+		l.buckets[key] = bucket
+	}
+	bucket.tokens = math.Min(l.burst, bucket.tokens+now.Sub(bucket.updated).Seconds()*l.rate)
+	bucket.updated = now
+	if bucket.tokens >= 1 {
+		return true, 0
+	}
+	if l.rate <= 0 {
+		return false, time.Hour
+	}
+	return false, time.Duration((1 - bucket.tokens) / l.rate * float64(time.Second))
+}
+
+/**
+Takes a token of the bucket belonging to the passed key, which has to be checked by available beforehand.
+ */
+func (l *RateLimiter) take(key string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if bucket, found := l.buckets[key]; found {
+		bucket.tokens--
+	}
+}
+
+/**
+Removes buckets that were refilled completely, since they don't differ from new ones. Runs at most once a minute.
+ */
+func (l *RateLimiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < time.Minute {
+		return
+	}
+	l.lastPrune = now
+	for key, bucket := range l.buckets {
+		if bucket.tokens+now.Sub(bucket.updated).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+/**
+Checks whether an anonymous comment submission is within the configured limits per client ip and per post.
+Authenticated requests are never limited. If the submission is rejected the duration until it is allowed again is returned.
+Tokens are only taken if both limits allow the submission, thus a rejected one doesn't count against the other limit.
+ */
+func AllowComment(r *http.Request, postId string) (bool, time.Duration) {
+	if _, loggedIn := CheckAuthentication(r); loggedIn {
+		return true, 0
+	}
+	commentLimitersOnce.Do(func() { // created lazily since the configuration may be changed on startup
+		ipCommentLimiter = NewRateLimiter(config.COMMENT_RATE_IP, config.COMMENT_BURST_IP)
+		postCommentLimiter = NewRateLimiter(config.COMMENT_RATE_POST, config.COMMENT_BURST_POST)
+	})
+	commentLimitMutex.Lock()
+	defer commentLimitMutex.Unlock()
+	now := time.Now()
+	if allowed, retryAfter := ipCommentLimiter.available(clientIp(r), now); !allowed {
+		slog.WarnContext(r.Context(), "Comment rejected by the rate limit per ip", "ip", clientIp(r))
+		ObserveComment(CommentRateLimited)
+		return false, retryAfter
+	}
+	if allowed, retryAfter := postCommentLimiter.available(postId, now); !allowed {
+		slog.WarnContext(r.Context(), "Comment rejected by the rate limit per post", "post", postId)
+		ObserveComment(CommentRateLimited)
+		return false, retryAfter
+	}
+	ipCommentLimiter.take(clientIp(r))
+	postCommentLimiter.take(postId)
+	return true, 0
+}
+
+/**
+Returns the ip address of the client that sent the request.
+ */
+func clientIp(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,75 @@
+package backend
+
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+	"time"
+	"net/http"
+)
+
+func TestRateLimiterAllow(t *testing.T) {
+	limiter := NewRateLimiter(60, 2) // one token per second
+	now := time.Now()
+	allowed, _ := limiter.allow("a", now)
+	assert.True(t, allowed)
+	allowed, _ = limiter.allow("a", now)
+	assert.True(t, allowed)
+	allowed, retryAfter := limiter.allow("a", now)
+	assert.False(t, allowed)
+	assert.True(t, retryAfter > 0 && retryAfter <= time.Second)
+	allowed, _ = limiter.allow("b", now) // keys are independent
+	assert.True(t, allowed)
+	allowed, _ = limiter.allow("a", now.Add(time.Second))
+	assert.True(t, allowed)
+	allowed, _ = limiter.allow("a", now.Add(time.Second))
+	assert.False(t, allowed)
+	// refilling never exceeds the burst
+	for idx := 0; idx < 2; idx++ {
+		allowed, _ = limiter.allow("a", now.Add(time.Hour))
+		assert.True(t, allowed)
+	}
+	allowed, _ = limiter.allow("a", now.Add(time.Hour))
+	assert.False(t, allowed)
+}
+
+func TestRateLimiterPrune(t *testing.T) {
+	limiter := NewRateLimiter(60, 1)
+	now := time.Now()
+	limiter.allow("a", now)
+	limiter.allow("b", now)
+	assert.True(t, len(limiter.buckets) == 2)
+	limiter.allow("c", now.Add(2*time.Minute))
+	assert.True(t, len(limiter.buckets) == 1)
+}
+
+func TestAllowComment(t *testing.T) {
+	commentLimitersOnce.Do(func() {})
+	ipCommentLimiter = NewRateLimiter(1, 1)
+	postCommentLimiter = NewRateLimiter(1, 2)
+	request := func(remoteAddr string, login bool) *http.Request {
+		req := &http.Request{RemoteAddr: remoteAddr, Header: http.Header{}}
+		if login {
+			req.AddCookie(&http.Cookie{Name: "Session", Value: "Konstantin#Test"})
+		}
+		return req
+	}
+	allowed, _ := AllowComment(request("1.2.3.4:1000", false), "1")
+	assert.True(t, allowed)
+	allowed, retryAfter := AllowComment(request("1.2.3.4:2000", false), "1") // same ip, different port
+	assert.False(t, allowed)
+	assert.True(t, retryAfter > 0)
+	allowed, _ = AllowComment(request("5.6.7.8:1000", false), "1")
+	assert.True(t, allowed)
+	allowed, _ = AllowComment(request("9.9.9.9:1000", false), "1") // post limit exceeded
+	assert.False(t, allowed)
+	allowed, _ = AllowComment(request("9.9.9.9:1000", false), "2") // the rejection didn't take a token of the ip
+	assert.True(t, allowed)
+	allowed, _ = AllowComment(request("1.2.3.4:1000", true), "1") // authors aren't limited
+	assert.True(t, allowed)
+}
+
+func TestClientIp(t *testing.T) {
+	assert.EqualValues(t, clientIp(&http.Request{RemoteAddr: "1.2.3.4:1000"}), "1.2.3.4")
+	assert.EqualValues(t, clientIp(&http.Request{RemoteAddr: "[::1]:1000"}), "::1")
+	assert.EqualValues(t, clientIp(&http.Request{RemoteAddr: "invalid"}), "invalid")
+}
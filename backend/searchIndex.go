@@ -0,0 +1,456 @@
+package backend
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"github.com/kherud/goblog/backend/models"
+	"github.com/kherud/goblog/config"
+	"github.com/kherud/goblog/util"
+)
+
+// Parameters of the BM25 ranking function: saturation of term frequencies and normalization of post lengths
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+/**
+An inverted index of all posts that maps the stems of their words to the posts containing them.
+Title, text, keywords and approved comments are weighted differently (see config.SEARCH_TITLE_WEIGHT).
+Tags, authors and categories are indexed as field terms, e.g. "tag:golang", and don't count towards the length of a post.
+The fingerprint identifies the state of the entries file the index belongs to.
+ */
+type SearchIndex struct {
+	documents   map[uint32]models.IndexedDocument
+	postings    map[string]map[uint32]int
+	totalLength int
+	fingerprint string
+	mutex       sync.RWMutex
+}
+
+/**
+A post matching a search query together with its relevance and an excerpt of its text highlighting the matches.
+ */
+type SearchResult struct {
+	Entry   models.Entry
+	Score   float64
+	Snippet []SnippetPart
+}
+
+/**
+A part of a snippet, matching parts are supposed to be highlighted.
+ */
+type SnippetPart struct {
+	Text  string
+	Match bool
+}
+
+/**
+The state of a single search: the index and the posts that are searched by their id.
+ */
+type indexSearch struct {
+	index   *SearchIndex
+	entries map[uint32]models.Entry
+}
+
+// The index of all posts of the entries file, loaded lazily
+var postIndex = NewSearchIndex()
+
+/**
+Creates an empty search index.
+ */
+func NewSearchIndex() *SearchIndex {
+	return &SearchIndex{documents: map[uint32]models.IndexedDocument{}, postings: map[string]map[uint32]int{}}
+}
+
+/**
+Adds a post to the index. If the post is already indexed it is replaced.
+ */
+func (index *SearchIndex) Add(entry models.Entry) {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+	index.remove(entry.Id)
+	index.add(indexDocument(entry))
+}
+
+/**
+Removes a post from the index.
+ */
+func (index *SearchIndex) Remove(postId uint32) {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+	index.remove(postId)
+}
+
+/**
+Replaces the whole content of the index by the passed posts.
+ */
+func (index *SearchIndex) Rebuild(entries []models.Entry) {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+	index.clear()
+	for _, entry := range entries {
+		index.remove(entry.Id) // guards against duplicate ids
+		index.add(indexDocument(entry))
+	}
+}
+
+/**
+Searches the passed posts for a query (see ParseSearchQuery) using the index, which is expected to contain them.
+The matching posts are ranked by their relevance according to BM25, posts with equal scores keep their order.
+ */
+func (index *SearchIndex) Search(entries []models.Entry, query string) (results []SearchResult) {
+	parsed := ParseSearchQuery(query)
+	if parsed.root == nil {
+		return nil
+	}
+	index.mutex.RLock()
+	defer index.mutex.RUnlock()
+	search := indexSearch{index: index, entries: map[uint32]models.Entry{}}
+	for _, entry := range entries {
+		search.entries[entry.Id] = entry
+	}
+	matches := parsed.root.lookup(search)
+	for _, entry := range entries {
+		if matches[entry.Id] {
+			matches[entry.Id] = false // posts are returned once only
+			document := index.documents[entry.Id]
+			results = append(results, SearchResult{
+				Entry:   entry,
+				Score:   index.score(document, parsed.Terms),
+				Snippet: createSnippet(entry.Text, document.Language, parsed.Terms, config.SNIPPET_WORDS),
+			})
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	return
+}
+
+/**
+Searches all posts for a query using the index, see SearchIndex.Search.
+The index is loaded or rebuilt beforehand if it doesn't belong to the current entries file.
+ */
+func SearchPosts(query string) []SearchResult {
+	ensureSearchIndex()
+	return postIndex.Search(GetEntries(), query)
+}
+
+/**
+Rebuilds the index of all posts and persists it. Returns the number of indexed posts.
+ */
+func RebuildSearchIndex() int {
+	fingerprint := entriesFingerprint()
+	entries := GetEntries()
+	postIndex.Rebuild(entries)
+	postIndex.persist(fingerprint)
+	return len(entries)
+}
+
+/**
+Saves the entries and updates the posts of the passed ids within the index, thus it doesn't have to be rebuilt.
+Posts that don't exist anymore are removed from the index.
+If the index didn't belong to the entries file before, it is rebuilt lazily on the next search instead.
+ */
+func saveEntriesIndexed(entries []models.Entry, postIds ...uint32) {
+	upToDate := postIndex.belongsTo(entriesFingerprint())
+	saveEntriesJson(entries)
+	if !upToDate {
+		return
+	}
+	for _, postId := range postIds {
+		postIndex.Remove(postId)
+		for _, entry := range entries {
+			if entry.Id == postId {
+				postIndex.Add(entry)
+				break
+			}
+		}
+	}
+	postIndex.persist(entriesFingerprint())
+}
+
+/**
+Ensures the index belongs to the current entries file by loading the persisted index or rebuilding it if necessary.
+ */
+func ensureSearchIndex() {
+	fingerprint := entriesFingerprint()
+	if postIndex.belongsTo(fingerprint) {
+		return
+	}
+	if stored := GetSearchIndex(); stored.Fingerprint == fingerprint {
+		postIndex.load(stored)
+	} else {
+		postIndex.Rebuild(GetEntries())
+		postIndex.persist(fingerprint)
+	}
+}
+
+/**
+Identifies the state of the entries file by its path, size and modification time.
+Returns an empty string if the file doesn't exist.
+ */
+func entriesFingerprint() string {
+	info, err := os.Stat(config.ENTRIES_FILE_PATH)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%v:%v:%v", config.ENTRIES_FILE_PATH, info.Size(), info.ModTime().UnixNano())
+}
+
+func (index *SearchIndex) belongsTo(fingerprint string) bool {
+	index.mutex.RLock()
+	defer index.mutex.RUnlock()
+	return fingerprint != "" && index.fingerprint == fingerprint
+}
+
+/**
+Marks the index as belonging to the passed state of the entries file and writes it to the index file.
+ */
+func (index *SearchIndex) persist(fingerprint string) {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+	index.fingerprint = fingerprint
+	stored := models.SearchIndex{Fingerprint: fingerprint}
+	for _, document := range index.documents {
+		stored.Documents = append(stored.Documents, document)
+	}
+	sort.Slice(stored.Documents, func(i, j int) bool {
+		return stored.Documents[i].PostId < stored.Documents[j].PostId
+	})
+	saveSearchIndexJson(stored)
+}
+
+/**
+Replaces the content of the index by a persisted index.
+ */
+func (index *SearchIndex) load(stored models.SearchIndex) {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+	index.clear()
+	for _, document := range stored.Documents {
+		index.add(document)
+	}
+	index.fingerprint = stored.Fingerprint
+}
+
+func (index *SearchIndex) clear() {
+	index.documents = map[uint32]models.IndexedDocument{}
+	index.postings = map[string]map[uint32]int{}
+	index.totalLength = 0
+	index.fingerprint = ""
+}
+
+func (index *SearchIndex) add(document models.IndexedDocument) {
+	index.documents[document.PostId] = document
+	index.totalLength += document.Length
+	for term, frequency := range document.Terms {
+		if index.postings[term] == nil {
+			index.postings[term] = map[uint32]int{}
+		}
+		index.postings[term][document.PostId] = frequency
+	}
+}
+
+func (index *SearchIndex) remove(postId uint32) {
+	document, found := index.documents[postId]
+	if !found {
+		return
+	}
+	delete(index.documents, postId)
+	index.totalLength -= document.Length
+	for term := range document.Terms {
+		delete(index.postings[term], postId)
+		if len(index.postings[term]) == 0 {
+			delete(index.postings, term)
+		}
+	}
+}
+
+/**
+Calculates the BM25 score of an indexed post for the terms of a query. Phrases are scored word by word.
+ */
+func (index *SearchIndex) score(document models.IndexedDocument, terms []string) (score float64) {
+	if len(index.documents) == 0 || index.totalLength == 0 {
+		return 0
+	}
+	averageLength := float64(index.totalLength) / float64(len(index.documents))
+	for _, term := range terms {
+		for _, word := range util.FoldedWords(term) {
+			stem := util.Stem(word, document.Language)
+			frequency := float64(document.Terms[stem])
+			if frequency == 0 {
+				continue
+			}
+			count := float64(len(index.postings[stem]))
+			idf := math.Log(1 + (float64(len(index.documents))-count+0.5)/(count+0.5))
+			score += idf * frequency * (bm25K1 + 1) /
+				(frequency + bm25K1*(1-bm25B+bm25B*float64(document.Length)/averageLength))
+		}
+	}
+	return
+}
+
+/**
+Returns the ids of all searched posts containing at least one of the passed terms.
+ */
+func (search indexSearch) postings(terms ...string) map[uint32]bool {
+	result := map[uint32]bool{}
+	for _, term := range terms {
+		for postId := range search.index.postings[term] {
+			if _, found := search.entries[postId]; found {
+				result[postId] = true
+			}
+		}
+	}
+	return result
+}
+
+/**
+Assembles the indexed representation of a post: the weighted frequencies of the stems of its words and its field terms.
+ */
+func indexDocument(entry models.Entry) models.IndexedDocument {
+	var words []string
+	for _, text := range searchableTexts(entry) {
+		words = append(words, util.FoldedWords(text)...)
+	}
+	document := models.IndexedDocument{PostId: entry.Id, Language: util.DetectLanguage(words), Terms: map[string]int{}}
+	addWords := func(text string, weight int) {
+		for _, word := range util.FoldedWords(text) {
+			document.Terms[util.Stem(word, document.Language)] += weight
+			document.Length += weight
+		}
+	}
+	addWords(entry.Title, config.SEARCH_TITLE_WEIGHT)
+	addWords(entry.Text, 1)
+	for _, keyword := range entry.Keywords {
+		addWords(keyword, config.SEARCH_KEYWORD_WEIGHT)
+		if keyword = util.FoldText(strings.TrimSpace(keyword)); keyword != "" {
+			document.Terms["tag:"+keyword] = 1
+		}
+	}
+	for _, comment := range searchableComments(entry) {
+		addWords(comment.Text, 1)
+	}
+	if author := util.FoldText(strings.TrimSpace(entry.Author)); author != "" {
+		document.Terms["author:"+author] = 1
+	}
+	for _, category := range postCategories(entry) {
+		document.Terms["category:"+category] = 1
+	}
+	return document
+}
+
+/**
+Returns all texts of a post that are searched: title, text, keywords and approved comments if enabled.
+ */
+func searchableTexts(entry models.Entry) []string {
+	texts := append([]string{entry.Title, entry.Text}, entry.Keywords...)
+	for _, comment := range searchableComments(entry) {
+		texts = append(texts, comment.Text)
+	}
+	return texts
+}
+
+/**
+Returns the verified comments of a post that aren't located in the spam queue if comments are searched at all.
+ */
+func searchableComments(entry models.Entry) (comments []models.Comment) {
+	if !config.SEARCH_COMMENTS {
+		return nil
+	}
+	for _, comment := range entry.Comments {
+		if comment.Verified && !comment.Spam {
+			comments = append(comments, comment)
+		}
+	}
+	return
+}
+
+/**
+Creates an excerpt of a text consisting of the passed number of words (param: length).
+The excerpt covers the words with the most matches of the query terms and starts a few words before the first match.
+Matching words are marked, omitted text at the beginning or end is indicated by an ellipsis.
+ */
+func createSnippet(text, language string, terms []string, length int) (snippet []SnippetPart) {
+	wanted := map[string]bool{}
+	for _, term := range terms {
+		for _, word := range util.FoldedWords(term) {
+			wanted[util.Stem(word, language)] = true
+		}
+	}
+	spans := wordSpans(text)
+	if len(spans) == 0 || length <= 0 {
+		return nil
+	}
+	matches := make([]bool, len(spans))
+	for idx, span := range spans {
+		matches[idx] = wanted[util.Stem(util.FoldText(text[span[0]:span[1]]), language)]
+	}
+	start, best, count := 0, -1, 0
+	for idx := range spans { // sliding window over the words
+		if matches[idx] {
+			count++
+		}
+		if idx >= length && matches[idx-length] {
+			count--
+		}
+		if windowStart := idx - length + 1; count > best && (windowStart >= 0 || idx == len(spans)-1) {
+			start, best = int(math.Max(0, float64(windowStart))), count
+		}
+	}
+	for idx := start; idx < start+length && idx < len(spans); idx++ { // leave a few words of context before the first match
+		if matches[idx] {
+			start = int(math.Max(float64(start), math.Min(float64(idx-3), float64(len(spans)-length))))
+			break
+		}
+	}
+	end := int(math.Min(float64(start+length), float64(len(spans))))
+	position := spans[start][0]
+	if start > 0 {
+		snippet = append(snippet, SnippetPart{Text: "… "})
+	}
+	for idx := start; idx < end; idx++ {
+		if !matches[idx] {
+			continue
+		}
+		if spans[idx][0] > position {
+			snippet = append(snippet, SnippetPart{Text: text[position:spans[idx][0]]})
+		}
+		snippet = append(snippet, SnippetPart{Text: text[spans[idx][0]:spans[idx][1]], Match: true})
+		position = spans[idx][1]
+	}
+	if spans[end-1][1] > position {
+		snippet = append(snippet, SnippetPart{Text: text[position:spans[end-1][1]]})
+	}
+	if end < len(spans) {
+		snippet = append(snippet, SnippetPart{Text: " …"})
+	}
+	return
+}
+
+/**
+Returns the byte offsets of the start and end of all words (letters and digits) of a text.
+ */
+func wordSpans(text string) (spans [][2]int) {
+	start := -1
+	for idx, char := range text {
+		isWordChar := unicode.IsLetter(char) || unicode.IsDigit(char)
+		if isWordChar && start < 0 {
+			start = idx
+		} else if !isWordChar && start >= 0 {
+			spans = append(spans, [2]int{start, idx})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, [2]int{start, len(text)})
+	}
+	return
+}
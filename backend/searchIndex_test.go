@@ -0,0 +1,115 @@
+package backend
+
+import (
+	"testing"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"github.com/stretchr/testify/assert"
+	"github.com/kherud/goblog/config"
+	"github.com/kherud/goblog/backend/models"
+)
+
+var rankingTestEntries = []models.Entry{
+	{Id: 1, Title: "Cooking", Text: "Some words about pasta and a single mention of templates.", Author: "Dzhoana"},
+	{Id: 2, Title: "Templates", Text: "Templating in Go: parsing templates and executing a template.", Author: "Konstantin"},
+	{Id: 3, Title: "Die Zeitungen", Text: "Das ist ein Artikel über die Zeitung und nicht über Templates.", Author: "Konstantin",
+		Comments: []models.Comment{
+			{Text: "Approved comment about routers", Verified: true},
+			{Text: "Unverified comment about databases"},
+			{Text: "Spam comment about casinos", Verified: true, Spam: true},
+		}},
+}
+
+func searchResultIds(results []SearchResult) (ids []uint32) {
+	for _, result := range results {
+		ids = append(ids, result.Entry.Id)
+	}
+	return
+}
+
+func TestSearchIndexRanking(t *testing.T) {
+	index := NewSearchIndex()
+	index.Rebuild(rankingTestEntries)
+	results := index.Search(rankingTestEntries, "template")
+	assert.EqualValues(t, []uint32{2, 1, 3}, searchResultIds(results))
+	assert.True(t, results[0].Score > results[1].Score)
+	assert.EqualValues(t, []uint32{3}, searchResultIds(index.Search(rankingTestEntries, "zeitung")))
+	assert.EqualValues(t, []uint32{2, 1}, searchResultIds(index.Search(rankingTestEntries, "templates -zeitungen")))
+	assert.EqualValues(t, []uint32{2}, searchResultIds(index.Search(rankingTestEntries, "\"parsing templates\"")))
+	assert.Empty(t, index.Search(rankingTestEntries, "\"templates parsing\""))
+	assert.EqualValues(t, []uint32{2, 3}, searchResultIds(index.Search(rankingTestEntries, "author:konstantin")))
+	assert.Empty(t, index.Search(rankingTestEntries, ""))
+}
+
+func TestSearchIndexComments(t *testing.T) {
+	index := NewSearchIndex()
+	index.Rebuild(rankingTestEntries)
+	assert.EqualValues(t, []uint32{3}, searchResultIds(index.Search(rankingTestEntries, "routers")))
+	assert.Empty(t, index.Search(rankingTestEntries, "databases"))
+	assert.Empty(t, index.Search(rankingTestEntries, "casinos"))
+	config.SEARCH_COMMENTS = false
+	index.Rebuild(rankingTestEntries)
+	assert.Empty(t, index.Search(rankingTestEntries, "routers"))
+	config.SEARCH_COMMENTS = true
+}
+
+func TestSearchIndexUpdate(t *testing.T) {
+	index := NewSearchIndex()
+	index.Rebuild(rankingTestEntries)
+	updated := rankingTestEntries[0]
+	updated.Text = "Now about risotto."
+	index.Add(updated)
+	entries := []models.Entry{updated, rankingTestEntries[1], rankingTestEntries[2]}
+	assert.EqualValues(t, []uint32{1}, searchResultIds(index.Search(entries, "risotto")))
+	assert.EqualValues(t, []uint32{2, 3}, searchResultIds(index.Search(entries, "templates")))
+	index.Remove(2)
+	assert.EqualValues(t, []uint32{3}, searchResultIds(index.Search(entries, "templates")))
+	assert.Empty(t, index.postings["templat"][2])
+	assert.Empty(t, index.postings["pars"])
+}
+
+func TestCreateSnippet(t *testing.T) {
+	snippet := createSnippet("One two three four five six seven eight nine ten", "en", []string{"seven"}, 4)
+	assert.EqualValues(t, []SnippetPart{{Text: "… "}, {Text: "four five six "}, {Text: "seven", Match: true}, {Text: " …"}}, snippet)
+	snippet = createSnippet("Parsing templates, executing Templates!", "en", []string{"template"}, 10)
+	assert.EqualValues(t, []SnippetPart{{Text: "Parsing "}, {Text: "templates", Match: true}, {Text: ", executing "},
+		{Text: "Templates", Match: true}}, snippet)
+	snippet = createSnippet("No match at all", "en", []string{"template"}, 2)
+	assert.EqualValues(t, []SnippetPart{{Text: "No match"}, {Text: " …"}}, snippet)
+	assert.Empty(t, createSnippet("", "en", []string{"template"}, 10))
+}
+
+func TestSearchPostsIncremental(t *testing.T) {
+	config.ENTRIES_FILE_PATH = config.TEST_TEMP_PATH
+	saveEntriesJson([]models.Entry{testEntry})
+	assert.Len(t, SearchPosts("test"), 1)
+	req := &http.Request{
+		Form:   url.Values{"text": {"Indexed immediately"}, "title": {"Incremental"}},
+		Header: http.Header{},
+	}
+	req.AddCookie(&http.Cookie{Name: "Session", Value: "Konstantin#Test"})
+	postId := CreatePost(req)
+	assert.True(t, postIndex.belongsTo(entriesFingerprint()))
+	assert.EqualValues(t, []uint32{postId}, searchResultIds(SearchPosts("immediately")))
+	stored := GetSearchIndex()
+	assert.EqualValues(t, stored.Fingerprint, entriesFingerprint())
+	assert.Len(t, stored.Documents, 2)
+	req.Form = url.Values{"postId": {"976620356"}}
+	assert.True(t, DeletePost(req))
+	assert.Empty(t, SearchPosts("test"))
+	saveEntriesJson([]models.Entry{testEntry}) // external modification
+	assert.Len(t, SearchPosts("test"), 1)
+	assert.Empty(t, SearchPosts("immediately"))
+	os.Remove(config.TEST_TEMP_PATH)
+	config.ENTRIES_FILE_PATH = filepath.Join("test_data", "entries.json")
+}
+
+func TestRebuildSearchIndex(t *testing.T) {
+	os.Remove(config.INDEX_TEST_PATH)
+	assert.EqualValues(t, RebuildSearchIndex(), len(GetEntries()))
+	stored := GetSearchIndex()
+	assert.EqualValues(t, stored.Fingerprint, entriesFingerprint())
+	assert.Len(t, stored.Documents, len(GetEntries()))
+}
@@ -0,0 +1,453 @@
+package backend
+
+import (
+	"strings"
+	"unicode"
+	"github.com/kherud/goblog/backend/models"
+	"github.com/kherud/goblog/util"
+)
+
+/**
+A parsed search query. Supports words, quoted phrases, tag:, author: and category: filters (including subcategories),
+the operators AND, OR and NOT (or a leading minus) as well as parentheses. Adjacent terms are combined with AND.
+Terms contains the folded words and phrases that are searched for, excluding negated ones.
+ */
+type SearchQuery struct {
+	root  searchNode
+	Terms []string
+}
+
+type searchNode interface {
+	matches(document searchDocument) bool
+	lookup(search indexSearch) map[uint32]bool
+}
+
+type andNode []searchNode
+type orNode []searchNode
+type notNode struct {
+	child searchNode
+}
+type termNode struct {
+	field  string
+	value  string
+	phrase bool
+}
+type categoryNode struct {
+	slugs map[string]bool
+}
+
+/**
+The folded (lower case, without accents) content of a post that is searched.
+Words are stored as stems of the detected language of the post (see util.Stem).
+Phrases are stored as space separated words with leading and trailing spaces to match whole words only.
+ */
+type searchDocument struct {
+	language   string
+	stems      map[string]bool
+	phrases    []string
+	keywords   []string
+	author     string
+	categories []string
+}
+
+const (
+	tokenTerm = iota
+	tokenOpen
+	tokenClose
+	tokenAnd
+	tokenOr
+	tokenNot
+)
+
+type queryToken struct {
+	kind   int
+	field  string
+	value  string
+	phrase bool
+}
+
+type queryParser struct {
+	tokens   []queryToken
+	position int
+	terms    []string
+}
+
+/**
+Parses a search query. Malformed queries (e.g. unbalanced parentheses or quotes) are interpreted as well as possible.
+ */
+func ParseSearchQuery(query string) SearchQuery {
+	parser := &queryParser{tokens: tokenizeQuery(query)}
+	var nodes andNode
+	for parser.position < len(parser.tokens) {
+		if node := parser.parseOr(false); node != nil {
+			nodes = append(nodes, node)
+		}
+		if parser.peek() == tokenClose { // skip unbalanced closing parentheses
+			parser.position++
+		}
+	}
+	if len(nodes) == 0 {
+		return SearchQuery{}
+	}
+	return SearchQuery{root: nodes, Terms: parser.terms}
+}
+
+/**
+Checks whether a post matches the query. An empty query matches no post.
+ */
+func (query SearchQuery) Matches(entry models.Entry) bool {
+	if query.root == nil {
+		return false
+	}
+	return query.root.matches(newSearchDocument(entry))
+}
+
+/**
+Folds and splits the searchable content of a post.
+ */
+func newSearchDocument(entry models.Entry) searchDocument {
+	document := searchDocument{
+		stems:      map[string]bool{},
+		author:     util.FoldText(strings.TrimSpace(entry.Author)),
+		categories: postCategories(entry),
+	}
+	var words []string
+	for _, text := range searchableTexts(entry) {
+		textWords := util.FoldedWords(text)
+		words = append(words, textWords...)
+		document.phrases = append(document.phrases, " "+strings.Join(textWords, " ")+" ")
+	}
+	document.language = util.DetectLanguage(words)
+	for _, word := range words {
+		document.stems[util.Stem(word, document.language)] = true
+	}
+	for _, keyword := range entry.Keywords {
+		document.keywords = append(document.keywords, util.FoldText(strings.TrimSpace(keyword)))
+	}
+	return document
+}
+
+func (node andNode) matches(document searchDocument) bool {
+	for _, child := range node {
+		if !child.matches(document) {
+			return false
+		}
+	}
+	return true
+}
+
+func (node orNode) matches(document searchDocument) bool {
+	for _, child := range node {
+		if child.matches(document) {
+			return true
+		}
+	}
+	return false
+}
+
+func (node notNode) matches(document searchDocument) bool {
+	return !node.child.matches(document)
+}
+
+func (node termNode) matches(document searchDocument) bool {
+	switch node.field {
+	case "tag":
+		for _, keyword := range document.keywords {
+			if keyword == node.value {
+				return true
+			}
+		}
+		return false
+	case "author":
+		return document.author == node.value
+	}
+	if !node.phrase {
+		for _, stem := range util.Stems(node.value) {
+			if document.stems[stem] {
+				return true
+			}
+		}
+		return false
+	}
+	for _, phrase := range document.phrases {
+		if strings.Contains(phrase, " "+node.value+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+func (node categoryNode) matches(document searchDocument) bool {
+	for _, category := range document.categories {
+		if node.slugs[category] {
+			return true
+		}
+	}
+	return false
+}
+
+func (node andNode) lookup(search indexSearch) map[uint32]bool {
+	result := node[0].lookup(search)
+	for _, child := range node[1:] {
+		childResult := child.lookup(search)
+		for postId := range result {
+			if !childResult[postId] {
+				delete(result, postId)
+			}
+		}
+	}
+	return result
+}
+
+func (node orNode) lookup(search indexSearch) map[uint32]bool {
+	result := map[uint32]bool{}
+	for _, child := range node {
+		for postId := range child.lookup(search) {
+			result[postId] = true
+		}
+	}
+	return result
+}
+
+func (node notNode) lookup(search indexSearch) map[uint32]bool {
+	excluded := node.child.lookup(search)
+	result := map[uint32]bool{}
+	for postId := range search.entries {
+		if !excluded[postId] {
+			result[postId] = true
+		}
+	}
+	return result
+}
+
+/**
+Field filters and words are looked up within the postings of the index.
+Phrases are looked up word by word, afterwards the remaining posts are checked for the exact phrase.
+ */
+func (node termNode) lookup(search indexSearch) map[uint32]bool {
+	if node.field != "" {
+		return search.postings(node.field + ":" + node.value)
+	}
+	if !node.phrase {
+		return search.postings(util.Stems(node.value)...)
+	}
+	words := strings.Fields(node.value)
+	result := search.postings(util.Stems(words[0])...)
+	for _, word := range words[1:] {
+		wordResult := search.postings(util.Stems(word)...)
+		for postId := range result {
+			if !wordResult[postId] {
+				delete(result, postId)
+			}
+		}
+	}
+	for postId := range result {
+		if !node.matches(newSearchDocument(search.entries[postId])) {
+			delete(result, postId)
+		}
+	}
+	return result
+}
+
+func (node categoryNode) lookup(search indexSearch) map[uint32]bool {
+	var terms []string
+	for slug := range node.slugs {
+		terms = append(terms, "category:"+slug)
+	}
+	return search.postings(terms...)
+}
+
+/**
+or := and ("OR" and)*
+ */
+func (parser *queryParser) parseOr(negated bool) searchNode {
+	var nodes orNode
+	for {
+		if node := parser.parseAnd(negated); node != nil {
+			nodes = append(nodes, node)
+		}
+		if parser.peek() != tokenOr {
+			break
+		}
+		parser.position++
+	}
+	if len(nodes) == 0 {
+		return nil
+	} else if len(nodes) == 1 {
+		return nodes[0]
+	}
+	return nodes
+}
+
+/**
+and := not (["AND"] not)*
+ */
+func (parser *queryParser) parseAnd(negated bool) searchNode {
+	var nodes andNode
+	for {
+		kind := parser.peek()
+		if kind == -1 || kind == tokenOr || kind == tokenClose {
+			break
+		}
+		if kind == tokenAnd {
+			parser.position++
+			continue
+		}
+		if node := parser.parseNot(negated); node != nil {
+			nodes = append(nodes, node)
+		}
+	}
+	if len(nodes) == 0 {
+		return nil
+	} else if len(nodes) == 1 {
+		return nodes[0]
+	}
+	return nodes
+}
+
+/**
+not := "NOT" not | primary
+ */
+func (parser *queryParser) parseNot(negated bool) searchNode {
+	switch parser.peek() {
+	case -1, tokenOr, tokenClose: // operator without operand
+		return nil
+	case tokenAnd:
+		parser.position++
+		return parser.parseNot(negated)
+	case tokenNot:
+		parser.position++
+		if child := parser.parseNot(!negated); child != nil {
+			return notNode{child: child}
+		}
+		return nil
+	}
+	return parser.parsePrimary(negated)
+}
+
+/**
+primary := "(" or ")" | term
+ */
+func (parser *queryParser) parsePrimary(negated bool) searchNode {
+	token := parser.tokens[parser.position]
+	parser.position++
+	if token.kind == tokenOpen {
+		node := parser.parseOr(negated)
+		if parser.peek() == tokenClose {
+			parser.position++
+		}
+		return node
+	}
+	if token.kind != tokenTerm {
+		return nil
+	}
+	if token.field == "category" { // matches the category and all of its subcategories
+		if slug := util.Slugify(token.value); slug != "" {
+			return categoryNode{slugs: CategoryDescendants(slug)}
+		}
+		return nil
+	}
+	node := termNode{field: token.field, phrase: token.phrase}
+	if token.field != "" {
+		node.value = util.FoldText(strings.TrimSpace(token.value))
+	} else {
+		words := util.FoldedWords(token.value)
+		if len(words) == 0 {
+			return nil
+		}
+		node.value = strings.Join(words, " ")
+		node.phrase = len(words) > 1
+	}
+	if node.value == "" {
+		return nil
+	}
+	if !negated {
+		parser.terms = append(parser.terms, node.value)
+	}
+	return node
+}
+
+/**
+Returns the kind of the next token or -1 if all tokens are consumed.
+ */
+func (parser *queryParser) peek() int {
+	if parser.position >= len(parser.tokens) {
+		return -1
+	}
+	return parser.tokens[parser.position].kind
+}
+
+/**
+Splits a query into terms, phrases, parentheses and operators.
+ */
+func tokenizeQuery(query string) (tokens []queryToken) {
+	runes := []rune(query)
+	for idx := 0; idx < len(runes); {
+		char := runes[idx]
+		switch {
+		case unicode.IsSpace(char):
+			idx++
+		case char == '(':
+			tokens = append(tokens, queryToken{kind: tokenOpen})
+			idx++
+		case char == ')':
+			tokens = append(tokens, queryToken{kind: tokenClose})
+			idx++
+		case char == '"':
+			var value string
+			value, idx = readQuoted(runes, idx+1)
+			tokens = append(tokens, queryToken{kind: tokenTerm, value: value, phrase: true})
+		case char == '-' && idx+1 < len(runes) && !unicode.IsSpace(runes[idx+1]):
+			tokens = append(tokens, queryToken{kind: tokenNot})
+			idx++
+		default:
+			start := idx
+			for idx < len(runes) && !unicode.IsSpace(runes[idx]) && !strings.ContainsRune("()\"", runes[idx]) {
+				idx++
+			}
+			word := string(runes[start:idx])
+			tokens = append(tokens, wordToken(word, runes, &idx))
+		}
+	}
+	return
+}
+
+/**
+Interprets a single word of a query: an operator, a field filter (field:value or field:"quoted value") or a plain term.
+ */
+func wordToken(word string, runes []rune, idx *int) queryToken {
+	switch word {
+	case "AND":
+		return queryToken{kind: tokenAnd}
+	case "OR":
+		return queryToken{kind: tokenOr}
+	case "NOT":
+		return queryToken{kind: tokenNot}
+	}
+	if separator := strings.Index(word, ":"); separator > 0 {
+		field := strings.ToLower(word[:separator])
+		if field == "tag" || field == "author" || field == "category" {
+			value := word[separator+1:]
+			if value == "" && *idx < len(runes) && runes[*idx] == '"' {
+				value, *idx = readQuoted(runes, *idx+1)
+			}
+			return queryToken{kind: tokenTerm, field: field, value: value}
+		}
+	}
+	return queryToken{kind: tokenTerm, value: word}
+}
+
+/**
+Reads a quoted value starting behind the opening quote. An unclosed quote lasts until the end of the query.
+Returns the value and the index behind the closing quote.
+ */
+func readQuoted(runes []rune, start int) (string, int) {
+	end := start
+	for end < len(runes) && runes[end] != '"' {
+		end++
+	}
+	if end < len(runes) {
+		return string(runes[start:end]), end + 1
+	}
+	return string(runes[start:end]), end
+}
@@ -0,0 +1,57 @@
+package backend
+
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/kherud/goblog/backend/models"
+)
+
+var searchTestEntries = []models.Entry{
+	{Id: 1, Title: "Templates in Go", Text: "Parsing templates is easy.", Author: "Konstantin", Keywords: []string{"golang", "Web Development"}},
+	{Id: 2, Title: "Größenwahn", Text: "Über Cafés und Crème brûlée.", Author: "Dzhoana", Keywords: []string{"food"}},
+	{Id: 3, Title: "Go routines", Text: "Concurrency is not parallelism.", Author: "Konstantin", Keywords: []string{"golang", "golang"}},
+}
+
+func TestSearchQueryMatches(t *testing.T) {
+	tests := []struct {
+		Query    string
+		Expected []uint32
+	}{{Query: "templates", Expected: []uint32{1}},
+		{Query: "GO", Expected: []uint32{1, 3}},
+		{Query: "grossenwahn", Expected: []uint32{2}},
+		{Query: "creme BRULEE", Expected: []uint32{2}},
+		{Query: "go concurrency", Expected: []uint32{3}},
+		{Query: "go AND concurrency", Expected: []uint32{3}},
+		{Query: "templates OR cafes", Expected: []uint32{1, 2}},
+		{Query: "go NOT templates", Expected: []uint32{3}},
+		{Query: "go -templates", Expected: []uint32{3}},
+		{Query: "NOT golang", Expected: []uint32{2}},
+		{Query: "(templates OR routines) AND -parsing", Expected: []uint32{3}},
+		{Query: "\"not parallelism\"", Expected: []uint32{3}},
+		{Query: "\"parallelism not\"", Expected: nil},
+		{Query: "tag:golang", Expected: []uint32{1, 3}},
+		{Query: "tag:\"web development\"", Expected: []uint32{1}},
+		{Query: "tag:web", Expected: nil},
+		{Query: "author:konstantin go", Expected: []uint32{1, 3}},
+		{Query: "author:Dzhoana OR tag:golang", Expected: []uint32{1, 2, 3}},
+		{Query: "(go", Expected: []uint32{1, 3}},
+		{Query: "go) OR", Expected: []uint32{1, 3}},
+		{Query: "\"crème", Expected: []uint32{2}},
+		{Query: "NOT", Expected: nil},
+		{Query: "", Expected: nil},
+		{Query: "  - ", Expected: nil},
+	}
+	for _, test := range tests {
+		var result []uint32
+		for _, entry := range FilterPosts(searchTestEntries, test.Query) {
+			result = append(result, entry.Id)
+		}
+		assert.EqualValues(t, test.Expected, result, test.Query)
+	}
+}
+
+func TestSearchQueryTerms(t *testing.T) {
+	query := ParseSearchQuery("Go \"Crème brûlée\" -templates tag:Food NOT (a OR b)")
+	assert.EqualValues(t, []string{"go", "creme brulee", "food"}, query.Terms)
+	assert.Empty(t, ParseSearchQuery("").Terms)
+}
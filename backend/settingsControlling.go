@@ -0,0 +1,40 @@
+package backend
+
+import (
+	"net/http"
+	"github.com/kherud/goblog/config"
+)
+
+/**
+Returns the name of the active theme. If none was selected so far the default theme (see config.DEFAULT_THEME) is active.
+ */
+func ActiveTheme() string {
+	if theme := GetSettings().Theme; theme != "" {
+		return theme
+	}
+	return config.DEFAULT_THEME
+}
+
+/**
+Selects the active theme by parsing the POST form of an http(s) request (theme: name) if the request is authenticated by an admin.
+The theme has to be one of the available ones (param: names of all installed themes).
+Returns a string that is determined to be displayed in the frontend. If it is empty everything went well.
+ */
+func UpdateTheme(r *http.Request, available []string) string {
+	user, loggedIn := CheckAuthentication(r)
+	if err := r.ParseForm(); err != nil || !loggedIn {
+		return "Something went wrong.\n"
+	} else if !user.Admin {
+		return "Only admins can change the theme.\n"
+	}
+	theme := r.FormValue("theme")
+	for _, name := range available {
+		if name == theme {
+			settings := GetSettings()
+			settings.Theme = theme
+			saveSettingsJson(settings)
+			return ""
+		}
+	}
+	return "The theme could not be found.\n"
+}
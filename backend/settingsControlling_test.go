@@ -0,0 +1,33 @@
+package backend
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/kherud/goblog/config"
+)
+
+func TestUpdateTheme(t *testing.T) {
+	defer os.Remove(config.SETTINGS_FILE_PATH)
+	assert.EqualValues(t, ActiveTheme(), config.DEFAULT_THEME)
+	available := []string{"default", "dark"}
+	assert.EqualValues(t, UpdateTheme(tagRequest(url.Values{"theme": {"dark"}}, false), available), "Something went wrong.\n")
+	assert.EqualValues(t, UpdateTheme(tagRequest(url.Values{"theme": {"unknown"}}, true), available), "The theme could not be found.\n")
+	assert.EqualValues(t, ActiveTheme(), config.DEFAULT_THEME)
+	assert.Empty(t, UpdateTheme(tagRequest(url.Values{"theme": {"dark"}}, true), available))
+	assert.EqualValues(t, ActiveTheme(), "dark")
+	assert.EqualValues(t, GetSettings().Theme, "dark")
+}
+
+func TestUpdateThemeNoAdmin(t *testing.T) {
+	defer os.Remove(config.SETTINGS_FILE_PATH)
+	raw, _ := ioutil.ReadFile(config.USERS_FILE_PATH)
+	defer ioutil.WriteFile(config.USERS_FILE_PATH, raw, 0644)
+	users := GetUsers()
+	users[0].Admin = false
+	saveUsersJson(users)
+	assert.EqualValues(t, UpdateTheme(tagRequest(url.Values{"theme": {"dark"}}, true), []string{"default", "dark"}), "Only admins can change the theme.\n")
+	assert.EqualValues(t, ActiveTheme(), config.DEFAULT_THEME)
+}
@@ -0,0 +1,162 @@
+package backend
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+	"github.com/kherud/goblog/config"
+	"github.com/kherud/goblog/backend/models"
+)
+
+/**
+A page listed by the sitemap together with the date of its last modification (zero if unknown).
+ */
+type SitemapUrl struct {
+	Loc     string
+	LastMod time.Time
+}
+
+/**
+Returns all pages that should be discovered by search engines: the index page, every post, every used tag
+and the archive pages of all years and months. The date of the last modification is derived from the posts (and their comments).
+ */
+func SitemapUrls() []SitemapUrl {
+	entries := GetEntries()
+	modified := map[uint32]time.Time{}
+	var newest time.Time
+	for _, entry := range entries {
+		modified[entry.Id] = postModified(entry)
+		newest = latest(newest, modified[entry.Id])
+	}
+	urls := []SitemapUrl{{Loc: BaseUrl() + "/", LastMod: newest}}
+	for _, entry := range entries {
+		urls = append(urls, SitemapUrl{Loc: fmt.Sprintf("%v/?id=%v", BaseUrl(), entry.Id), LastMod: modified[entry.Id]})
+	}
+	for _, tag := range ListTags() {
+		if tag.Count == 0 {
+			continue
+		}
+		var lastMod time.Time
+		for _, entry := range FilterPostsByTag(entries, tag.Slug) {
+			lastMod = latest(lastMod, modified[entry.Id])
+		}
+		urls = append(urls, SitemapUrl{Loc: BaseUrl() + "/?tag=" + url.QueryEscape(tag.Slug), LastMod: lastMod})
+	}
+	years := map[int]time.Time{}
+	var yearOrder []int
+	for _, month := range ArchiveMonths(entries) {
+		var lastMod time.Time
+		for _, entry := range FilterPostsByDate(entries, month.Year, int(month.Month)) {
+			lastMod = latest(lastMod, modified[entry.Id])
+		}
+		if _, found := years[month.Year]; !found {
+			yearOrder = append(yearOrder, month.Year)
+		}
+		years[month.Year] = latest(years[month.Year], lastMod)
+		urls = append(urls, SitemapUrl{Loc: BaseUrl() + month.Path(), LastMod: lastMod})
+	}
+	for _, year := range yearOrder {
+		urls = append(urls, SitemapUrl{Loc: fmt.Sprintf("%v/archive/%d", BaseUrl(), year), LastMod: years[year]})
+	}
+	return urls
+}
+
+type sitemapUrlSet struct {
+	XMLName xml.Name         `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	Urls    []sitemapElement `xml:"url"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name         `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 sitemapindex"`
+	Sitemaps []sitemapElement `xml:"sitemap"`
+}
+
+type sitemapElement struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+/**
+Renders the sitemap. Page 0 is the sitemap itself (/sitemap.xml). If it would contain more than config.SITEMAP_MAX_URLS urls
+it becomes a sitemap index that references the pages 1 to n (/sitemap.xml?page=n) containing the urls instead.
+Returns an error if the requested page doesn't exist.
+ */
+func Sitemap(page int) ([]byte, error) {
+	urls := SitemapUrls()
+	pages := (len(urls) + config.SITEMAP_MAX_URLS - 1) / config.SITEMAP_MAX_URLS
+	if page == 0 && pages <= 1 {
+		return marshalXml(sitemapUrlSet{Urls: sitemapElements(urls)})
+	}
+	if page == 0 {
+		var index sitemapIndex
+		for idx := 1; idx <= pages; idx++ {
+			var lastMod time.Time
+			for _, url := range sitemapPage(urls, idx) {
+				lastMod = latest(lastMod, url.LastMod)
+			}
+			index.Sitemaps = append(index.Sitemaps, sitemapElements([]SitemapUrl{
+				{Loc: fmt.Sprintf("%v/sitemap.xml?page=%d", BaseUrl(), idx), LastMod: lastMod}})...)
+		}
+		return marshalXml(index)
+	}
+	if page < 0 || page > pages || pages <= 1 {
+		return nil, errors.New("sitemap page not found")
+	}
+	return marshalXml(sitemapUrlSet{Urls: sitemapElements(sitemapPage(urls, page))})
+}
+
+/**
+Assembles the robots.txt: the configured rules (see config.ROBOTS_RULES) followed by a reference to the sitemap.
+ */
+func RobotsTxt() string {
+	rules := config.ROBOTS_RULES
+	if rules != "" && rules[len(rules)-1] != '\n' {
+		rules += "\n"
+	}
+	return rules + "\nSitemap: " + BaseUrl() + "/sitemap.xml\n"
+}
+
+func sitemapPage(urls []SitemapUrl, page int) []SitemapUrl {
+	start := (page - 1) * config.SITEMAP_MAX_URLS
+	end := start + config.SITEMAP_MAX_URLS
+	if end > len(urls) {
+		end = len(urls)
+	}
+	return urls[start:end]
+}
+
+func sitemapElements(urls []SitemapUrl) (elements []sitemapElement) {
+	for _, url := range urls {
+		element := sitemapElement{Loc: url.Loc}
+		if !url.LastMod.IsZero() {
+			element.LastMod = url.LastMod.Format(time.RFC3339)
+		}
+		elements = append(elements, element)
+	}
+	return
+}
+
+/**
+Returns the date of the last modification of a post: its date, the date of its last edit or the date of its newest comment that isn't spam.
+ */
+func postModified(entry models.Entry) (modified time.Time) {
+	modified, _ = ParseEntryDate(entry.Date)
+	if edited, err := ParseEntryDate(entry.Modified); err == nil {
+		modified = latest(modified, edited)
+	}
+	for _, comment := range entry.Comments {
+		if date, err := ParseEntryDate(comment.Date); err == nil && !comment.Spam {
+			modified = latest(modified, date)
+		}
+	}
+	return
+}
+
+func latest(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,83 @@
+package backend
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+	"github.com/kherud/goblog/config"
+	"github.com/kherud/goblog/backend/models"
+)
+
+func TestSitemapUrls(t *testing.T) {
+	urls := SitemapUrls()
+	// index, 7 posts, 4 tags, one month and one year
+	assert.Len(t, urls, 14)
+	newest, _ := ParseEntryDate("04.01.2018 - 03:40") // newest comment
+	assert.EqualValues(t, SitemapUrl{Loc: BaseUrl() + "/", LastMod: newest}, urls[0])
+	assert.EqualValues(t, urls[1].Loc, BaseUrl()+"/?id=708643541")
+	assert.EqualValues(t, urls[8].Loc, BaseUrl()+"/?tag=asd")
+	assert.EqualValues(t, urls[12].Loc, BaseUrl()+"/archive/2018/01")
+	assert.EqualValues(t, urls[13].Loc, BaseUrl()+"/archive/2018")
+	assert.EqualValues(t, urls[13].LastMod, urls[12].LastMod)
+	useTagTestEntries()
+	defer resetTagTestEntries()
+	saveEntriesJson([]models.Entry{{Id: 1, Title: "Sharp", Keywords: []string{"C#"}}})
+	urls = SitemapUrls()
+	assert.EqualValues(t, urls[len(urls)-1].Loc, BaseUrl()+"/?tag=c%23")
+}
+
+func TestSitemapUrlsEdited(t *testing.T) {
+	useTagTestEntries()
+	defer resetTagTestEntries()
+	saveEntriesJson([]models.Entry{{Id: 1, Title: "Edited", Text: "Old", Author: "Konstantin", AuthorId: 689017489, Date: "03.01.2018 - 17:19"}})
+	published, _ := ParseEntryDate("03.01.2018 - 17:19")
+	assert.EqualValues(t, SitemapUrls()[1].LastMod, published)
+	assert.True(t, UpdatePost(tagRequest(url.Values{"text": {"New"}, "title": {"Edited"}}, true), "1"))
+	post, _ := GetPost("1")
+	edited, _ := ParseEntryDate(post.Modified)
+	assert.True(t, edited.After(published))
+	assert.EqualValues(t, SitemapUrls()[1].LastMod, edited)
+}
+
+func TestPostModified(t *testing.T) {
+	entry := models.Entry{Date: "02.01.2018 - 20:55", Comments: []models.Comment{
+		{Date: "05.01.2018 - 10:00"}, {Date: "07.01.2018 - 10:00", Spam: true}, {Date: "invalid"}}}
+	assert.EqualValues(t, postModified(entry), time.Date(2018, time.January, 5, 10, 0, 0, 0, time.Local))
+	assert.True(t, postModified(models.Entry{Date: "invalid"}).IsZero())
+}
+
+func TestSitemap(t *testing.T) {
+	sitemap, err := Sitemap(0)
+	assert.NoError(t, err)
+	assert.Contains(t, string(sitemap), `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`)
+	assert.EqualValues(t, strings.Count(string(sitemap), "<url>"), 14)
+	assert.Contains(t, string(sitemap), "<lastmod>")
+	_, err = Sitemap(1)
+	assert.Error(t, err)
+}
+
+func TestSitemapIndex(t *testing.T) {
+	config.SITEMAP_MAX_URLS = 4
+	defer func() { config.SITEMAP_MAX_URLS = 50000 }()
+	index, err := Sitemap(0)
+	assert.NoError(t, err)
+	assert.Contains(t, string(index), `<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`)
+	assert.EqualValues(t, strings.Count(string(index), "<sitemap>"), 4)
+	assert.Contains(t, string(index), "<loc>"+BaseUrl()+"/sitemap.xml?page=4</loc>")
+	page, err := Sitemap(4)
+	assert.NoError(t, err)
+	assert.EqualValues(t, strings.Count(string(page), "<url>"), 2)
+	_, err = Sitemap(5)
+	assert.Error(t, err)
+	_, err = Sitemap(-1)
+	assert.Error(t, err)
+}
+
+func TestRobotsTxt(t *testing.T) {
+	assert.EqualValues(t, RobotsTxt(), config.ROBOTS_RULES+"\nSitemap: "+BaseUrl()+"/sitemap.xml\n")
+	config.ROBOTS_RULES = "User-agent: *\nDisallow: /"
+	defer func() { config.ROBOTS_RULES = "User-agent: *\nDisallow: /?search=\nDisallow: /?more=\nDisallow: /?suggest=\n" }()
+	assert.EqualValues(t, RobotsTxt(), "User-agent: *\nDisallow: /\n\nSitemap: "+BaseUrl()+"/sitemap.xml\n")
+}
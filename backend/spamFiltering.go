@@ -0,0 +1,311 @@
+package backend
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+	"github.com/kherud/goblog/backend/models"
+	"github.com/kherud/goblog/config"
+)
+
+/**
+A comment of the spam queue together with information about the post it belongs to.
+ */
+type QueuedComment struct {
+	PostId    uint32
+	PostTitle string
+	Comment   models.Comment
+}
+
+// Training states of a comment, an empty state means the classifier hasn't learned it yet
+const (
+	trainedSpam = "spam"
+	trainedHam  = "ham"
+)
+
+// Signs the form tokens, thus tokens of a previous server run are invalid
+var commentTokenSecret = createSecret()
+
+/**
+Creates a token that is embedded into the comment form. It contains the time the form was rendered and a signature.
+The token is used to determine how long it took to submit a comment.
+ */
+func CreateCommentToken() string {
+	return createCommentToken(time.Now())
+}
+
+/**
+Scores a comment extracted from the POST form of an http(s) request between 0 (ham) and 1 (spam).
+Combines the results of a honeypot form field, the time to submit, the amount of links, a blocklist and the trained classifier.
+ */
+func ScoreComment(r *http.Request, text string) float64 {
+	scores := []float64{
+		honeypotScore(r.FormValue("website")),
+		submitTimeScore(r.FormValue("rendered"), time.Now()),
+		linkScore(text),
+		blocklistScore(text),
+		classifierScore(GetSpamModel(), text),
+	}
+	return combineScores(scores)
+}
+
+/**
+Moves a comment to the spam queue if the request is authenticated and all requirements are met.
+Does so by parsing the POST form of a request and extracting the comment id and its affiliated post id.
+The decision is used to train the classifier, also if the comment was already flagged automatically. Returns a boolean that represents whether the comment was successfully marked.
+ */
+func MarkCommentSpam(r *http.Request) bool {
+	_, loggedIn := CheckAuthentication(r)
+	var postId, commentId string
+	if err := r.ParseForm(); err == nil && loggedIn {
+		postId = r.FormValue("postId")
+		commentId = r.FormValue("commentId")
+	} else {
+		return false
+	}
+	entries := GetEntries()
+	for entryIdx, entry := range entries { // search for entry
+		if strconv.Itoa(int(entry.Id)) == postId {
+			for commentIdx, comment := range entry.Comments { // search for comment
+				if strconv.Itoa(int(comment.Id)) == commentId {
+					entries[entryIdx].Comments[commentIdx].Spam = true
+					entries[entryIdx].Comments[commentIdx].Verified = false
+					entries[entryIdx].Comments[commentIdx].Trained = learnVerdict(comment, true)
+					saveEntriesIndexed(entries, entry.Id)
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+/**
+Returns all comments of all posts that are currently located in the spam queue.
+ */
+func GetSpamQueue() (queue []QueuedComment) {
+	for _, entry := range GetEntries() {
+		for _, comment := range entry.Comments {
+			if comment.Spam {
+				queue = append(queue, QueuedComment{PostId: entry.Id, PostTitle: entry.Title, Comment: comment})
+			}
+		}
+	}
+	return
+}
+
+/**
+Creates a form token for the passed time: "<unix seconds>.<signature>"
+ */
+func createCommentToken(rendered time.Time) string {
+	timestamp := strconv.FormatInt(rendered.Unix(), 10)
+	return timestamp + "." + signCommentTimestamp(timestamp)
+}
+
+/**
+Creates a random secret of 32 bytes using a cryptographically secure source, thus it can't be predicted.
+ */
+func createSecret() []byte {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		panic(err)
+	}
+	return secret
+}
+
+/**
+Signs a timestamp with the secret of the current server run using HMAC-SHA256.
+ */
+func signCommentTimestamp(timestamp string) string {
+	mac := hmac.New(sha256.New, commentTokenSecret)
+	mac.Write([]byte(timestamp))
+	return base64.URLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+/**
+Validates a form token and returns the time the form was rendered.
+The boolean is false if the token is missing, malformed or has an invalid signature.
+ */
+func parseCommentToken(token string) (time.Time, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 || !hmac.Equal([]byte(signCommentTimestamp(parts[0])), []byte(parts[1])) {
+		return time.Time{}, false
+	}
+	seconds, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(seconds, 0), true
+}
+
+/**
+The honeypot field is hidden from humans, thus only bots fill it in.
+ */
+func honeypotScore(honeypot string) float64 {
+	if honeypot != "" {
+		return 1
+	}
+	return 0
+}
+
+/**
+Comments submitted faster than a human could type them are suspicious, as well as comments without a valid form token.
+ */
+func submitTimeScore(token string, now time.Time) float64 {
+	rendered, valid := parseCommentToken(token)
+	if !valid {
+		return 0.5
+	}
+	if now.Sub(rendered) < time.Duration(config.SPAM_MIN_SUBMIT_SECONDS)*time.Second {
+		return 0.9
+	}
+	return 0
+}
+
+/**
+Every link exceeding the allowed amount increases the score.
+ */
+func linkScore(text string) float64 {
+	lower := strings.ToLower(text)
+	links := strings.Count(lower, "http://") + strings.Count(lower, "https://") + strings.Count(lower, "www.")
+	if links <= config.SPAM_MAX_LINKS {
+		return 0
+	}
+	return math.Min(0.3*float64(links-config.SPAM_MAX_LINKS), 0.9)
+}
+
+/**
+Every term of the blocklist that is contained in the comment increases the score.
+ */
+func blocklistScore(text string) float64 {
+	lower := strings.ToLower(text)
+	var scores []float64
+	for _, term := range config.SPAM_BLOCKLIST {
+		if strings.Contains(lower, strings.ToLower(term)) {
+			scores = append(scores, 0.8)
+		}
+	}
+	return combineScores(scores)
+}
+
+/**
+Returns the spam probability of the naive Bayes classifier.
+The classifier is only considered once enough moderation decisions were made to train it.
+ */
+func classifierScore(model models.SpamModel, text string) float64 {
+	if model.SpamComments < config.SPAM_MIN_TRAINING || model.HamComments < config.SPAM_MIN_TRAINING {
+		return 0
+	}
+	return classifySpam(model, text)
+}
+
+/**
+Calculates the spam probability of a text using multinomial naive Bayes with Laplace smoothing.
+ */
+func classifySpam(model models.SpamModel, text string) float64 {
+	spamTotal, hamTotal := 0, 0
+	vocabulary := map[string]bool{}
+	for token, count := range model.SpamTokens {
+		spamTotal += count
+		vocabulary[token] = true
+	}
+	for token, count := range model.HamTokens {
+		hamTotal += count
+		vocabulary[token] = true
+	}
+	comments := float64(model.SpamComments + model.HamComments)
+	spamLog := math.Log(float64(model.SpamComments) / comments)
+	hamLog := math.Log(float64(model.HamComments) / comments)
+	for _, token := range tokenizeComment(text) {
+		spamLog += math.Log(float64(model.SpamTokens[token]+1) / float64(spamTotal+len(vocabulary)))
+		hamLog += math.Log(float64(model.HamTokens[token]+1) / float64(hamTotal+len(vocabulary)))
+	}
+	return 1 / (1 + math.Exp(hamLog-spamLog))
+}
+
+/**
+Trains the classifier with the verdict of a moderator about a comment. A comment is learned at most once per verdict,
+if it was learned with the opposite verdict before, that training is undone. Returns the new training state of the comment.
+ */
+func learnVerdict(comment models.Comment, spam bool) string {
+	verdict := trainedHam
+	if spam {
+		verdict = trainedSpam
+	}
+	if comment.Trained == verdict {
+		return verdict
+	}
+	if comment.Trained != "" {
+		untrainSpamModel(comment.Text, comment.Trained == trainedSpam)
+	}
+	trainSpamModel(comment.Text, spam)
+	return verdict
+}
+
+/**
+Adds the tokens of a text to the spam or ham (param: spam) statistics of the classifier and saves it.
+ */
+func trainSpamModel(text string, spam bool) {
+	changeSpamModel(text, spam, 1)
+}
+
+/**
+Removes the tokens of a previously trained text from the spam or ham (param: spam) statistics of the classifier and saves it.
+ */
+func untrainSpamModel(text string, spam bool) {
+	changeSpamModel(text, spam, -1)
+}
+
+/**
+Changes the spam or ham statistics of the classifier by delta for a text. Counts never drop below zero.
+ */
+func changeSpamModel(text string, spam bool, delta int) {
+	model := GetSpamModel()
+	comments, tokens := &model.HamComments, model.HamTokens
+	if spam {
+		comments, tokens = &model.SpamComments, model.SpamTokens
+	}
+	*comments = max(*comments+delta, 0)
+	for _, token := range tokenizeComment(text) {
+		if count := tokens[token] + delta; count > 0 {
+			tokens[token] = count
+		} else {
+			delete(tokens, token)
+		}
+	}
+	saveSpamModelJson(model)
+}
+
+/**
+Splits a text into lower case words. Very short and very long words are ignored.
+ */
+func tokenizeComment(text string) (tokens []string) {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		if length := utf8.RuneCountInString(word); length > 1 && length <= 30 {
+			tokens = append(tokens, word)
+		}
+	}
+	return
+}
+
+/**
+Combines independent scores: the result is the probability that at least one of them applies.
+ */
+func combineScores(scores []float64) float64 {
+	ham := 1.0
+	for _, score := range scores {
+		ham *= 1 - score
+	}
+	return 1 - ham
+}
@@ -0,0 +1,170 @@
+package backend
+
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"time"
+	"net/url"
+	"net/http"
+	"path/filepath"
+	"github.com/kherud/goblog/backend/models"
+	"github.com/kherud/goblog/config"
+)
+
+func TestCommentToken(t *testing.T) {
+	rendered := time.Now().Add(-time.Minute)
+	token := createCommentToken(rendered)
+	parsed, valid := parseCommentToken(token)
+	assert.True(t, valid)
+	assert.EqualValues(t, parsed.Unix(), rendered.Unix())
+	invalidTokens := []string{"", "123", "123.abc", token + "x", "1" + token}
+	for _, invalid := range invalidTokens {
+		_, valid = parseCommentToken(invalid)
+		assert.False(t, valid)
+	}
+}
+
+func TestSubmitTimeScore(t *testing.T) {
+	now := time.Now()
+	assert.True(t, submitTimeScore(createCommentToken(now.Add(-time.Minute)), now) == 0)
+	assert.True(t, submitTimeScore(createCommentToken(now.Add(-time.Second)), now) == 0.9)
+	assert.True(t, submitTimeScore("", now) == 0.5)
+}
+
+func TestHeuristicScores(t *testing.T) {
+	assert.True(t, honeypotScore("") == 0)
+	assert.True(t, honeypotScore("http://spam.example") == 1)
+	assert.True(t, linkScore("see https://a.example and http://b.example") == 0)
+	assert.True(t, linkScore("https://a.example https://b.example www.c.example") > 0)
+	assert.True(t, linkScore("https://a https://b https://c https://d https://e https://f https://g") == 0.9)
+	assert.True(t, blocklistScore("Nice post!") == 0)
+	assert.True(t, blocklistScore("Cheap VIAGRA here") == 0.8)
+	assert.True(t, blocklistScore("viagra at the casino") > 0.9)
+}
+
+func TestCombineScores(t *testing.T) {
+	assert.True(t, combineScores(nil) == 0)
+	assert.True(t, combineScores([]float64{0, 1}) == 1)
+	assert.InDelta(t, combineScores([]float64{0.5, 0.5}), 0.75, 0.0001)
+}
+
+func TestTokenizeComment(t *testing.T) {
+	tokens := tokenizeComment("Hello, World! a Größe x-ray https://spam.example")
+	assert.EqualValues(t, []string{"hello", "world", "größe", "ray", "https", "spam", "example"}, tokens)
+}
+
+func TestClassifierScore(t *testing.T) {
+	config.SPAM_FILE_PATH = config.TEST_TEMP_PATH
+	assert.True(t, classifierScore(GetSpamModel(), "cheap pills online") == 0) // untrained
+	for idx := 0; idx < config.SPAM_MIN_TRAINING; idx++ {
+		trainSpamModel("buy cheap pills online now", true)
+		trainSpamModel("great article about go templates", false)
+	}
+	model := GetSpamModel()
+	assert.EqualValues(t, model.SpamComments, config.SPAM_MIN_TRAINING)
+	assert.EqualValues(t, model.HamComments, config.SPAM_MIN_TRAINING)
+	assert.True(t, classifierScore(model, "cheap pills") > 0.9)
+	assert.True(t, classifierScore(model, "nice article about templates") < 0.1)
+	os.Remove(config.TEST_TEMP_PATH)
+	config.SPAM_FILE_PATH = config.SPAM_TEST_PATH
+}
+
+func TestSaveCommentSpam(t *testing.T) {
+	config.ENTRIES_FILE_PATH = config.TEST_TEMP_PATH
+	saveEntriesJson([]models.Entry{testEntry})
+	tests := []struct {
+		Params url.Values
+		Spam   bool
+	}{{Params: url.Values{"text": {"Nice post"}, "rendered": {createCommentToken(time.Now().Add(-time.Minute))}}, Spam: false},
+		{Params: url.Values{"text": {"Nice post"}, "website": {"http://spam.example"}}, Spam: true},
+		{Params: url.Values{"text": {"Visit our casino"}}, Spam: true}, // missing token and blocklist
+	}
+	for _, test := range tests {
+		req := &http.Request{
+			Form:   test.Params,
+			Header: http.Header{},
+		}
+		SaveComment(req, "976620356")
+		post, err := GetPost("976620356")
+		assert.Nil(t, err)
+		assert.EqualValues(t, post.Comments[0].Spam, test.Spam)
+	}
+	queue := GetSpamQueue()
+	assert.True(t, len(queue) == 2)
+	assert.EqualValues(t, queue[0].PostId, testEntry.Id)
+	assert.EqualValues(t, queue[0].PostTitle, testEntry.Title)
+	os.Remove(config.TEST_TEMP_PATH)
+	config.ENTRIES_FILE_PATH = filepath.Join("test_data", "entries.json")
+}
+
+func TestMarkCommentSpam(t *testing.T) {
+	os.Remove(config.SPAM_TEST_PATH) // start with an untrained classifier
+	config.ENTRIES_FILE_PATH = config.TEST_TEMP_PATH
+	saveEntriesJson([]models.Entry{testEntry})
+	tests := []struct {
+		Params url.Values
+		Login  bool
+		Result bool
+	}{{Params: url.Values{"postId": {"976620356"}, "commentId": {"489017489"}}, Login: false, Result: false},
+		{Params: url.Values{"postId": {"976620356"}, "commentId": {"123"}}, Login: true, Result: false},
+		{Params: url.Values{"postId": {"976620356"}, "commentId": {"489017489"}}, Login: true, Result: true},
+		{Params: url.Values{"postId": {"976620356"}, "commentId": {"489017489"}}, Login: true, Result: true}, // marked twice
+	}
+	for _, test := range tests {
+		req := &http.Request{
+			Form:   test.Params,
+			Header: http.Header{},
+		}
+		if test.Login {
+			cookie := &http.Cookie{Name: "Session", Value: "Konstantin#Test"}
+			req.AddCookie(cookie)
+		}
+		assert.EqualValues(t, MarkCommentSpam(req), test.Result)
+		post, err := GetPost("976620356")
+		assert.Nil(t, err)
+		assert.EqualValues(t, post.Comments[0].Spam, test.Result)
+	}
+	assert.EqualValues(t, GetSpamModel().SpamComments, 1)
+	// verifying moves the comment back and trains it as ham instead of spam
+	assert.True(t, VerifyComment(moderationRequest("489017489")))
+	post, _ := GetPost("976620356")
+	assert.False(t, post.Comments[0].Spam)
+	assert.EqualValues(t, GetSpamModel().HamComments, 1)
+	assert.EqualValues(t, GetSpamModel().SpamComments, 0)
+	assert.Empty(t, GetSpamModel().SpamTokens)
+	assert.Empty(t, GetSpamQueue())
+	// marking it again trains it as spam instead of ham
+	assert.True(t, MarkCommentSpam(moderationRequest("489017489")))
+	assert.EqualValues(t, GetSpamModel().HamComments, 0)
+	assert.EqualValues(t, GetSpamModel().SpamComments, 1)
+	assert.Empty(t, GetSpamModel().HamTokens)
+	os.Remove(config.TEST_TEMP_PATH)
+	os.Remove(config.SPAM_TEST_PATH)
+	config.ENTRIES_FILE_PATH = filepath.Join("test_data", "entries.json")
+}
+
+func TestMarkCommentSpamFlagged(t *testing.T) {
+	os.Remove(config.SPAM_TEST_PATH) // start with an untrained classifier
+	config.ENTRIES_FILE_PATH = config.TEST_TEMP_PATH
+	entry := testEntry
+	entry.Comments = []models.Comment{{Text: "Visit our casino", Id: 489017489, Spam: true}} // flagged automatically
+	saveEntriesJson([]models.Entry{entry})
+	// confirming the automatic decision trains the classifier
+	assert.True(t, MarkCommentSpam(moderationRequest("489017489")))
+	assert.EqualValues(t, GetSpamModel().SpamComments, 1)
+	post, _ := GetPost("976620356")
+	assert.EqualValues(t, post.Comments[0].Trained, "spam")
+	os.Remove(config.TEST_TEMP_PATH)
+	os.Remove(config.SPAM_TEST_PATH)
+	config.ENTRIES_FILE_PATH = filepath.Join("test_data", "entries.json")
+}
+
+func moderationRequest(commentId string) *http.Request {
+	req := &http.Request{
+		Form:   url.Values{"postId": {"976620356"}, "commentId": {commentId}},
+		Header: http.Header{},
+	}
+	req.AddCookie(&http.Cookie{Name: "Session", Value: "Konstantin#Test"})
+	return req
+}
@@ -1,10 +1,15 @@
 package backend
 
 import (
+	"bytes"
 	"encoding/json"
-	"io/ioutil"
 	"fmt"
+	"time"
+	"io/ioutil"
 	"os"
+	"log/slog"
+	"path/filepath"
+	"sync"
 	"github.com/kherud/goblog/config"
 	"github.com/kherud/goblog/backend/models"
 )
@@ -32,44 +37,285 @@ func GetEntries() []models.Entry {
 }
 
 /**
-Writes an users slice to the users.json file.
+Reads and returns the trained spam classifier from the spam.json file.
+If none is found an untrained model is returned.
  */
-func saveUsersJson(users []models.User) {
-	file, err := os.Create(config.USERS_FILE_PATH)
-	if err != nil {
-		panic(err)
+func GetSpamModel() models.SpamModel {
+	raw := readFile(config.SPAM_FILE_PATH)
+	var model models.SpamModel
+	json.Unmarshal(raw, &model)
+	if model.SpamTokens == nil {
+		model.SpamTokens = map[string]int{}
 	}
-	defer file.Close()
-	encoder := json.NewEncoder(file)
-	if err := encoder.Encode(users); err != nil {
-		panic(err)
+	if model.HamTokens == nil {
+		model.HamTokens = map[string]int{}
 	}
+	return model
+}
+
+/**
+Reads and returns all reply subscriptions of readers from the subscriptions.json file.
+If none are found an empty slice is returned.
+ */
+func GetSubscriptions() []models.Subscription {
+	raw := readFile(config.SUBSCRIPTIONS_FILE_PATH)
+	var subscriptions []models.Subscription
+	json.Unmarshal(raw, &subscriptions)
+	return subscriptions
+}
+
+/**
+Reads and returns all notifications waiting for the next digest from the digest.json file.
+If none are found an empty slice is returned.
+ */
+func GetDigestQueue() []models.Notification {
+	raw := readFile(config.DIGEST_FILE_PATH)
+	var notifications []models.Notification
+	json.Unmarshal(raw, &notifications)
+	return notifications
+}
+
+/**
+Reads and returns the persisted search index from the index.json file.
+If none is found an empty index without fingerprint is returned.
+ */
+func GetSearchIndex() models.SearchIndex {
+	raw := readFile(config.INDEX_FILE_PATH)
+	var index models.SearchIndex
+	json.Unmarshal(raw, &index)
+	return index
+}
+
+/**
+Reads and returns the names and descriptions of all tags from the tags.json file.
+If none are found an empty slice is returned.
+ */
+func GetTags() []models.Tag {
+	raw := readFile(config.TAGS_FILE_PATH)
+	var tags []models.Tag
+	json.Unmarshal(raw, &tags)
+	return tags
+}
+
+/**
+Reads and returns all categories from the categories.json file.
+If none are found an empty slice is returned.
+ */
+func GetCategories() []models.Category {
+	raw := readFile(config.CATEGORIES_FILE_PATH)
+	var categories []models.Category
+	json.Unmarshal(raw, &categories)
+	return categories
+}
+
+/**
+Reads and returns the media library (all uploaded files) from the media.json file.
+If none are found an empty slice is returned.
+ */
+func GetMedia() []models.Media {
+	raw := readFile(config.MEDIA_FILE_PATH)
+	var media []models.Media
+	json.Unmarshal(raw, &media)
+	return media
+}
+
+/**
+Reads and returns the settings of the blog (e.g. the active theme) from the settings.json file.
+If none are found the zero value is returned.
+ */
+func GetSettings() models.Settings {
+	raw := readFile(config.SETTINGS_FILE_PATH)
+	var settings models.Settings
+	json.Unmarshal(raw, &settings)
+	return settings
+}
+
+/**
+Writes an users slice to the users.json file.
+ */
+func saveUsersJson(users []models.User) {
+	writeJson(config.USERS_FILE_PATH, users)
 }
 
 /**
 Writes an entries slice to the entries.json file.
  */
 func saveEntriesJson(entries []models.Entry) {
-	file, err := os.Create(config.ENTRIES_FILE_PATH)
-	if err != nil {
+	writeJson(config.ENTRIES_FILE_PATH, entries)
+}
+
+/**
+Writes the spam classifier to the spam.json file.
+ */
+func saveSpamModelJson(model models.SpamModel) {
+	writeJson(config.SPAM_FILE_PATH, model)
+}
+
+/**
+Writes a subscriptions slice to the subscriptions.json file.
+ */
+func saveSubscriptionsJson(subscriptions []models.Subscription) {
+	writeJson(config.SUBSCRIPTIONS_FILE_PATH, subscriptions)
+}
+
+/**
+Writes a notifications slice to the digest.json file.
+ */
+func saveDigestQueueJson(notifications []models.Notification) {
+	writeJson(config.DIGEST_FILE_PATH, notifications)
+}
+
+/**
+Writes the search index to the index.json file.
+ */
+func saveSearchIndexJson(index models.SearchIndex) {
+	writeJson(config.INDEX_FILE_PATH, index)
+}
+
+/**
+Writes a tags slice to the tags.json file.
+ */
+func saveTagsJson(tags []models.Tag) {
+	writeJson(config.TAGS_FILE_PATH, tags)
+}
+
+/**
+Writes a categories slice to the categories.json file.
+ */
+func saveCategoriesJson(categories []models.Category) {
+	writeJson(config.CATEGORIES_FILE_PATH, categories)
+}
+
+/**
+Writes a media slice to the media.json file.
+ */
+func saveMediaJson(media []models.Media) {
+	writeJson(config.MEDIA_FILE_PATH, media)
+}
+
+/**
+Writes the settings to the settings.json file.
+ */
+func saveSettingsJson(settings models.Settings) {
+	writeJson(config.SETTINGS_FILE_PATH, settings)
+}
+
+// serializes writes of data files and counts the pending ones, thus a shutdown is able to await them (see FlushStorage)
+var (
+	storageMutex  sync.Mutex
+	pendingMutex  sync.Mutex
+	pendingWrites int
+	writesDone    = sync.NewCond(&pendingMutex)
+)
+
+/**
+Encodes a value as json and writes it to the file in the given path. Panics if the file can't be written.
+The content is written to a temporary file within the same directory first, which then replaces the file.
+Thus readers and crashes never encounter a partially written file.
+The duration and size of the write are recorded (see ObserveStorage).
+ */
+func writeJson(path string, value interface{}) {
+	start := time.Now()
+	trackWrite(1)
+	defer trackWrite(-1)
+	var buffer bytes.Buffer
+	if err := json.NewEncoder(&buffer).Encode(value); err != nil {
 		panic(err)
 	}
-	defer file.Close()
-	encode := json.NewEncoder(file)
-	if err := encode.Encode(entries); err != nil {
+	storageMutex.Lock()
+	defer storageMutex.Unlock()
+	if err := writeFileAtomic(path, buffer.Bytes()); err != nil {
 		panic(err)
 	}
+	ObserveStorage("write", path, time.Since(start), buffer.Len())
+}
+
+func writeFileAtomic(path string, content []byte) error {
+	file, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name()) // fails once the file was renamed
+	if _, err := file.Write(content); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(file.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
 }
 
 /**
-Tries to read a file in the given path and return its raw content.
+Waits until all pending writes of data files are finished, including the ones queued behind the running write.
+Called on shutdown, thus the process never stops in the middle of one.
+ */
+func FlushStorage() {
+	pendingMutex.Lock()
+	defer pendingMutex.Unlock()
+	for pendingWrites > 0 {
+		writesDone.Wait()
+	}
+}
+
+func trackWrite(delta int) {
+	pendingMutex.Lock()
+	defer pendingMutex.Unlock()
+	pendingWrites += delta
+	if pendingWrites == 0 {
+		writesDone.Broadcast()
+	}
+}
+
+/**
+Tries to read a file in the given path and return its raw content. The duration and size of the read are recorded (see ObserveStorage).
  */
 func readFile(path string) []byte {
+	start := time.Now()
 	raw, err := ioutil.ReadFile(path)
-	if err != nil {
-		fmt.Println(err.Error())
-		fmt.Println("This may not be a problem if no entries exist so far.")
+	defer func() { ObserveStorage("read", path, time.Since(start), len(raw)) }()
+	if os.IsNotExist(err) {
+		slog.Debug("File not found, this may not be a problem if no entries exist so far", "path", path)
+		return nil
+	} else if err != nil {
+		slog.Error("File could not be read", "path", path, "error", err)
 		return nil
 	}
 	return raw
 }
+
+/**
+Checks whether the data files (users.json and entries.json) can be read and contain valid json and whether their directories are writable.
+Files that don't exist yet are fine, since they are created on first use. Returns the first problem that was found.
+ */
+func CheckStorage() error {
+	for _, path := range []string{config.USERS_FILE_PATH, config.ENTRIES_FILE_PATH} {
+		raw, err := ioutil.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if !json.Valid(raw) {
+			return fmt.Errorf("%v contains invalid json", filepath.Base(path))
+		}
+	}
+	for _, path := range []string{config.USERS_FILE_PATH, config.ENTRIES_FILE_PATH} {
+		file, err := ioutil.TempFile(filepath.Dir(path), ".check-*")
+		if err != nil {
+			return err
+		}
+		file.Close()
+		if err := os.Remove(file.Name()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -5,8 +5,10 @@ import (
 	"github.com/stretchr/testify/assert"
 	"unicode/utf8"
 	"os"
+	"io/ioutil"
 	"encoding/json"
 	"path/filepath"
+	"time"
 	"github.com/kherud/goblog/config"
 	"github.com/kherud/goblog/backend/models"
 )
@@ -16,7 +18,26 @@ func TestMain(m *testing.M) {
 	config.ENTRIES_FILE_PATH = config.ENTRIES_TEST_PATH
 	config.MIN_USERNAME_LENGTH = 6
 	config.MIN_PASSWORD_LENGTH = 8
-	os.Exit(m.Run())
+	config.SPAM_FILE_PATH = config.SPAM_TEST_PATH
+	config.SUBSCRIPTIONS_FILE_PATH = config.SUBSCRIPTIONS_TEST_PATH
+	config.DIGEST_FILE_PATH = config.DIGEST_TEST_PATH
+	config.INDEX_FILE_PATH = config.INDEX_TEST_PATH
+	config.TAGS_FILE_PATH = config.TAGS_TEST_PATH
+	config.CATEGORIES_FILE_PATH = config.CATEGORIES_TEST_PATH
+	config.MEDIA_FILE_PATH = config.MEDIA_TEST_FILE_PATH
+	config.MEDIA_PATH = config.MEDIA_TEST_PATH
+	config.SETTINGS_FILE_PATH = config.SETTINGS_TEST_PATH
+	code := m.Run()
+	os.Remove(config.SPAM_TEST_PATH)
+	os.Remove(config.SUBSCRIPTIONS_TEST_PATH)
+	os.Remove(config.DIGEST_TEST_PATH)
+	os.Remove(config.INDEX_TEST_PATH)
+	os.Remove(config.TAGS_TEST_PATH)
+	os.Remove(config.CATEGORIES_TEST_PATH)
+	os.Remove(config.MEDIA_TEST_FILE_PATH)
+	os.RemoveAll(config.MEDIA_TEST_PATH)
+	os.Remove(config.SETTINGS_TEST_PATH)
+	os.Exit(code)
 }
 
 func TestGetUsersSafe(t *testing.T) {
@@ -107,3 +128,67 @@ func TestSaveEntriesJson(t *testing.T) {
 	os.Remove(config.TEST_TEMP_PATH)
 	config.USERS_FILE_PATH = filepath.Join("test_data", "entries.json")
 }
+
+func TestCheckStorage(t *testing.T) {
+	assert.NoError(t, CheckStorage())
+	config.ENTRIES_FILE_PATH = config.TEST_TEMP_PATH
+	defer func() { config.ENTRIES_FILE_PATH = config.ENTRIES_TEST_PATH }()
+	assert.NoError(t, CheckStorage()) // doesn't exist yet
+	ioutil.WriteFile(config.TEST_TEMP_PATH, []byte("[{"), 0644)
+	defer os.Remove(config.TEST_TEMP_PATH)
+	err := CheckStorage()
+	assert.Error(t, err)
+	assert.EqualValues(t, err.Error(), "test.json contains invalid json")
+	config.ENTRIES_FILE_PATH = filepath.Join("test_data", "missing", "entries.json")
+	assert.Error(t, CheckStorage())
+}
+
+func TestWriteJsonAtomic(t *testing.T) {
+	defer os.Remove(config.TEST_TEMP_PATH)
+	writeJson(config.TEST_TEMP_PATH, []string{"first"})
+	writeJson(config.TEST_TEMP_PATH, []string{"second"})
+	FlushStorage()
+	assert.EqualValues(t, string(readFile(config.TEST_TEMP_PATH)), "[\"second\"]\n")
+	leftovers, _ := filepath.Glob(filepath.Join("test_data", ".test.json.tmp-*"))
+	assert.Empty(t, leftovers)
+	defer func() { assert.NotNil(t, recover()) }()
+	writeJson(filepath.Join("test_data", "missing", "test.json"), []string{})
+}
+
+func TestFlushStorage(t *testing.T) {
+	paths := []string{filepath.Join("test_data", "flush1.json"), filepath.Join("test_data", "flush2.json")}
+	defer func() {
+		for _, path := range paths {
+			os.Remove(path)
+		}
+	}()
+	storageMutex.Lock() // a running write, the others are queued behind it
+	for _, path := range paths {
+		go writeJson(path, []string{path})
+	}
+	for idx := 0; idx < 100 && pendingCount() < len(paths); idx++ {
+		time.Sleep(time.Millisecond)
+	}
+	flushed := make(chan bool)
+	go func() {
+		FlushStorage()
+		close(flushed)
+	}()
+	select {
+	case <-flushed:
+		t.Error("flushed before the queued writes finished")
+	case <-time.After(20 * time.Millisecond):
+	}
+	storageMutex.Unlock()
+	<-flushed
+	for _, path := range paths {
+		assert.EqualValues(t, string(readFile(path)), "[\""+path+"\"]\n")
+	}
+	assert.EqualValues(t, pendingCount(), 0)
+}
+
+func pendingCount() int {
+	pendingMutex.Lock()
+	defer pendingMutex.Unlock()
+	return pendingWrites
+}
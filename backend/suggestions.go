@@ -0,0 +1,154 @@
+package backend
+
+import (
+	"sort"
+	"strings"
+	"github.com/kherud/goblog/backend/models"
+	"github.com/kherud/goblog/util"
+)
+
+/**
+An existing keyword together with its slug (see util.Slugify) and the number of posts it is used by.
+ */
+type KeywordCount struct {
+	Keyword string `json:"keyword"`
+	Slug    string `json:"slug"`
+	Count   int    `json:"count"`
+}
+
+/**
+Suggestions for a partially entered keyword or search query.
+ */
+type Suggestions struct {
+	Keywords []KeywordCount `json:"keywords"`
+	Titles   []string       `json:"titles"`
+}
+
+/**
+Aggregates the keywords of all passed posts. Keywords with the same slug (e.g. "Golang" and "golang" or "Web Dev" and "web-dev")
+are counted together and represented by their most frequent spelling.
+Every keyword is counted once per post. The result is ordered by usage, equally used keywords alphabetically.
+ */
+func CountKeywords(entries []models.Entry) []KeywordCount {
+	counts := map[string]int{}
+	spellings := map[string]map[string]int{}
+	var order []string
+	for _, entry := range entries {
+		counted := map[string]bool{}
+		for _, keyword := range entry.Keywords {
+			keyword = strings.TrimSpace(keyword)
+			slug := util.Slugify(keyword)
+			if slug == "" {
+				continue
+			}
+			if spellings[slug] == nil {
+				spellings[slug] = map[string]int{}
+				order = append(order, slug)
+			}
+			spellings[slug][keyword]++
+			if !counted[slug] {
+				counted[slug] = true
+				counts[slug]++
+			}
+		}
+	}
+	result := make([]KeywordCount, 0, len(order))
+	for _, slug := range order {
+		result = append(result, KeywordCount{Keyword: preferredSpelling(spellings[slug]), Slug: slug, Count: counts[slug]})
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return util.FoldText(result[i].Keyword) < util.FoldText(result[j].Keyword)
+	})
+	return result
+}
+
+/**
+Returns up to limit existing keywords that match a partially entered keyword (param: prefix), ignoring case and accents.
+Keywords starting with the prefix are suggested before keywords containing a word starting with it.
+ */
+func SuggestKeywords(prefix string, limit int) []KeywordCount {
+	prefix = util.FoldText(strings.TrimSpace(prefix))
+	if prefix == "" {
+		return nil
+	}
+	var starting, containing []KeywordCount
+	for _, keyword := range CountKeywords(GetEntries()) {
+		folded := util.FoldText(keyword.Keyword)
+		if strings.HasPrefix(folded, prefix) {
+			starting = append(starting, keyword)
+		} else if hasWordPrefix(folded, prefix) {
+			containing = append(containing, keyword)
+		}
+	}
+	return limitKeywords(append(starting, containing...), limit)
+}
+
+/**
+Returns up to limit titles of posts that match a partially entered search query, ignoring case and accents.
+Titles starting with the query are suggested before titles containing it, otherwise the newest posts come first.
+ */
+func SuggestTitles(query string, limit int) []string {
+	query = util.FoldText(strings.TrimSpace(query))
+	if query == "" {
+		return nil
+	}
+	var starting, containing []string
+	for _, entry := range GetEntries() {
+		folded := util.FoldText(entry.Title)
+		if strings.HasPrefix(folded, query) {
+			starting = append(starting, entry.Title)
+		} else if strings.Contains(folded, query) {
+			containing = append(containing, entry.Title)
+		}
+	}
+	titles := append(starting, containing...)
+	if limit >= 0 && len(titles) > limit {
+		titles = titles[:limit]
+	}
+	return titles
+}
+
+/**
+Assembles the keyword and title suggestions for a partially entered text. Contains empty slices if nothing matches.
+ */
+func Suggest(text string, limit int) Suggestions {
+	suggestions := Suggestions{Keywords: []KeywordCount{}, Titles: []string{}}
+	suggestions.Keywords = append(suggestions.Keywords, SuggestKeywords(text, limit)...)
+	suggestions.Titles = append(suggestions.Titles, SuggestTitles(text, limit)...)
+	return suggestions
+}
+
+/**
+Returns the most frequent spelling of a keyword, alphabetically first one if multiple are equally frequent.
+ */
+func preferredSpelling(spellings map[string]int) (preferred string) {
+	best := 0
+	for spelling, count := range spellings {
+		if count > best || (count == best && spelling < preferred) {
+			preferred, best = spelling, count
+		}
+	}
+	return
+}
+
+/**
+Checks whether any word of a folded text starts with the passed prefix.
+ */
+func hasWordPrefix(text, prefix string) bool {
+	for _, word := range util.FoldedWords(text) {
+		if strings.HasPrefix(word, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func limitKeywords(keywords []KeywordCount, limit int) []KeywordCount {
+	if limit >= 0 && len(keywords) > limit {
+		return keywords[:limit]
+	}
+	return keywords
+}
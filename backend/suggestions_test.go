@@ -0,0 +1,50 @@
+package backend
+
+import (
+	"testing"
+	"os"
+	"path/filepath"
+	"github.com/stretchr/testify/assert"
+	"github.com/kherud/goblog/config"
+	"github.com/kherud/goblog/backend/models"
+)
+
+func TestCountKeywords(t *testing.T) {
+	entries := []models.Entry{
+		{Keywords: []string{"golang", "Web"}},
+		{Keywords: []string{"Golang", " golang ", "Gölang"}},
+		{Keywords: []string{"golang", "", "web", "Api"}},
+		{},
+	}
+	assert.EqualValues(t, []KeywordCount{{Keyword: "golang", Slug: "golang", Count: 3}, {Keyword: "Web", Slug: "web", Count: 2},
+		{Keyword: "Api", Slug: "api", Count: 1}}, CountKeywords(entries))
+	assert.Empty(t, CountKeywords(nil))
+}
+
+func TestSuggestKeywords(t *testing.T) {
+	assert.EqualValues(t, []KeywordCount{{Keyword: "asd", Slug: "asd", Count: 2}}, SuggestKeywords("AS", 10))
+	assert.Len(t, SuggestKeywords("", 10), 0)
+	assert.Len(t, SuggestKeywords("qqq", 10), 0)
+	config.ENTRIES_FILE_PATH = config.TEST_TEMP_PATH
+	saveEntriesJson([]models.Entry{{Keywords: []string{"web development", "go"}}, {Keywords: []string{"developer", "golang"}}})
+	assert.EqualValues(t, []KeywordCount{{Keyword: "developer", Slug: "developer", Count: 1},
+		{Keyword: "web development", Slug: "web-development", Count: 1}},
+		SuggestKeywords("dev", 10))
+	assert.Len(t, SuggestKeywords("go", 1), 1)
+	os.Remove(config.TEST_TEMP_PATH)
+	config.ENTRIES_FILE_PATH = filepath.Join("test_data", "entries.json")
+}
+
+func TestSuggestTitles(t *testing.T) {
+	assert.EqualValues(t, []string{"Post #41", "Post #4"}, SuggestTitles("post #4", 10))
+	assert.EqualValues(t, []string{"Hi friend"}, SuggestTitles("FRIEND", 10))
+	assert.Len(t, SuggestTitles("post", 3), 3)
+	assert.Empty(t, SuggestTitles(" ", 3))
+}
+
+func TestSuggest(t *testing.T) {
+	suggestions := Suggest("qqq", 10)
+	assert.NotNil(t, suggestions.Keywords)
+	assert.NotNil(t, suggestions.Titles)
+	assert.Len(t, Suggest("h", 10).Titles, 1)
+}
@@ -0,0 +1,225 @@
+package backend
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"github.com/kherud/goblog/backend/models"
+	"github.com/kherud/goblog/util"
+)
+
+/**
+A tag together with the number of posts using it and its weight (1 - 5) within the tag cloud.
+ */
+type TagInfo struct {
+	models.Tag
+	Count  int
+	Weight int
+}
+
+/**
+Returns all tags: the keywords of all posts grouped by their slug and tags that only exist as description.
+Names and descriptions are taken from the tags.json file, otherwise the most frequent spelling of a keyword is used.
+Tags are ordered by usage, equally used tags alphabetically.
+ */
+func ListTags() []TagInfo {
+	var tags []TagInfo
+	stored := GetTags()
+	for _, keyword := range CountKeywords(GetEntries()) {
+		tag := TagInfo{Tag: models.Tag{Name: keyword.Keyword, Slug: keyword.Slug}, Count: keyword.Count}
+		if idx := findTag(stored, keyword.Slug); idx >= 0 {
+			tag.Name, tag.Description = stored[idx].Name, stored[idx].Description
+		}
+		tags = append(tags, tag)
+	}
+	for _, tag := range stored {
+		if findTagInfo(tags, tag.Slug) < 0 {
+			tags = append(tags, TagInfo{Tag: tag})
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Slug < tags[j].Slug
+	})
+	return tags
+}
+
+/**
+Returns a single tag by its slug. If the tag is neither used nor described an error is returned.
+ */
+func GetTag(slug string) (TagInfo, error) {
+	tags := ListTags()
+	if idx := findTagInfo(tags, slug); idx >= 0 {
+		return tags[idx], nil
+	}
+	return TagInfo{}, errors.New("tag not found")
+}
+
+/**
+Returns all used tags in alphabetical order weighted by their usage for the tag cloud.
+The weight scales logarithmically from 1 (least used) to 5 (most used).
+ */
+func TagCloud() (cloud []TagInfo) {
+	maximum := 0
+	for _, tag := range ListTags() {
+		if tag.Count > 0 {
+			cloud = append(cloud, tag)
+			maximum = int(math.Max(float64(maximum), float64(tag.Count)))
+		}
+	}
+	for idx, tag := range cloud {
+		cloud[idx].Weight = 1
+		if maximum > 1 {
+			cloud[idx].Weight += int(math.Round(4 * math.Log(float64(tag.Count)) / math.Log(float64(maximum))))
+		}
+	}
+	sort.SliceStable(cloud, func(i, j int) bool {
+		return util.FoldText(cloud[i].Name) < util.FoldText(cloud[j].Name)
+	})
+	return
+}
+
+/**
+Filters a slice of posts by a tag (param: slug). The filtered slice keeps the original order.
+ */
+func FilterPostsByTag(entries []models.Entry, slug string) (result []models.Entry) {
+	for _, entry := range entries {
+		for _, keyword := range entry.Keywords {
+			if util.Slugify(keyword) == slug {
+				result = append(result, entry)
+				break
+			}
+		}
+	}
+	return
+}
+
+/**
+Renames and describes a tag by parsing the POST form of an http(s) request (tag: slug, name, description) if the request is authenticated by an admin.
+All posts using the tag are rewritten to the new name in one step.
+Renaming a tag to the name of another existing tag is rejected, those tags have to be merged instead.
+Returns a string that is determined to be displayed in the frontend. If it is empty everything went well.
+ */
+func UpdateTag(r *http.Request) string {
+	user, loggedIn := CheckAuthentication(r)
+	if err := r.ParseForm(); err != nil || !loggedIn {
+		return "Something went wrong.\n"
+	} else if !user.Admin {
+		return "Only admins can change tags.\n"
+	}
+	tag, err := GetTag(r.FormValue("tag"))
+	if err != nil {
+		return "The tag could not be found.\n"
+	}
+	name := strings.TrimSpace(r.FormValue("name"))
+	slug := util.Slugify(name)
+	if slug == "" {
+		return "The name must contain at least one letter or digit.\n"
+	}
+	if _, err := GetTag(slug); err == nil && slug != tag.Slug {
+		return "A tag with this name already exists. Merge the tags instead.\n"
+	}
+	renameKeywords(tag.Slug, name)
+	tags := removeTag(GetTags(), tag.Slug)
+	saveTagsJson(append(tags, models.Tag{Name: name, Slug: slug, Description: strings.TrimSpace(r.FormValue("description"))}))
+	return ""
+}
+
+/**
+Merges a tag into another by parsing the POST form of an http(s) request (source and target: slugs) if the request is authenticated by an admin.
+All posts using the source tag are rewritten to use the target tag instead. Posts using both only keep the target.
+The target keeps its description unless it has none.
+Returns a string that is determined to be displayed in the frontend. If it is empty everything went well.
+ */
+func MergeTags(r *http.Request) string {
+	user, loggedIn := CheckAuthentication(r)
+	if err := r.ParseForm(); err != nil || !loggedIn {
+		return "Something went wrong.\n"
+	} else if !user.Admin {
+		return "Only admins can change tags.\n"
+	}
+	source, sourceErr := GetTag(r.FormValue("source"))
+	target, targetErr := GetTag(r.FormValue("target"))
+	if sourceErr != nil || targetErr != nil {
+		return "The tag could not be found.\n"
+	}
+	if source.Slug == target.Slug {
+		return "A tag can't be merged into itself.\n"
+	}
+	renameKeywords(source.Slug, target.Name)
+	if target.Description == "" {
+		target.Description = source.Description
+	}
+	tags := removeTag(removeTag(GetTags(), source.Slug), target.Slug)
+	saveTagsJson(append(tags, target.Tag))
+	return ""
+}
+
+/**
+Replaces all keywords with the passed slug by a new name within all posts and saves the affected posts.
+Keywords that would occur twice within a post afterwards are only kept once.
+ */
+func renameKeywords(slug, name string) {
+	entries := GetEntries()
+	var changed []uint32
+	for idx, entry := range entries {
+		var keywords []string
+		modified := false
+		for _, keyword := range entry.Keywords {
+			if util.Slugify(keyword) == slug {
+				keyword, modified = name, true
+			}
+			if !containsKeyword(keywords, keyword) {
+				keywords = append(keywords, keyword)
+			}
+		}
+		if modified {
+			entries[idx].Keywords = keywords
+			changed = append(changed, entry.Id)
+		}
+	}
+	if len(changed) > 0 {
+		saveEntriesIndexed(entries, changed...)
+	}
+}
+
+/**
+Checks whether a slice of keywords contains a keyword with the same slug.
+ */
+func containsKeyword(keywords []string, keyword string) bool {
+	for _, existing := range keywords {
+		if util.Slugify(existing) == util.Slugify(keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+func removeTag(tags []models.Tag, slug string) []models.Tag {
+	if idx := findTag(tags, slug); idx >= 0 {
+		return append(tags[:idx], tags[idx+1:]...)
+	}
+	return tags
+}
+
+func findTag(tags []models.Tag, slug string) int {
+	for idx, tag := range tags {
+		if tag.Slug == slug {
+			return idx
+		}
+	}
+	return -1
+}
+
+func findTagInfo(tags []TagInfo, slug string) int {
+	for idx, tag := range tags {
+		if tag.Slug == slug {
+			return idx
+		}
+	}
+	return -1
+}
@@ -0,0 +1,140 @@
+package backend
+
+import (
+	"testing"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"github.com/stretchr/testify/assert"
+	"github.com/kherud/goblog/config"
+	"github.com/kherud/goblog/backend/models"
+)
+
+var tagTestEntries = []models.Entry{
+	{Id: 1, Title: "First", Keywords: []string{"Go", "web"}},
+	{Id: 2, Title: "Second", Keywords: []string{"golang", "Web"}},
+	{Id: 3, Title: "Third", Keywords: []string{"go", "Web Development"}},
+}
+
+func useTagTestEntries() {
+	config.ENTRIES_FILE_PATH = config.TEST_TEMP_PATH
+	config.USERS_FILE_PATH = config.USERS_TEST_PATH // tests of other files may leave another file behind
+	saveEntriesJson(tagTestEntries)
+	os.Remove(config.TAGS_TEST_PATH)
+}
+
+func resetTagTestEntries() {
+	os.Remove(config.TEST_TEMP_PATH)
+	os.Remove(config.TAGS_TEST_PATH)
+	config.ENTRIES_FILE_PATH = filepath.Join("test_data", "entries.json")
+}
+
+func tagRequest(form url.Values, loggedIn bool) *http.Request {
+	req := &http.Request{Form: form, Header: http.Header{}}
+	if loggedIn {
+		req.AddCookie(&http.Cookie{Name: "Session", Value: "Konstantin#Test"})
+	}
+	return req
+}
+
+// revokes the admin rights of the test user 'Konstantin', the returned function restores them
+func revokeAdmin() func() {
+	raw, _ := ioutil.ReadFile(config.USERS_FILE_PATH)
+	users := GetUsers()
+	users[0].Admin = false
+	saveUsersJson(users)
+	return func() { ioutil.WriteFile(config.USERS_FILE_PATH, raw, 0644) }
+}
+
+func TestListTags(t *testing.T) {
+	useTagTestEntries()
+	defer resetTagTestEntries()
+	saveTagsJson([]models.Tag{{Name: "Golang", Slug: "golang", Description: "The language"}, {Name: "Rust", Slug: "rust"}})
+	tags := ListTags()
+	assert.Len(t, tags, 5)
+	assert.EqualValues(t, TagInfo{Tag: models.Tag{Name: "Web", Slug: "web"}, Count: 2}, tags[1])
+	assert.EqualValues(t, TagInfo{Tag: models.Tag{Name: "Golang", Slug: "golang", Description: "The language"}, Count: 1}, tags[2])
+	assert.EqualValues(t, TagInfo{Tag: models.Tag{Name: "Rust", Slug: "rust"}}, tags[4])
+	tag, err := GetTag("web-development")
+	assert.NoError(t, err)
+	assert.EqualValues(t, tag.Name, "Web Development")
+	_, err = GetTag("java")
+	assert.Error(t, err)
+}
+
+func TestTagCloud(t *testing.T) {
+	useTagTestEntries()
+	defer resetTagTestEntries()
+	saveTagsJson([]models.Tag{{Name: "Rust", Slug: "rust"}})
+	cloud := TagCloud()
+	assert.Len(t, cloud, 4)
+	assert.EqualValues(t, cloud[0].Slug, "go")
+	assert.EqualValues(t, cloud[0].Weight, 5)
+	assert.EqualValues(t, cloud[1].Slug, "golang")
+	assert.EqualValues(t, cloud[1].Weight, 1)
+}
+
+func TestFilterPostsByTag(t *testing.T) {
+	assert.Len(t, FilterPostsByTag(tagTestEntries, "web"), 2)
+	assert.Len(t, FilterPostsByTag(tagTestEntries, "web-development"), 1)
+	assert.Empty(t, FilterPostsByTag(tagTestEntries, "java"))
+}
+
+func TestUpdateTag(t *testing.T) {
+	useTagTestEntries()
+	defer resetTagTestEntries()
+	assert.EqualValues(t, UpdateTag(tagRequest(url.Values{"tag": {"web"}, "name": {"Internet"}}, false)), "Something went wrong.\n")
+	assert.EqualValues(t, UpdateTag(tagRequest(url.Values{"tag": {"java"}, "name": {"Java"}}, true)), "The tag could not be found.\n")
+	assert.EqualValues(t, UpdateTag(tagRequest(url.Values{"tag": {"web"}, "name": {" ?! "}}, true)),
+		"The name must contain at least one letter or digit.\n")
+	assert.EqualValues(t, UpdateTag(tagRequest(url.Values{"tag": {"web"}, "name": {"Golang"}}, true)),
+		"A tag with this name already exists. Merge the tags instead.\n")
+	assert.Empty(t, UpdateTag(tagRequest(url.Values{"tag": {"web"}, "name": {"Internet"}, "description": {"All about it"}}, true)))
+	entries := GetEntries()
+	assert.EqualValues(t, entries[0].Keywords, []string{"Go", "Internet"})
+	assert.EqualValues(t, entries[1].Keywords, []string{"golang", "Internet"})
+	assert.EqualValues(t, entries[2].Keywords, []string{"go", "Web Development"})
+	tag, err := GetTag("internet")
+	assert.NoError(t, err)
+	assert.EqualValues(t, tag.Description, "All about it")
+	assert.EqualValues(t, tag.Count, 2)
+	_, err = GetTag("web")
+	assert.Error(t, err)
+	// changing the spelling only
+	assert.Empty(t, UpdateTag(tagRequest(url.Values{"tag": {"go"}, "name": {"GO"}}, true)))
+	assert.EqualValues(t, GetEntries()[2].Keywords, []string{"GO", "Web Development"})
+}
+
+func TestUpdateTagNoAdmin(t *testing.T) {
+	useTagTestEntries()
+	defer resetTagTestEntries()
+	defer revokeAdmin()()
+	assert.EqualValues(t, UpdateTag(tagRequest(url.Values{"tag": {"web"}, "name": {"Internet"}}, true)), "Only admins can change tags.\n")
+	assert.EqualValues(t, MergeTags(tagRequest(url.Values{"source": {"golang"}, "target": {"go"}}, true)), "Only admins can change tags.\n")
+	assert.EqualValues(t, GetEntries()[0].Keywords, tagTestEntries[0].Keywords)
+	assert.EqualValues(t, GetEntries()[1].Keywords, tagTestEntries[1].Keywords)
+}
+
+func TestMergeTags(t *testing.T) {
+	useTagTestEntries()
+	defer resetTagTestEntries()
+	saveTagsJson([]models.Tag{{Name: "Golang", Slug: "golang", Description: "The language"}})
+	assert.EqualValues(t, MergeTags(tagRequest(url.Values{"source": {"golang"}, "target": {"go"}}, false)), "Something went wrong.\n")
+	assert.EqualValues(t, MergeTags(tagRequest(url.Values{"source": {"java"}, "target": {"go"}}, true)), "The tag could not be found.\n")
+	assert.EqualValues(t, MergeTags(tagRequest(url.Values{"source": {"go"}, "target": {"go"}}, true)),
+		"A tag can't be merged into itself.\n")
+	assert.Empty(t, MergeTags(tagRequest(url.Values{"source": {"golang"}, "target": {"go"}}, true)))
+	entries := GetEntries()
+	assert.EqualValues(t, entries[1].Keywords, []string{"Go", "Web"})
+	tag, err := GetTag("go")
+	assert.NoError(t, err)
+	assert.EqualValues(t, tag.Count, 3)
+	assert.EqualValues(t, tag.Description, "The language")
+	_, err = GetTag("golang")
+	assert.Error(t, err)
+	// posts using both tags only keep the target
+	assert.Empty(t, MergeTags(tagRequest(url.Values{"source": {"web-development"}, "target": {"go"}}, true)))
+	assert.EqualValues(t, GetEntries()[2].Keywords, []string{"go"})
+}
@@ -3,7 +3,9 @@ package backend
 import (
 	"fmt"
 	"errors"
+	"strings"
 	"net/http"
+	"net/mail"
 	"unicode/utf8"
 	"github.com/kherud/goblog/util"
 	"github.com/kherud/goblog/backend/models"
@@ -37,6 +39,19 @@ func GetUser(username string) (user models.User, err error) {
 	return models.User{}, errors.New("user not found")
 }
 
+/**
+Returns a user by his id by iterating over all existing users.
+If the account is not found an error message and empty instance of User is returned.
+ */
+func getUserById(id uint32) (models.User, error) {
+	for _, user := range GetUsers() {
+		if user.Id == id {
+			return user, nil
+		}
+	}
+	return models.User{}, errors.New("user not found")
+}
+
 /**
 Validates a transferred username and password by iterating over all existing users and comparing credentials.
 Returns a boolean that represents the validity of the credentials.
@@ -181,3 +196,31 @@ func ChangePassword(r *http.Request) string {
 		return "Something went wrong.\n"
 	}
 }
+
+/**
+Changes the email address and notification setting (off, instant or digest) of the currently authenticated account by parsing the POST form of an http(s) request.
+Returns a string that is determined to be displayed in the frontend. If the string is empty everything went well otherwise it contains an appropriate error message.
+ */
+func ChangeNotifications(r *http.Request) string {
+	user, loggedIn := CheckAuthentication(r)
+	if err := r.ParseForm(); err == nil && loggedIn {
+		email, notifications := strings.TrimSpace(r.FormValue("email")), r.FormValue("notifications")
+		if notifications != NotifyInstant && notifications != NotifyDigest {
+			notifications = ""
+		}
+		if email != "" {
+			address, err := mail.ParseAddress(email)
+			if err != nil {
+				return "Invalid email address.\n"
+			}
+			email = address.Address
+		} else if notifications != "" {
+			return "Notifications require an email address.\n"
+		}
+		user.Email, user.Notifications = email, notifications
+		saveUser(user)
+		return ""
+	} else {
+		return "Something went wrong.\n"
+	}
+}
@@ -228,6 +228,50 @@ func TestChangePasswordValidForm(t *testing.T) {
 	saveUsersJson(users)
 }
 
+func TestChangeNotificationsInvalid(t *testing.T) {
+	tests := []struct {
+		Params url.Values
+	}{{Params: url.Values{"email": {"author@example.com"}, "notifications": {"instant"}}},
+		{Params: url.Values{"email": {"invalid"}, "notifications": {"instant"}}},
+		{Params: url.Values{"email": {""}, "notifications": {"digest"}}},
+	}
+	for idx, test := range tests {
+		req := &http.Request{
+			Form:   test.Params,
+			Header: http.Header{},
+		}
+		// check login validation in first case
+		if idx > 0 {
+			cookie := &http.Cookie{Name: "Session", Value: "Konstantin#Test"}
+			req.AddCookie(cookie)
+		}
+		err := ChangeNotifications(req)
+		assert.NotEmpty(t, err)
+	}
+	user, _ := GetUser("Konstantin")
+	assert.Empty(t, user.Email)
+}
+
+func TestChangeNotificationsValidForm(t *testing.T) {
+	req := &http.Request{
+		Form:   url.Values{"email": {" Author <author@example.com> "}, "notifications": {"digest"}},
+		Header: http.Header{},
+	}
+	cookie := &http.Cookie{Name: "Session", Value: "Konstantin#Test"}
+	req.AddCookie(cookie)
+	err := ChangeNotifications(req)
+	assert.Empty(t, err)
+	user, _ := GetUser("Konstantin")
+	assert.EqualValues(t, user.Email, "author@example.com")
+	assert.EqualValues(t, user.Notifications, NotifyDigest)
+	req.Form = url.Values{"email": {""}, "notifications": {"unknown"}}
+	err = ChangeNotifications(req)
+	assert.Empty(t, err)
+	user, _ = GetUser("Konstantin")
+	assert.Empty(t, user.Email)
+	assert.Empty(t, user.Notifications)
+}
+
 func testFileExistsGetContent(t *testing.T) []models.User {
 	_, err := os.Stat(config.TEST_TEMP_PATH)
 	assert.True(t, err == nil)
@@ -3,20 +3,145 @@ package config
 import "path/filepath"
 
 var (
-	DATA_PATH           = filepath.Join(".", "backend", "data")
-	USERS_FILE_PATH     = filepath.Join("backend", "data", "users.json")
-	ENTRIES_FILE_PATH   = filepath.Join("backend", "data", "entries.json")
-	USERS_TEST_PATH     = filepath.Join("test_data", "users.json")
-	ENTRIES_TEST_PATH   = filepath.Join("test_data", "entries.json")
-	TEST_TEMP_PATH      = filepath.Join("test_data", "test.json")
-	SESSION_TIME        = 15
-	POSTS_PER_REQUESTS  = 5
-	MIN_USERNAME_LENGTH = 6
-	MIN_PASSWORD_LENGTH = 8
-	STATIC_FILE_PATH    = filepath.Join("webserver", "static")
-	TEMPLATE_PATH       = filepath.Join("webserver", "templates")
-	CERT_FILE           = filepath.Join("server.crt")
-	KEY_FILE            = filepath.Join("server.key")
-	TEST_TEMPLATE_PATH  = "templates"
-	DEFAULT_PORT        = "8080"
+	DATA_PATH               = filepath.Join(".", "backend", "data")
+	USERS_FILE_PATH         = filepath.Join("backend", "data", "users.json")
+	ENTRIES_FILE_PATH       = filepath.Join("backend", "data", "entries.json")
+	SPAM_FILE_PATH          = filepath.Join("backend", "data", "spam.json")
+	SUBSCRIPTIONS_FILE_PATH = filepath.Join("backend", "data", "subscriptions.json")
+	DIGEST_FILE_PATH        = filepath.Join("backend", "data", "digest.json")
+	INDEX_FILE_PATH         = filepath.Join("backend", "data", "index.json")
+	TAGS_FILE_PATH          = filepath.Join("backend", "data", "tags.json")
+	CATEGORIES_FILE_PATH    = filepath.Join("backend", "data", "categories.json")
+	MEDIA_FILE_PATH         = filepath.Join("backend", "data", "media.json")
+	MEDIA_PATH              = filepath.Join("backend", "data", "media")
+	SETTINGS_FILE_PATH      = filepath.Join("backend", "data", "settings.json")
+	THEMES_PATH             = filepath.Join("backend", "data", "themes")
+	USERS_TEST_PATH         = filepath.Join("test_data", "users.json")
+	ENTRIES_TEST_PATH       = filepath.Join("test_data", "entries.json")
+	TEST_TEMP_PATH          = filepath.Join("test_data", "test.json")
+	SPAM_TEST_PATH          = filepath.Join("test_data", "spam.json")
+	SUBSCRIPTIONS_TEST_PATH = filepath.Join("test_data", "subscriptions.json")
+	DIGEST_TEST_PATH        = filepath.Join("test_data", "digest.json")
+	INDEX_TEST_PATH         = filepath.Join("test_data", "index.json")
+	TAGS_TEST_PATH          = filepath.Join("test_data", "tags.json")
+	CATEGORIES_TEST_PATH    = filepath.Join("test_data", "categories.json")
+	MEDIA_TEST_FILE_PATH    = filepath.Join("test_data", "media.json")
+	MEDIA_TEST_PATH         = filepath.Join("test_data", "media")
+	SETTINGS_TEST_PATH      = filepath.Join("test_data", "settings.json")
+	SESSION_TIME            = 15
+	POSTS_PER_REQUESTS      = 5
+	MAX_COMMENT_DEPTH       = 3
+	MIN_USERNAME_LENGTH     = 6
+	MIN_PASSWORD_LENGTH     = 8
+	STATIC_FILE_PATH        = filepath.Join("webserver", "static")
+	TEMPLATE_PATH           = filepath.Join("webserver", "templates")
+	CERT_FILE               = filepath.Join("server.crt")
+	KEY_FILE                = filepath.Join("server.key")
+	TEST_TEMPLATE_PATH      = "templates"
+	DEFAULT_PORT            = "8080"
+	// templates are embedded into the binary unless the development mode reads them from TEMPLATE_PATH and reloads them on changes
+	DEV_MODE               = false
+	TEMPLATE_POLL_INTERVAL = 500 // milliseconds
+	// directory containing templates/ and static/ whose files replace the embedded ones (disabled if empty)
+	OVERRIDE_PATH = ""
+	// theme that is active as long as no other one was selected, missing blocks of other themes fall back to it
+	DEFAULT_THEME = "default"
+	// comments scoring at least the threshold (0 - 1) are moved to the spam queue
+	SPAM_THRESHOLD          = 0.9
+	SPAM_MIN_SUBMIT_SECONDS = 3
+	SPAM_MAX_LINKS          = 2
+	SPAM_MIN_TRAINING       = 5
+	SPAM_BLOCKLIST          = []string{"viagra", "cialis", "casino", "payday loan", "crypto giveaway", "seo services"}
+	// anonymous comment submissions per minute and maximum burst
+	COMMENT_RATE_IP    = 5.0
+	COMMENT_BURST_IP   = 3
+	COMMENT_RATE_POST  = 30.0
+	COMMENT_BURST_POST = 10
+	// notifications are disabled as long as no smtp host is set
+	SMTP_HOST       = ""
+	SMTP_PORT       = "25"
+	SMTP_USER       = ""
+	SMTP_PASSWORD   = ""
+	SMTP_FROM       = "goblog@localhost"
+	DIGEST_INTERVAL = 24 * 60
+	// used to create absolute links, e.g. within emails. Defaults to https://localhost:<port>
+	BASE_URL = ""
+	// relevance of title and keyword matches compared to the text, approved comments are searched if enabled
+	SEARCH_TITLE_WEIGHT   = 3
+	SEARCH_KEYWORD_WEIGHT = 2
+	SEARCH_COMMENTS       = true
+	// number of words of a search result snippet and maximum number of autocomplete suggestions
+	SNIPPET_WORDS    = 30
+	SUGGESTION_LIMIT = 8
+	// format of the dates of posts and comments (see time.Format), used to store and to parse them for the archive
+	DATE_FORMAT = "02.01.2006 - 15:04"
+	// title and description of the blog used within feeds
+	BLOG_TITLE       = "DMK Blog"
+	BLOG_DESCRIPTION = "A Blog by Dzhoana Yordanova, Moritz Koch and Konstantin Herud"
+	// image shared along with links to the blog and number of words of the description of a post (Open Graph, Twitter Card)
+	META_IMAGE             = "/static/img/background.jpg"
+	META_DESCRIPTION_WORDS = 30
+	// number of words of the automatically generated excerpt of a post within previews and the assumed reading speed
+	EXCERPT_WORDS            = 55
+	READING_WORDS_PER_MINUTE = 200
+	// number of posts within a feed, whether they contain the full text or an excerpt of the passed number of words
+	FEED_ITEMS         = 20
+	FEED_FULL_CONTENT  = true
+	FEED_EXCERPT_WORDS = 50
+	// maximum number of urls of a single sitemap, larger sitemaps are split by a sitemap index
+	SITEMAP_MAX_URLS = 50000
+	// maximum size of an upload in bytes and the accepted media types (detected by their content) with their file extension.
+	// Images have to be decodable by the standard library, since their metadata is stripped on upload
+	MAX_UPLOAD_SIZE int64 = 10 << 20
+	MEDIA_TYPES           = map[string]string{
+		"image/jpeg":      ".jpg",
+		"image/png":       ".png",
+		"image/gif":       ".gif",
+		"application/pdf": ".pdf",
+		"text/plain":      ".txt",
+	}
+	// maximum widths of the variants of uploaded images (generated on demand) and the quality of re-encoded jpegs
+	IMAGE_VARIANTS = map[string]int{"thumbnail": 150, "medium": 640, "large": 1280}
+	JPEG_QUALITY   = 85
+	// maximum dimensions of uploaded images in pixels, larger ones are rejected before they are decoded
+	MAX_IMAGE_WIDTH  = 8192
+	MAX_IMAGE_HEIGHT = 8192
+	// maximum amount of frames of uploaded gifs, since all of them are decoded at once
+	MAX_GIF_FRAMES = 500
+	// rules of the robots.txt, a reference to the sitemap is appended. May be replaced by a file (see flag -robots)
+	ROBOTS_RULES = "User-agent: *\nDisallow: /?search=\nDisallow: /?more=\nDisallow: /?suggest=\n"
+	// minimum level of logged records (debug, info, warn or error) and their format (text or json)
+	LOG_LEVEL  = "info"
+	LOG_FORMAT = "text"
+	// bearer token required to read the metrics (/metrics), which are public if it is empty
+	METRICS_TOKEN = ""
+	// the readiness check fails if the certificate expires within the passed number of days, its result is reused for the passed seconds
+	CERT_EXPIRY_WARNING  = 14
+	READINESS_CACHE_TIME = 5
+	// timeouts of the web server in seconds, maximum size of request headers and the time active requests get on shutdown
+	READ_HEADER_TIMEOUT = 10
+	READ_TIMEOUT        = 30
+	WRITE_TIMEOUT       = 60
+	IDLE_TIMEOUT        = 120
+	MAX_HEADER_BYTES    = 1 << 20
+	SHUTDOWN_TIMEOUT    = 15
 )
+
+/**
+Moves all data files (users, posts, indexes, uploads, ...) to another directory, thus the blog doesn't depend on its working directory.
+ */
+func SetDataPath(path string) {
+	DATA_PATH = path
+	USERS_FILE_PATH = filepath.Join(path, "users.json")
+	ENTRIES_FILE_PATH = filepath.Join(path, "entries.json")
+	SPAM_FILE_PATH = filepath.Join(path, "spam.json")
+	SUBSCRIPTIONS_FILE_PATH = filepath.Join(path, "subscriptions.json")
+	DIGEST_FILE_PATH = filepath.Join(path, "digest.json")
+	INDEX_FILE_PATH = filepath.Join(path, "index.json")
+	TAGS_FILE_PATH = filepath.Join(path, "tags.json")
+	CATEGORIES_FILE_PATH = filepath.Join(path, "categories.json")
+	MEDIA_FILE_PATH = filepath.Join(path, "media.json")
+	MEDIA_PATH = filepath.Join(path, "media")
+	SETTINGS_FILE_PATH = filepath.Join(path, "settings.json")
+	THEMES_PATH = filepath.Join(path, "themes")
+}
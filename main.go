@@ -4,7 +4,10 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"log/slog"
 	"bufio"
+	"encoding/json"
+	"io/ioutil"
 	"github.com/kherud/goblog/config"
 	"github.com/kherud/goblog/backend"
 	"github.com/kherud/goblog/webserver"
@@ -13,23 +16,84 @@ import (
 /**
 Starting point that parses possible flags, ensures an user exists and creates one if not. Then starts the web server.
 Also ensures the storage directory and certificate files exist.
+Passing the command "reindex" (e.g. goblog reindex) only rebuilds the search index of all posts.
+The command "check" runs the readiness checks (see webserver.CheckReadiness), prints their results and exits with 1 if one fails.
  */
 func main() {
 	time := flag.Int("t", 15, "Minutes until an authentication session expires")
 	port := flag.String("p", "8080", "Port that is used for the webserver")
+	baseUrl := flag.String("url", "", "Public url of the blog used for absolute links (default https://localhost:<port>)")
+	smtpHost := flag.String("smtp", "", "Host of the smtp server used for email notifications (disabled if empty)")
+	smtpPort := flag.String("smtp-port", "25", "Port of the smtp server")
+	smtpUser := flag.String("smtp-user", "", "User of the smtp server, the password is read from GOBLOG_SMTP_PASSWORD")
+	smtpFrom := flag.String("smtp-from", "goblog@localhost", "Sender address of email notifications")
+	data := flag.String("data", config.DATA_PATH, "Directory containing all data files (users, posts, uploads, ...)")
+	cert := flag.String("cert", config.CERT_FILE, "HTTPS certificate file")
+	key := flag.String("key", config.KEY_FILE, "HTTPS key file")
+	override := flag.String("override", "", "Directory containing templates/ and static/ whose files replace the embedded ones")
+	dev := flag.Bool("dev", false, "Development mode: reads the templates from "+config.TEMPLATE_PATH+" and reloads them on changes")
+	robots := flag.String("robots", "", "File containing the rules of the robots.txt (default allows everything but search pages)")
+	logLevel := flag.String("log-level", config.LOG_LEVEL, "Minimum level of logged records (debug, info, warn or error)")
+	logFormat := flag.String("log-format", config.LOG_FORMAT, "Format of logged records (text or json)")
 	flag.Parse()
+	config.LOG_LEVEL = *logLevel
+	config.LOG_FORMAT = *logFormat
+	if err := backend.ConfigureLogging(os.Stderr); err != nil {
+		fmt.Println("Logging could not be configured:", err)
+		return
+	}
 	config.SESSION_TIME = *time
 	config.DEFAULT_PORT = *port
+	config.BASE_URL = *baseUrl
+	config.SMTP_HOST = *smtpHost
+	config.SMTP_PORT = *smtpPort
+	config.SMTP_USER = *smtpUser
+	config.SMTP_PASSWORD = os.Getenv("GOBLOG_SMTP_PASSWORD")
+	config.METRICS_TOKEN = os.Getenv("GOBLOG_METRICS_TOKEN")
+	config.SMTP_FROM = *smtpFrom
+	config.DEV_MODE = *dev
+	config.OVERRIDE_PATH = *override
+	config.CERT_FILE = *cert
+	config.KEY_FILE = *key
+	if *data != config.DATA_PATH {
+		config.SetDataPath(*data)
+	}
+	if *robots != "" {
+		rules, err := ioutil.ReadFile(*robots)
+		if err != nil {
+			slog.Error("The robots.txt rules could not be read", "error", err)
+			return
+		}
+		config.ROBOTS_RULES = string(rules)
+	}
+	if flag.Arg(0) == "check" {
+		readiness := webserver.CheckReadiness()
+		result, _ := json.MarshalIndent(readiness, "", "  ")
+		fmt.Println(string(result))
+		if readiness.Status != "ok" {
+			os.Exit(1)
+		}
+		return
+	}
+	if flag.Arg(0) == "reindex" {
+		os.MkdirAll(config.DATA_PATH, os.ModePerm)
+		fmt.Println("Indexed", backend.RebuildSearchIndex(), "posts")
+		return
+	}
 	_, certErr := os.Stat(config.CERT_FILE)
-	_, keyErr := os.Stat(config.CERT_FILE)
+	_, keyErr := os.Stat(config.KEY_FILE)
 	if certErr != nil || keyErr != nil {
-		fmt.Println("HTTPS certificate or key file could not be found.\nPlease ensure they are at the right directory.")
+		slog.Error("HTTPS certificate or key file could not be found. Please ensure they are at the right directory.",
+			"cert", config.CERT_FILE, "key", config.KEY_FILE)
 	} else {
 		os.MkdirAll(config.DATA_PATH, os.ModePerm)
-		fmt.Println("Starting webserver on port", config.DEFAULT_PORT)
-		fmt.Println("Session expiration time:", config.SESSION_TIME, "minutes")
+		slog.Info("Starting webserver", "port", config.DEFAULT_PORT, "session_minutes", config.SESSION_TIME)
 		backend.EnsureUserExists(bufio.NewReader(os.Stdin)) // inject dependency for proper testing
-		fmt.Println("Server is now running...")
+		if backend.NotificationsEnabled() {
+			slog.Info("Email notifications enabled", "smtp", config.SMTP_HOST)
+			go backend.RunNotificationDigest()
+		}
+		slog.Info("Server is now running...")
 		webserver.StartServer()
 	}
 }
@@ -0,0 +1,137 @@
+package util
+
+import (
+	"strings"
+)
+
+// Languages supported by the stemmers
+const (
+	LanguageEnglish = "en"
+	LanguageGerman  = "de"
+)
+
+// Frequent words used to guess the language of a text. Words are folded (see FoldText).
+var stopWords = map[string]map[string]bool{
+	LanguageEnglish: toSet("the", "and", "is", "are", "of", "to", "in", "that", "it", "with", "for", "this",
+		"was", "on", "be", "not", "you", "as", "have", "but"),
+	LanguageGerman: toSet("der", "die", "das", "und", "ist", "sind", "nicht", "ein", "eine", "mit", "zu", "den",
+		"von", "auf", "ich", "sie", "es", "im", "dem", "auch", "wie", "fur", "uber", "aber"),
+}
+
+/**
+Guesses the language of folded words by counting stop words. Defaults to english.
+ */
+func DetectLanguage(words []string) string {
+	english, german := 0, 0
+	for _, word := range words {
+		if stopWords[LanguageEnglish][word] {
+			english++
+		}
+		if stopWords[LanguageGerman][word] {
+			german++
+		}
+	}
+	if german > english {
+		return LanguageGerman
+	}
+	return LanguageEnglish
+}
+
+/**
+Reduces a folded word to its stem using a light suffix stripping stemmer of the passed language.
+The stems aren't necessarily real words, they only have to be equal for inflections of the same word,
+e.g. "templates" and "templating" -> "templat".
+ */
+func Stem(word, language string) string {
+	if language == LanguageGerman {
+		return stemGerman(word)
+	}
+	return stemEnglish(word)
+}
+
+/**
+Returns the distinct stems of a folded word for all supported languages.
+Used for queries, since their language can't be detected reliably.
+ */
+func Stems(word string) []string {
+	english, german := stemEnglish(word), stemGerman(word)
+	if english == german {
+		return []string{english}
+	}
+	return []string{english, german}
+}
+
+/**
+Strips plural, verb and a few derivational suffixes of english words (loosely based on the porter stemmer).
+ */
+func stemEnglish(word string) string {
+	switch {
+	case strings.HasSuffix(word, "sses"):
+		word = word[:len(word)-2]
+	case strings.HasSuffix(word, "ies") && len(word) > 4:
+		word = word[:len(word)-3] + "y"
+	case strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss") && !strings.HasSuffix(word, "us") &&
+		!strings.HasSuffix(word, "is") && len(word) > 3:
+		word = word[:len(word)-1]
+	}
+	for _, suffix := range []string{"ingly", "edly", "ing", "ed"} {
+		if stem := strings.TrimSuffix(word, suffix); stem != word && len(stem) >= 3 && strings.ContainsAny(stem, "aeiouy") {
+			word = stem
+			if last := len(word) - 1; word[last] == word[last-1] && isConsonant(word[last]) && !strings.ContainsRune("lsz", rune(word[last])) {
+				word = word[:last] // running -> run
+			}
+			break
+		}
+	}
+	for _, suffix := range []string{"fulness", "iveness", "ousness", "ational", "ization", "ness", "ment", "ful", "ly"} {
+		if stem := strings.TrimSuffix(word, suffix); stem != word && len(stem) >= 3 {
+			word = stem
+			break
+		}
+	}
+	if strings.HasSuffix(word, "e") && len(word) > 4 {
+		word = word[:len(word)-1]
+	}
+	return word
+}
+
+/**
+Strips inflectional and a few derivational suffixes of german words (loosely based on the snowball stemmer).
+Umlauts and ß are expected to be folded already.
+ */
+func stemGerman(word string) string {
+	word = stripSuffix(word, 3, "ern", "em", "er", "en", "es", "e")
+	if strings.HasSuffix(word, "s") && len(word) > 3 && strings.ContainsRune("bdfghklmnrt", rune(word[len(word)-2])) {
+		word = word[:len(word)-1]
+	}
+	word = stripSuffix(word, 3, "est", "er", "en")
+	if strings.HasSuffix(word, "st") && len(word) > 5 && strings.ContainsRune("bdfghklmnt", rune(word[len(word)-3])) {
+		word = word[:len(word)-2]
+	}
+	word = stripSuffix(word, 3, "heit", "keit") // may follow another suffix, e.g. moglichkeit
+	return stripSuffix(word, 3, "isch", "lich", "ung", "end", "ig")
+}
+
+/**
+Removes the first matching suffix if the remaining stem consists of at least minimumLength bytes.
+ */
+func stripSuffix(word string, minimumLength int, suffixes ...string) string {
+	for _, suffix := range suffixes {
+		if stem := strings.TrimSuffix(word, suffix); stem != word && len(stem) >= minimumLength {
+			return stem
+		}
+	}
+	return word
+}
+
+func isConsonant(char byte) bool {
+	return char >= 'a' && char <= 'z' && !strings.ContainsRune("aeiou", rune(char))
+}
+
+func toSet(words ...string) map[string]bool {
+	set := map[string]bool{}
+	for _, word := range words {
+		set[word] = true
+	}
+	return set
+}
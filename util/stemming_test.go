@@ -0,0 +1,39 @@
+package util
+
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStemEnglish(t *testing.T) {
+	tests := [][]string{{"templates", "templating", "template"}, {"running", "runs", "run"},
+		{"parsing", "parse", "parsed"}, {"stories", "story"}, {"kindness", "kind"}}
+	for _, inflections := range tests {
+		for _, word := range inflections {
+			assert.EqualValues(t, Stem(inflections[0], LanguageEnglish), Stem(word, LanguageEnglish), word)
+		}
+	}
+	assert.EqualValues(t, Stem("go", LanguageEnglish), "go")
+	assert.EqualValues(t, Stem("class", LanguageEnglish), "class")
+}
+
+func TestStemGerman(t *testing.T) {
+	tests := [][]string{{"zeitungen", "zeitung", "zeit"}, {"hauser", "haus"}, {"kinder", "kindern", "kind"},
+		{FoldText("möglich"), FoldText("möglichkeit")}, {"schnellsten", "schnell"}}
+	for _, inflections := range tests {
+		for _, word := range inflections {
+			assert.EqualValues(t, Stem(inflections[0], LanguageGerman), Stem(word, LanguageGerman), word)
+		}
+	}
+}
+
+func TestStems(t *testing.T) {
+	assert.EqualValues(t, Stems("go"), []string{"go"})
+	assert.Len(t, Stems("zeitungen"), 2)
+}
+
+func TestDetectLanguage(t *testing.T) {
+	assert.EqualValues(t, DetectLanguage(FoldedWords("Das ist nicht der Weg, über den ich sprach.")), LanguageGerman)
+	assert.EqualValues(t, DetectLanguage(FoldedWords("This is not the way I was talking about.")), LanguageEnglish)
+	assert.EqualValues(t, DetectLanguage(nil), LanguageEnglish)
+}
@@ -0,0 +1,67 @@
+package util
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Replacements of accented and special latin letters by their plain ascii equivalent
+var foldings = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'ā': "a", 'ă': "a", 'ą': "a",
+	'æ': "ae", 'ç': "c", 'ć': "c", 'ĉ': "c", 'ċ': "c", 'č': "c", 'ď': "d", 'đ': "d", 'ð': "d",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ē': "e", 'ĕ': "e", 'ė': "e", 'ę': "e", 'ě': "e",
+	'ĝ': "g", 'ğ': "g", 'ġ': "g", 'ģ': "g", 'ĥ': "h", 'ħ': "h",
+	'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ĩ': "i", 'ī': "i", 'ĭ': "i", 'į': "i", 'ı': "i",
+	'ĵ': "j", 'ķ': "k", 'ĺ': "l", 'ļ': "l", 'ľ': "l", 'ŀ': "l", 'ł': "l",
+	'ñ': "n", 'ń': "n", 'ņ': "n", 'ň': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'ō': "o", 'ŏ': "o", 'ő': "o", 'œ': "oe",
+	'ŕ': "r", 'ŗ': "r", 'ř': "r", 'ś': "s", 'ŝ': "s", 'ş': "s", 'š': "s", 'ß': "ss",
+	'ţ': "t", 'ť': "t", 'ŧ': "t", 'þ': "th",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ũ': "u", 'ū': "u", 'ŭ': "u", 'ů': "u", 'ű': "u", 'ų': "u",
+	'ŵ': "w", 'ý': "y", 'ÿ': "y", 'ŷ': "y", 'ź': "z", 'ż': "z", 'ž': "z",
+}
+
+/**
+Normalizes a text for comparisons that ignore case and accents, e.g. "Größe" -> "grosse".
+ */
+func FoldText(text string) string {
+	var folded strings.Builder
+	for _, char := range strings.ToLower(text) {
+		if replacement, found := foldings[char]; found {
+			folded.WriteString(replacement)
+		} else {
+			folded.WriteRune(char)
+		}
+	}
+	return folded.String()
+}
+
+/**
+Splits a text into folded words consisting of letters and digits.
+ */
+func FoldedWords(text string) []string {
+	return strings.FieldsFunc(FoldText(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+/**
+Creates an url friendly identifier of a name, e.g. "Web Development" -> "web-development".
+Letters, digits, "+" and "#" are kept (e.g. "c++"), any other characters are replaced by single hyphens.
+ */
+func Slugify(name string) string {
+	var slug strings.Builder
+	hyphen := false
+	for _, char := range FoldText(name) {
+		if unicode.IsLetter(char) || unicode.IsDigit(char) || char == '+' || char == '#' {
+			if hyphen && slug.Len() > 0 {
+				slug.WriteRune('-')
+			}
+			slug.WriteRune(char)
+			hyphen = false
+		} else {
+			hyphen = true
+		}
+	}
+	return slug.String()
+}
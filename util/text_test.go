@@ -0,0 +1,26 @@
+package util
+
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFoldText(t *testing.T) {
+	assert.EqualValues(t, FoldText("Größe"), "grosse")
+	assert.EqualValues(t, FoldText("Crème Brûlée"), "creme brulee")
+	assert.EqualValues(t, FoldText("ÆØÅ"), "aeoa")
+	assert.EqualValues(t, FoldText("Go 1.14"), "go 1.14")
+}
+
+func TestFoldedWords(t *testing.T) {
+	assert.EqualValues(t, FoldedWords("Hello, Wörld! x-ray 42"), []string{"hello", "world", "x", "ray", "42"})
+	assert.Empty(t, FoldedWords(" !?- "))
+}
+
+func TestSlugify(t *testing.T) {
+	assert.EqualValues(t, Slugify("Web Development"), "web-development")
+	assert.EqualValues(t, Slugify("  Größe -- über Äpfel! "), "grosse-uber-apfel")
+	assert.EqualValues(t, Slugify("C++"), "c++")
+	assert.EqualValues(t, Slugify("C#"), "c#")
+	assert.EqualValues(t, Slugify("?!"), "")
+}
@@ -0,0 +1,91 @@
+package webserver
+
+import (
+	"log/slog"
+	"net/http"
+	"regexp"
+	"time"
+	"github.com/kherud/goblog/backend"
+)
+
+// ids of requests passed by a reverse proxy (header X-Request-Id) are adopted if they look harmless
+var requestIdPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+/**
+Wraps a ResponseWriter and records the status code and the number of bytes of the response.
+ */
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (recorder *responseRecorder) WriteHeader(status int) {
+	if recorder.status == 0 {
+		recorder.status = status
+	}
+	recorder.ResponseWriter.WriteHeader(status)
+}
+
+func (recorder *responseRecorder) Write(content []byte) (int, error) {
+	if recorder.status == 0 {
+		recorder.status = http.StatusOK
+	}
+	written, err := recorder.ResponseWriter.Write(content)
+	recorder.bytes += written
+	return written, err
+}
+
+/**
+Allows http.ResponseController to reach the wrapped ResponseWriter.
+ */
+func (recorder *responseRecorder) Unwrap() http.ResponseWriter {
+	return recorder.ResponseWriter
+}
+
+/**
+Middleware that assigns an id to every request (returned by the header X-Request-Id) and logs the request after it was answered:
+method, path, page (first GET parameter, values are omitted since they may contain tokens), status, duration, bytes and user.
+The id is passed along with the context of the request, thus records of the backend can be correlated (see backend.WithRequestId).
+The request is counted within the metrics of its route as well (see routeName).
+ */
+func accessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := r.Header.Get("X-Request-Id")
+		if !requestIdPattern.MatchString(id) {
+			id = ""
+		}
+		r = backend.WithRequestId(r, id)
+		w.Header().Set("X-Request-Id", backend.RequestId(r.Context()))
+		var user string
+		if _, err := r.Cookie("Session"); err == nil { // determined beforehand, since the request may end the session
+			if authenticated, loggedIn := backend.CheckAuthentication(r); loggedIn {
+				user = authenticated.UserName
+			}
+		}
+		recorder := &responseRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+		duration := time.Since(start)
+		backend.ObserveRequest(routeName(r), r.Method, recorder.status, duration)
+		page, _ := firstParameter(r)
+		level := slog.LevelInfo
+		if recorder.status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		} else if r.URL.Path == "/healthz" || r.URL.Path == "/readyz" {
+			level = slog.LevelDebug // probed frequently by load balancers
+		}
+		slog.Log(r.Context(), level, "Request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"page", page,
+			"status", recorder.status,
+			"duration", duration,
+			"bytes", recorder.bytes,
+			"user", user,
+		)
+	})
+}
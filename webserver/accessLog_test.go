@@ -0,0 +1,64 @@
+package webserver
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/kherud/goblog/backend"
+)
+
+func TestAccessLog(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+	var output bytes.Buffer
+	assert.NoError(t, backend.ConfigureLogging(&output))
+	var requestId string
+	handler := accessLog(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestId = backend.RequestId(r.Context())
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("Not found"))
+	}))
+	recorder := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/?id=0", nil)
+	r.AddCookie(&http.Cookie{Name: "Session", Value: "Konstantin#Test"})
+	handler.ServeHTTP(recorder, r)
+	assert.Len(t, requestId, 16)
+	assert.EqualValues(t, recorder.Header().Get("X-Request-Id"), requestId)
+	record := output.String()
+	for _, field := range []string{"method=GET", "path=/", "page=id", "status=404", "bytes=9", "user=Konstantin", "duration=", "request_id=" + requestId} {
+		assert.True(t, strings.Contains(record, field), field)
+	}
+	assert.False(t, strings.Contains(record, "id=0"))
+}
+
+func TestAccessLogRequestId(t *testing.T) {
+	handler := accessLog(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	recorder := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Request-Id", "proxy-id.1")
+	handler.ServeHTTP(recorder, r)
+	assert.EqualValues(t, recorder.Header().Get("X-Request-Id"), "proxy-id.1")
+	recorder = httptest.NewRecorder()
+	r.Header.Set("X-Request-Id", "invalid id\nwith newline")
+	handler.ServeHTTP(recorder, r)
+	assert.Len(t, recorder.Header().Get("X-Request-Id"), 16)
+}
+
+func TestAccessLogMalformedCookie(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+	var output bytes.Buffer
+	assert.NoError(t, backend.ConfigureLogging(&output))
+	handler := accessLog(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	recorder := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/static/style.css", nil)
+	r.AddCookie(&http.Cookie{Name: "Session", Value: "broken"})
+	handler.ServeHTTP(recorder, r)
+	assert.EqualValues(t, recorder.Code, http.StatusOK)
+	record := output.String()
+	for _, field := range []string{"path=/static/style.css", "status=200", "user=\"\""} {
+		assert.True(t, strings.Contains(record, field), field)
+	}
+}
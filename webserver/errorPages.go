@@ -0,0 +1,40 @@
+package webserver
+
+import (
+	"log/slog"
+	"net/http"
+	"github.com/kherud/goblog/backend"
+)
+
+/**
+Responds with an error page (error.html) within the layout of the active theme using the passed status code, e.g. 404.
+The message describes the error, if it is empty the status text is used. If even the error page can't be rendered
+the message is returned as plain text. Returns the status code that was written.
+ */
+func renderErrorPage(w http.ResponseWriter, r *http.Request, status int, message string) (int, error) {
+	if message == "" {
+		message = http.StatusText(status)
+	}
+	entries := map[string]interface{}{"status": status, "message": message, "meta": backend.SiteMeta(r.URL)}
+	if user, found := backend.CheckAuthentication(r); found {
+		entries["user"] = user
+	}
+	tmpl, err := getTemplate("error.html")
+	if err == nil {
+		err = executeTemplate(w, tmpl, "index.html", entries, status)
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error page could not be rendered", "error", err)
+		http.Error(w, message, status)
+	}
+	return status, nil
+}
+
+/**
+Logs an error that occurred while answering a request and responds with an error page (500).
+ */
+func internalError(w http.ResponseWriter, r *http.Request, err error) (int, error) {
+	slog.ErrorContext(r.Context(), "Request could not be answered", "error", err)
+	renderErrorPage(w, r, http.StatusInternalServerError, "Something went wrong.")
+	return http.StatusInternalServerError, err
+}
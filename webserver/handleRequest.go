@@ -2,79 +2,287 @@ package webserver
 
 import (
 	"net/http"
-	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"context"
 	"html/template"
-	"path/filepath"
 	"fmt"
+	"math"
 	"strconv"
+	"strings"
+	"net/url"
+	"encoding/json"
+	"errors"
+	"time"
+	"bytes"
+	"hash/fnv"
 	"github.com/kherud/goblog/config"
 	"github.com/kherud/goblog/backend"
 	"github.com/kherud/goblog/backend/models"
 )
 
 /**
-Starts the web server using https. Declares different handlers for static files, page & login/-out requests
+Starts the web server using https and blocks until it is stopped by SIGINT or SIGTERM.
+The templates are parsed once beforehand and reloaded on changes in development mode.
+On shutdown no new connections are accepted and active requests may finish within config.SHUTDOWN_TIMEOUT seconds,
+afterwards pending writes of data files are awaited (see backend.FlushStorage), thus the process never stops in the middle of one.
  */
 func StartServer() {
-	fs := http.FileServer(http.Dir(config.STATIC_FILE_PATH))
-	http.Handle("/static/", http.StripPrefix("/static/", fs))
-	http.HandleFunc("/", returnContent)
-	http.HandleFunc("/login", loginUser)
-	http.HandleFunc("/logout", logoutUser)
-	err := http.ListenAndServeTLS(":"+config.DEFAULT_PORT, config.CERT_FILE, config.KEY_FILE, nil)
-	if err != nil {
-		log.Fatal("ListenAndServe: ", err)
+	if err := LoadTemplates(); err != nil {
+		slog.Error("Templates could not be parsed", "error", err)
+		os.Exit(1)
+	}
+	if config.DEV_MODE {
+		go watchTemplates()
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	server := newServer()
+	failed := make(chan error, 1)
+	go func() {
+		failed <- server.ListenAndServeTLS(config.CERT_FILE, config.KEY_FILE)
+	}()
+	select {
+	case err := <-failed:
+		slog.Error("ListenAndServe", "error", err)
+		os.Exit(1)
+	case <-ctx.Done():
+	}
+	slog.Info("Shutting down, draining active requests", "timeout_seconds", config.SHUTDOWN_TIMEOUT)
+	drain, cancel := context.WithTimeout(context.Background(), time.Duration(config.SHUTDOWN_TIMEOUT)*time.Second)
+	defer cancel()
+	if err := server.Shutdown(drain); err != nil {
+		slog.Warn("Active requests were aborted", "error", err)
 	}
+	backend.FlushStorage()
+	slog.Info("Server stopped")
+}
+
+/**
+Creates the web server. Declares different handlers for static files, page & login/-out requests, metrics and health checks.
+Every request is logged (see accessLog). The timeouts (see config.READ_HEADER_TIMEOUT and following) and the maximum size of
+the headers prevent slow or malicious clients from holding connections open.
+ */
+func newServer() *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/static/", serveStatic)
+	mux.HandleFunc("/", returnContent)
+	mux.HandleFunc("/login", loginUser)
+	mux.HandleFunc("/logout", logoutUser)
+	mux.HandleFunc("/metrics", serveMetrics)
+	mux.HandleFunc("/healthz", serveHealth)
+	mux.HandleFunc("/readyz", serveReadiness)
+	return &http.Server{
+		Addr:              ":" + config.DEFAULT_PORT,
+		Handler:           accessLog(mux),
+		ReadHeaderTimeout: time.Duration(config.READ_HEADER_TIMEOUT) * time.Second,
+		ReadTimeout:       time.Duration(config.READ_TIMEOUT) * time.Second,
+		WriteTimeout:      time.Duration(config.WRITE_TIMEOUT) * time.Second,
+		IdleTimeout:       time.Duration(config.IDLE_TIMEOUT) * time.Second,
+		MaxHeaderBytes:    config.MAX_HEADER_BYTES,
+	}
+}
+
+// Handlers of the GET parameters routed by returnContent, any other parameter returns the index page
+var pageRoutes = map[string]func(w http.ResponseWriter, r *http.Request, parameters url.Values){
+	"id": func(w http.ResponseWriter, r *http.Request, parameters url.Values) { // Display a whole post (param: post id)
+		assembleTemplate(w, r, false, "post.html", "post", parameters.Get("id"))
+	},
+	"comment": func(w http.ResponseWriter, r *http.Request, parameters url.Values) { // Persists a comment then displays the appropriate post (param: post id belonging to the comment)
+		if allowed, retryAfter := backend.AllowComment(r, parameters.Get("comment")); !allowed {
+			// Too many comments: display the post again including the rejected comment and an error message
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			assembleTemplate(w, r, false, "post.html", "commentRejected", parameters.Get("comment"))
+			return
+		}
+		backend.SaveComment(r, parameters.Get("comment"))
+		http.Redirect(w, r, "https://"+r.Host+"?id="+parameters.Get("comment"), http.StatusSeeOther)
+	},
+	"post": func(w http.ResponseWriter, r *http.Request, parameters url.Values) { // Displays the post creation site
+		assembleTemplate(w, r, true, "createPost.html", "create", "")
+	},
+	"account": func(w http.ResponseWriter, r *http.Request, parameters url.Values) { // Displays the account page (change password / create user if admin)
+		assembleTemplate(w, r, true, "user.html", "user", "")
+	},
+	"search": func(w http.ResponseWriter, r *http.Request, parameters url.Values) { // Displays the index page with results filtered by a search query (param: query, see backend.ParseSearchQuery)
+		assembleTemplate(w, r, false, "postPreview.html", "index", parameters.Get("search"))
+	},
+	"tag": func(w http.ResponseWriter, r *http.Request, parameters url.Values) { // Displays the landing page of a tag including its description and posts (param: tag slug)
+		assembleTemplate(w, r, false, "postPreview.html", "tag", parameters.Get("tag"))
+	},
+	"tags": func(w http.ResponseWriter, r *http.Request, parameters url.Values) { // Displays the tag management page (rename, describe and merge tags, admins only)
+		if user, loggedIn := backend.CheckAuthentication(r); loggedIn && !user.Admin {
+			renderErrorPage(w, r, http.StatusForbidden, "Only admins can change tags.")
+		} else {
+			assembleTemplate(w, r, true, "tags.html", "tags", "")
+		}
+	},
+	"updateTag": func(w http.ResponseWriter, r *http.Request, parameters url.Values) { // Ajax request to rename and describe a tag. Possibly returns an error message.
+		w.Write([]byte(backend.UpdateTag(r)))
+	},
+	"mergeTags": func(w http.ResponseWriter, r *http.Request, parameters url.Values) { // Ajax request to merge a tag into another. Possibly returns an error message.
+		w.Write([]byte(backend.MergeTags(r)))
+	},
+	"category": func(w http.ResponseWriter, r *http.Request, parameters url.Values) { // Displays the archive page of a category including its subcategories and posts (param: category slug)
+		assembleTemplate(w, r, false, "postPreview.html", "category", parameters.Get("category"))
+	},
+	"categories": func(w http.ResponseWriter, r *http.Request, parameters url.Values) { // Displays the category management page (create and delete categories, admins only)
+		if user, loggedIn := backend.CheckAuthentication(r); loggedIn && !user.Admin {
+			renderErrorPage(w, r, http.StatusForbidden, "Only admins can change categories.")
+		} else {
+			assembleTemplate(w, r, true, "categories.html", "categories", "")
+		}
+	},
+	"newCategory": func(w http.ResponseWriter, r *http.Request, parameters url.Values) { // Ajax request to create a category. Possibly returns an error message.
+		w.Write([]byte(backend.CreateCategory(r)))
+	},
+	"deleteCategory": func(w http.ResponseWriter, r *http.Request, parameters url.Values) { // Ajax request to delete a category. Possibly returns an error message.
+		w.Write([]byte(backend.DeleteCategory(r)))
+	},
+	"media": func(w http.ResponseWriter, r *http.Request, parameters url.Values) { // Displays the media library (upload, browse and delete files)
+		assembleTemplate(w, r, true, "media.html", "media", "")
+	},
+	"upload": func(w http.ResponseWriter, r *http.Request, parameters url.Values) { // Ajax request to upload a file (multipart form: file). Returns json containing the url of the file or an error message.
+		media, err := backend.UploadMedia(r)
+		response := map[string]interface{}{"error": err}
+		if err == "" {
+			response["url"] = backend.MediaUrl(media)
+			response["name"] = media.Name
+			response["image"] = strings.HasPrefix(media.Type, "image/")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	},
+	"deleteMedia": func(w http.ResponseWriter, r *http.Request, parameters url.Values) { // Ajax request to delete an uploaded file. Possibly returns an error message.
+		w.Write([]byte(backend.DeleteMedia(r)))
+	},
+	"themes": func(w http.ResponseWriter, r *http.Request, parameters url.Values) { // Displays the available themes and allows to select the active one (admins only)
+		if user, loggedIn := backend.CheckAuthentication(r); loggedIn && !user.Admin {
+			renderErrorPage(w, r, http.StatusForbidden, "Only admins can change the theme.")
+		} else {
+			assembleTemplate(w, r, true, "themes.html", "themes", "")
+		}
+	},
+	"activateTheme": func(w http.ResponseWriter, r *http.Request, parameters url.Values) { // Ajax request to select the active theme (param: theme name). Possibly returns an error message.
+		w.Write([]byte(activateTheme(r)))
+	},
+	"suggest": func(w http.ResponseWriter, r *http.Request, parameters url.Values) { // Ajax request for existing keywords (including usage counts) and post titles matching a partially entered text (param: text). Returns json.
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(backend.Suggest(parameters.Get("suggest"), config.SUGGESTION_LIMIT))
+	},
+	"more": func(w http.ResponseWriter, r *http.Request, parameters url.Values) { // Responses to an ajax request to load more posts (default 5) if there are more (param: amount of posts already displayed -> index, optional search query, tag or category)
+		assembleSingleTemplate(w, r, "postPreview.html", "more", parameters.Get("more"))
+	},
+	"newPost": func(w http.ResponseWriter, r *http.Request, parameters url.Values) { // Tries to persist a new post and shows it if successful. Otherwise returns to the post creation site.
+		id := backend.CreatePost(r)
+		if id == 0 {
+			assembleTemplate(w, r, true, "createPost.html", "create", "")
+		} else {
+			http.Redirect(w, r, "https://"+r.Host+"?id="+strconv.Itoa(int(id)), http.StatusSeeOther)
+		}
+	},
+	"newUser": func(w http.ResponseWriter, r *http.Request, parameters url.Values) { // Ajax request to persist an user. Returns the username or an error message (e.g. 'Konstantin#' or '#Error Message')
+		name, err := backend.CreateUser(r)
+		w.Write([]byte(name + "#" + err))
+	},
+	"delete": func(w http.ResponseWriter, r *http.Request, parameters url.Values) { // Ajax request to delete a post. Returns a string representing the success bool value.
+		w.Write([]byte(strconv.FormatBool(backend.DeletePost(r))))
+	},
+	"edit": func(w http.ResponseWriter, r *http.Request, parameters url.Values) { // Shows the post editing page (param: post id)
+		assembleTemplate(w, r, true, "editPost.html", "edit", parameters.Get("edit"))
+	},
+	"update": func(w http.ResponseWriter, r *http.Request, parameters url.Values) { // Tries to apply edits to a post and then returns it (param: post id)
+		id := parameters.Get("update")
+		if _, loggedIn := backend.CheckAuthentication(r); !loggedIn {
+			renderErrorPage(w, r, http.StatusUnauthorized, "Please login to edit this post.")
+		} else if _, err := backend.GetPost(id); err != nil {
+			renderErrorPage(w, r, http.StatusNotFound, "Post not found.")
+		} else if !backend.UpdatePost(r, id) {
+			renderErrorPage(w, r, http.StatusForbidden, "You are not allowed to edit this post.")
+		} else {
+			http.Redirect(w, r, "https://"+r.Host+"?id="+id, http.StatusSeeOther)
+		}
+	},
+	"password": func(w http.ResponseWriter, r *http.Request, parameters url.Values) { // Ajax request to change a password. Possibly returns an error message.
+		err := backend.ChangePassword(r)
+		w.Write([]byte(err))
+	},
+	"verify": func(w http.ResponseWriter, r *http.Request, parameters url.Values) { // Ajax request to verify a comment. Returns a string representing the success bool value.
+		success := backend.VerifyComment(r)
+		w.Write([]byte(strconv.FormatBool(success)))
+	},
+	"markSpam": func(w http.ResponseWriter, r *http.Request, parameters url.Values) { // Ajax request to move a comment to the spam queue. Returns a string representing the success bool value.
+		success := backend.MarkCommentSpam(r)
+		w.Write([]byte(strconv.FormatBool(success)))
+	},
+	"notifications": func(w http.ResponseWriter, r *http.Request, parameters url.Values) { // Ajax request to change the notification settings. Possibly returns an error message.
+		err := backend.ChangeNotifications(r)
+		w.Write([]byte(err))
+	},
+	"confirm": func(w http.ResponseWriter, r *http.Request, parameters url.Values) { // Confirms a reply subscription of a reader by a POST request, GET only shows the form (param: token of the double-opt-in link)
+		assembleTemplate(w, r, false, "notification.html", "confirm", parameters.Get("confirm"))
+	},
+	"unsubscribe": func(w http.ResponseWriter, r *http.Request, parameters url.Values) { // Cancels a reply subscription of a reader by a POST request, GET only shows the form (param: token of the unsubscribe link)
+		assembleTemplate(w, r, false, "notification.html", "unsubscribe", parameters.Get("unsubscribe"))
+	},
+	"spam": func(w http.ResponseWriter, r *http.Request, parameters url.Values) { // Displays the spam queue containing comments of all posts
+		assembleTemplate(w, r, true, "spamQueue.html", "spam", "")
+	},
 }
 
 /**
-Routes requests appropriate to their GET parameter. If none or an arbitrary one exists the index page is returned.
+Routes requests appropriate to their GET parameter (see pageRoutes). If none or an arbitrary one exists the index page is returned.
+Only the date-based archive (e.g. /archive/2026/10), uploaded media (/media/<file>), the feeds (/feed.xml, /atom.xml, /feed.json),
+the sitemap and the robots.txt are routed by their path.
 Therefor hands over necessary variables to 'assembleTemplate()': responseWriter, request, loginRequired, templateName, requestedPage and parameter (GET value).
 Since the pages mostly consist of two templates with one of them being the static content (header, footer, ...) only one template name is passed that determines the dynamic main content.
 The parameter value is used to pass GET values (e.g. post index id)
  */
 func returnContent(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, "/archive") { // Displays the posts of a year or month (path: /archive/<year>[/<month>])
+		assembleTemplate(w, r, false, "postPreview.html", "archive", strings.TrimPrefix(r.URL.Path, "/archive"))
+		return
+	}
+	if strings.HasPrefix(r.URL.Path, "/media/") { // Uploaded files of the media library (path: /media/<file name>)
+		serveMedia(w, r, strings.TrimPrefix(r.URL.Path, "/media/"))
+		return
+	}
+	switch r.URL.Path { // Feeds of the newest posts (optional GET parameters: tag, category or author)
+	case "/feed.xml":
+		serveFeed(w, r, "rss")
+		return
+	case "/atom.xml":
+		serveFeed(w, r, "atom")
+		return
+	case "/feed.json":
+		serveFeed(w, r, "json")
+		return
+	case "/sitemap.xml": // Sitemap of all posts, tags and archive pages, split into pages above config.SITEMAP_MAX_URLS (param: page)
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		sitemap, err := backend.Sitemap(page)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		w.Write(sitemap)
+		return
+	case "/robots.txt":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(backend.RobotsTxt()))
+		return
+	}
+	if r.URL.Path != "/" && r.URL.Path != "" { // any other path doesn't exist
+		renderErrorPage(w, r, http.StatusNotFound, "Page not found.")
+		return
+	}
 	parameters := r.URL.Query()
-	for key := range parameters {
-		switch key {
-		case "id": // Display a whole post (param: post id)
-			assembleTemplate(w, r, false, "post.html", "post", parameters.Get("id"))
-		case "comment": // Persists a comment then displays the appropriate post (param: post id belonging to the comment)
-			backend.SaveComment(r, parameters.Get("comment"))
-			http.Redirect(w, r, "https://"+r.Host+"?id="+parameters.Get("comment"), http.StatusMovedPermanently)
-		case "post": // Displays the post creation site
-			assembleTemplate(w, r, true, "createPost.html", "create", "")
-		case "account": // Displays the account page (change password / create user if admin)
-			assembleTemplate(w, r, true, "user.html", "user", "")
-		case "search": // Displays the index page with results filtered by a keyword (param: keyword)
-			assembleTemplate(w, r, false, "postPreview.html", "index", parameters.Get("search"))
-		case "more": // Responses to an ajax request to load more posts (default 5) if there are more (param: amount of posts already displayed -> index)
-			assembleSingleTemplate(w, r, "postPreview.html", "more", parameters.Get("more"))
-		case "newPost": // Tries to persist a new post and shows it if successful. Otherwise returns to the post creation site.
-			id := backend.CreatePost(r)
-			if id == 0 {
-				assembleTemplate(w, r, true, "createPost.html", "create", "")
-			} else {
-				http.Redirect(w, r, "https://"+r.Host+"?id="+strconv.Itoa(int(id)), http.StatusMovedPermanently)
-			}
-		case "newUser": // Ajax request to persist an user. Returns the username or an error message (e.g. 'Konstantin#' or '#Error Message')
-			name, err := backend.CreateUser(r)
-			w.Write([]byte(name + "#" + err))
-		case "delete": // Ajax request to delete a post. Returns a string representing the success bool value.
-			w.Write([]byte(strconv.FormatBool(backend.DeletePost(r))))
-		case "edit": // Shows the post editing page (param: post id)
-			assembleTemplate(w, r, true, "editPost.html", "post", parameters.Get("edit"))
-		case "update": // Tries to apply edits to a post and then returns it (param: post id)
-			id := parameters.Get("update")
-			backend.UpdatePost(r, id)
-			http.Redirect(w, r, "https://"+r.Host+"?id="+id, http.StatusMovedPermanently)
-		case "password": // Ajax request to change a password. Possibly returns an error message.
-			err := backend.ChangePassword(r)
-			w.Write([]byte(err))
-		case "verify": // Ajax request to verify a comment. Returns a string representing the success bool value.
-			success := backend.VerifyComment(r)
-			w.Write([]byte(strconv.FormatBool(success)))
-		default: // Returns the index page (listing of recent posts) for unknown GET parameters.
+	if key, found := firstParameter(r); found {
+		if route, found := pageRoutes[key]; found {
+			route(w, r, parameters)
+		} else { // Returns the index page (listing of recent posts) for unknown GET parameters.
 			assembleTemplate(w, r, false, "postPreview.html", "index", "")
 		}
 		return // only consider first parameter
@@ -83,29 +291,108 @@ func returnContent(w http.ResponseWriter, r *http.Request) {
 	assembleTemplate(w, r, false, "postPreview.html", "index", "")
 }
 
+/**
+Responds with a feed of the newest posts in the passed format (rss, atom or json), optionally filtered by the GET parameter
+tag, category or author. Sends Last-Modified and ETag headers, thus unchanged feeds are answered with 304 Not Modified.
+ */
+func serveFeed(w http.ResponseWriter, r *http.Request, format string) {
+	query := r.URL.Query()
+	filter := backend.FeedFilter{Tag: query.Get("tag"), Category: query.Get("category"), Author: query.Get("author")}
+	feed, err := backend.BuildFeed(filter, backend.BaseUrl()+r.URL.RequestURI())
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	var body []byte
+	switch format {
+	case "atom":
+		body, err = feed.Atom()
+		w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	case "json":
+		body, err = feed.JSON()
+		w.Header().Set("Content-Type", "application/feed+json; charset=utf-8")
+	default:
+		body, err = feed.RSS()
+		w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Feed could not be created", "error", err)
+		http.Error(w, "Something went wrong.", http.StatusInternalServerError)
+		return
+	}
+	checksum := fnv.New64a()
+	checksum.Write(body)
+	w.Header().Set("ETag", fmt.Sprintf("\"%x\"", checksum.Sum64()))
+	http.ServeContent(w, r, "", feed.Updated, bytes.NewReader(body))
+}
+
+/**
+Responds with an uploaded file of the media library or a resized variant of an image (GET parameter size, e.g. thumbnail).
+Since files are content-addressed they never change and may be cached forever.
+ */
+func serveMedia(w http.ResponseWriter, r *http.Request, file string) {
+	media, _, err := backend.GetMediaFile(file)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	path, err := backend.MediaVariant(file, r.URL.Query().Get("size"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", media.Type)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	http.ServeFile(w, r, path)
+}
+
 /**
 Assembles an html page.
-Checks if a login is necessary to view the page / if the user is logged in. If the user lacks access he is redirected to the index page.
-Otherwise inserts the dynamic content (templateName) into the static template (header, footer, ...).
+Checks if a login is necessary to view the page / if the user is logged in. If the user lacks access an error page (401) is returned.
+Otherwise inserts the dynamic content (templateName) into the static template (header, footer, ...), both taken from the template cache.
 Therefor appropriate page variables are loaded that always include information about an existing authentication.
-Then returns the result of the assembled html template.
+Then returns the result of the assembled html template using the status code of the page (see pageStatus).
+If it can't be rendered an error page (500) is returned instead. Returns the status code that was written.
  */
 func assembleTemplate(w http.ResponseWriter, r *http.Request, loginRequired bool, templateName, page, parameter string) (int, error) {
 	if _, loggedIn := backend.CheckAuthentication(r); loginRequired && !loggedIn {
-		http.Redirect(w, r, "https://"+r.Host, http.StatusMovedPermanently)
-		return 401, nil
+		return renderErrorPage(w, r, http.StatusUnauthorized, "Please login to view this page.")
 	}
-	staticContent := filepath.Join(config.TEMPLATE_PATH, "index.html")
-	dynamicContent := filepath.Join(config.TEMPLATE_PATH, templateName)
-	tmpl, err := template.ParseFiles(staticContent, dynamicContent)
+	tmpl, err := getTemplate(templateName)
 	if err != nil {
-		fmt.Println(err)
-		return 500, err
+		return internalError(w, r, err)
 	}
 
 	entries := getPageVars(page, r, parameter)
-	tmpl.Execute(w, entries)
-	return 0, nil
+	entries["meta"] = getPageMeta(r, entries)
+	status := pageStatus(entries)
+	if err := executeTemplate(w, tmpl, "index.html", entries, status); err != nil {
+		return internalError(w, r, err)
+	}
+	return status, nil
+}
+
+/**
+Returns the status code of a page, which is set by the page variables (status) if the requested content doesn't exist
+or the user isn't allowed to access it, e.g. 404 for missing posts. Defaults to 200.
+ */
+func pageStatus(entries map[string]interface{}) int {
+	if status, ok := entries["status"].(int); ok {
+		return status
+	}
+	return http.StatusOK
+}
+
+/**
+Returns the metadata of the html head (Open Graph, Twitter Card, JSON-LD). Pages displaying a post use the post itself,
+all other pages the general information of the blog.
+ */
+func getPageMeta(r *http.Request, entries map[string]interface{}) backend.PageMeta {
+	if post, ok := entries["post"].(models.Entry); ok && post.Id != 0 {
+		return backend.PostMeta(post)
+	}
+	return backend.SiteMeta(r.URL)
 }
 
 /**
@@ -114,15 +401,30 @@ Only used to answer the ajax request for loading more posts.
 The parameter value is used to identify the index of the requested posts.
  */
 func assembleSingleTemplate(w http.ResponseWriter, r *http.Request, templateName, page, parameter string) (int, error) {
-	lp := filepath.Join(config.TEMPLATE_PATH, templateName)
-	tmpl, err := template.New("mainContent").ParseFiles(lp)
+	tmpl, err := getTemplate(templateName)
 	if err != nil {
-		fmt.Println(err)
-		return 500, err
+		return internalError(w, r, err)
 	}
 	entries := getPageVars(page, r, parameter)
-	tmpl.Execute(w, entries)
-	return 0, nil
+	if err := executeTemplate(w, tmpl, "mainContent", entries, http.StatusOK); err != nil {
+		return internalError(w, r, err)
+	}
+	return http.StatusOK, nil
+}
+
+/**
+Executes a template into a buffer first and writes it with the passed status code afterwards.
+Thus a failing template writes nothing and the caller can respond with an error page instead of a partially written page.
+ */
+func executeTemplate(w http.ResponseWriter, tmpl *template.Template, name string, entries map[string]interface{}, status int) error {
+	var buffer bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buffer, name, entries); err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	buffer.WriteTo(w)
+	return nil
 }
 
 /**
@@ -135,10 +437,79 @@ func getPageVars(page string, r *http.Request, parameter string) map[string]inte
 	case "index":
 		entries = getIndexVars(parameter)
 	case "more":
-		entries = getLoadMoreVars(parameter)
-	case "post":
+		query := r.URL.Query()
+		year, _ := strconv.Atoi(query.Get("year"))
+		month, _ := strconv.Atoi(query.Get("month"))
+		entries = getLoadMoreVars(parameter, postFilter{search: query.Get("search"), tag: query.Get("tag"),
+			category: query.Get("category"), year: year, month: month})
+	case "tag":
+		entries = getTagVars(parameter)
+	case "category":
+		entries = getCategoryVars(parameter)
+	case "archive":
+		entries = getArchiveVars(parameter)
+	case "categories":
+		entries["categories"] = backend.CategoryOptions()
+	case "create":
+		entries["categories"] = backend.CategoryOptions()
+		entries["coverImages"] = backend.CoverImages()
+	case "tags":
+		entries["tags"] = backend.ListTags()
+	case "themes":
+		entries["themes"] = ListThemes()
+		entries["activeTheme"] = activeTheme().Slug
+	case "media":
+		entries["media"] = backend.ListMedia()
+		entries["maxUploadSize"] = config.MAX_UPLOAD_SIZE >> 20
+	case "commentRejected":
+		entries["commentError"] = "You are commenting too fast. Please wait a moment and try again."
+		entries["commentText"] = r.FormValue("text")
+		entries["status"] = http.StatusTooManyRequests
+		fallthrough
+	case "post", "edit":
 		// if an error occurs this variable is empty -> 404 message displayed, e.g. /?id=0
-		entries["post"], _ = backend.GetPost(parameter)
+		post, err := backend.GetPost(parameter)
+		if _, rejected := entries["status"]; err != nil && !rejected {
+			entries["status"] = http.StatusNotFound
+		} else if user, _ := backend.CheckAuthentication(r); page == "edit" && post.AuthorId != user.Id {
+			entries["status"] = http.StatusForbidden
+			entries["forbidden"] = true
+			post = models.Entry{} // only the author may edit a post
+		}
+		entries["post"] = post
+		entries["breadcrumbs"] = backend.CategoryPath(post.Category)
+		var secondaries []models.Category
+		for _, slug := range post.Categories {
+			if category, err := backend.GetCategory(slug); err == nil {
+				secondaries = append(secondaries, category)
+			}
+		}
+		entries["secondaryCategories"] = secondaries
+		entries["categories"] = backend.CategoryOptions()
+		entries["coverImages"] = backend.CoverImages()
+		comments := post.Comments
+		if _, loggedIn := backend.CheckAuthentication(r); !loggedIn {
+			comments = backend.VerifiedComments(comments) // readers only see verified comments
+		}
+		entries["comments"] = backend.BuildCommentThreads(comments, config.MAX_COMMENT_DEPTH)
+		entries["commentToken"] = backend.CreateCommentToken()
+		entries["notifications"] = backend.NotificationsEnabled()
+	case "user":
+		entries["notifications"] = backend.NotificationsEnabled()
+	case "confirm", "unsubscribe":
+		// links are opened by prefetchers and crawlers as well, thus the subscription only changes by submitting the form
+		if r.Method != http.MethodPost {
+			entries["action"] = page
+			entries["token"] = parameter
+		} else if page == "confirm" && backend.ConfirmSubscription(parameter) {
+			entries["message"] = "Your subscription is confirmed. You will be notified about replies to your comment."
+		} else if page == "unsubscribe" && backend.CancelSubscription(parameter) {
+			entries["message"] = "You won't be notified about replies anymore."
+		} else {
+			entries["message"] = "The subscription could not be found."
+		}
+	case "spam":
+		entries["queue"] = backend.GetSpamQueue()
 	}
 	// If the request reveals an existing session further information about the user is provided
 	if user, found := backend.CheckAuthentication(r); found {
@@ -147,54 +518,187 @@ func getPageVars(page string, r *http.Request, parameter string) map[string]inte
 	return entries
 }
 
+/**
+Restricts the posts listed by the index, tag, category and archive pages: by a search query, a tag or a category (slugs)
+or the year and month (0 = whole year) of publication.
+ */
+type postFilter struct {
+	search   string
+	tag      string
+	category string
+	year     int
+	month    int
+}
+
 /**
 Loads required variables of the index page, including:
 - initial: are the displayed posts the first ones? (<-> load more)
 - previews: information about posts to assemble their preview within the index page.
-- search: search phrase / keyword defined by the GET parameter. If set the posts are appropriately filtered.
+- search: search query defined by the GET parameter. If set the posts are appropriately filtered.
 - more/index: if more posts exist a flag is set and the index to load more content is provided.
+- tagCloud: all used tags weighted by their usage, only if no search query is set.
+- archive: months with the number of posts published within them, only if no search query is set.
  */
 func getIndexVars(parameter string) map[string]interface{} {
-	entries := map[string]interface{}{}
+	entries := getLoadMoreVars("0", postFilter{search: parameter})
 	entries["initial"] = true
-	entries["previews"] = backend.GetEntries()
-	if parameter != "" {
-		entries["previews"] = backend.FilterPosts(entries["previews"].([]models.Entry), parameter)
-		entries["search"] = parameter
+	if parameter == "" {
+		entries["tagCloud"] = backend.TagCloud()
+		entries["archive"] = backend.ArchiveMonths(backend.GetEntries())
+	}
+	return entries
+}
+
+/**
+Loads required variables of the landing page of a tag, including:
+- initial, previews, more/index: see getIndexVars, the posts are filtered by the tag.
+- tag: name, description and usage of the tag. Not set if the tag doesn't exist.
+ */
+func getTagVars(slug string) map[string]interface{} {
+	entries := getLoadMoreVars("0", postFilter{tag: slug})
+	entries["initial"] = true
+	if tag, err := backend.GetTag(slug); err == nil {
+		entries["tag"] = tag
 	} else {
-		if length := len(entries["previews"].([]models.Entry)); length > config.POSTS_PER_REQUESTS {
-			entries["previews"] = entries["previews"].([]models.Entry)[:config.POSTS_PER_REQUESTS]
-			entries["more"] = true
-			entries["index"] = config.POSTS_PER_REQUESTS
-		}
+		entries["status"] = http.StatusNotFound
+	}
+	return entries
+}
+
+/**
+Loads required variables of the archive page of a category, including:
+- initial, previews, more/index: see getIndexVars, the posts are filtered by the category and its subcategories.
+- category: name and description of the category. Not set if the category doesn't exist.
+- breadcrumbs: path of categories from the top level down to the category.
+- subcategories: direct subcategories of the category.
+ */
+func getCategoryVars(slug string) map[string]interface{} {
+	entries := getLoadMoreVars("0", postFilter{category: slug})
+	entries["initial"] = true
+	if category, err := backend.GetCategory(slug); err == nil {
+		entries["category"] = category
+		entries["breadcrumbs"] = backend.CategoryPath(slug)
+		entries["subcategories"] = backend.Subcategories(slug)
+	} else {
+		entries["status"] = http.StatusNotFound
+	}
+	return entries
+}
+
+/**
+Loads required variables of the archive page of a year or month (param: path below /archive, e.g. /2026 or /2026/10), including:
+- initial, previews, more/index: see getIndexVars, the posts are filtered by their date.
+- archiveTitle: the displayed period, e.g. 'October 2026'. Not set if the path is invalid.
+- archive: months with the number of posts published within them.
+ */
+func getArchiveVars(path string) map[string]interface{} {
+	year, month, err := parseArchivePath(path)
+	if err != nil {
+		return map[string]interface{}{"initial": true, "archiveInvalid": true, "status": http.StatusNotFound}
+	}
+	entries := getLoadMoreVars("0", postFilter{year: year, month: month})
+	entries["initial"] = true
+	entries["archive"] = backend.ArchiveMonths(backend.GetEntries())
+	if month == 0 {
+		entries["archiveTitle"] = strconv.Itoa(year)
+	} else {
+		entries["archiveTitle"] = fmt.Sprintf("%v %d", time.Month(month), year)
 	}
 	return entries
 }
 
+/**
+Parses the path of an archive page below /archive into a year and a month (1 - 12 or 0 if the whole year is requested).
+Returns an error if the path doesn't match /<year> or /<year>/<month>, a trailing slash is allowed.
+ */
+func parseArchivePath(path string) (year, month int, err error) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) > 2 || !strings.HasPrefix(path, "/") {
+		return 0, 0, errors.New("invalid archive path")
+	}
+	if year, err = strconv.Atoi(parts[0]); err != nil || year < 1 || year > 9999 {
+		return 0, 0, errors.New("invalid archive year")
+	}
+	if len(parts) == 2 {
+		if month, err = strconv.Atoi(parts[1]); err != nil || month < 1 || month > 12 {
+			return 0, 0, errors.New("invalid archive month")
+		}
+	}
+	return year, month, nil
+}
+
 /**
 Loads required variables to reponse to an ajax request to load more posts.
-- initial: are the displayed posts the first ones? (<-> load more)
 - previews: information about posts to assemble their preview within the index page.
-- search: search phrase / keyword defined by the GET parameter. If set the posts are appropriately filtered.
+- search: search query. If set the posts are appropriately filtered and ranked by relevance before they are paginated.
+- snippets: excerpts of the found posts highlighting the matches by post id.
+- tagSlug/categorySlug: slug of a tag or category. If set the posts are filtered accordingly before they are paginated.
+- year/month: if set the posts are filtered by their date of publication before they are paginated.
+- filter: the filter encoded as query string, used to request more posts.
 - more/index: if more posts exist a flag is set and the index to load more content is provided.
  */
-func getLoadMoreVars(parameter string) map[string]interface{} {
+func getLoadMoreVars(parameter string, filter postFilter) map[string]interface{} {
 	entries := map[string]interface{}{}
-	entries["previews"] = backend.GetEntries()
+	var previews []models.Entry
+	query := url.Values{}
+	if filter.search != "" {
+		snippets := map[uint32][]backend.SnippetPart{}
+		for _, result := range backend.SearchPosts(filter.search) {
+			previews = append(previews, result.Entry)
+			snippets[result.Entry.Id] = result.Snippet
+		}
+		entries["search"] = filter.search
+		entries["snippets"] = snippets
+		query.Set("search", filter.search)
+	} else if filter.tag != "" {
+		previews = backend.FilterPostsByTag(backend.GetEntries(), filter.tag)
+		entries["tagSlug"] = filter.tag
+		query.Set("tag", filter.tag)
+	} else if filter.category != "" {
+		previews = backend.FilterPostsByCategory(backend.GetEntries(), filter.category)
+		entries["categorySlug"] = filter.category
+		query.Set("category", filter.category)
+	} else if filter.year != 0 {
+		previews = backend.FilterPostsByDate(backend.GetEntries(), filter.year, filter.month)
+		query.Set("year", strconv.Itoa(filter.year))
+		if filter.month != 0 {
+			query.Set("month", strconv.Itoa(filter.month))
+		}
+	} else {
+		previews = backend.GetEntries()
+	}
+	entries["filter"] = query.Encode()
 	index, _ := strconv.Atoi(parameter)
-	lengthLeft := len(entries["previews"].([]models.Entry)) - index
+	if index < 0 || index > len(previews) {
+		index = len(previews)
+	}
+	lengthLeft := len(previews) - index
 	if lengthLeft >= config.POSTS_PER_REQUESTS {
-		entries["previews"] = entries["previews"].([]models.Entry)[index:index+config.POSTS_PER_REQUESTS]
+		entries["previews"] = previews[index:index+config.POSTS_PER_REQUESTS]
 		if lengthLeft > config.POSTS_PER_REQUESTS {
 			entries["more"] = true
 			entries["index"] = index + config.POSTS_PER_REQUESTS
 		}
 	} else {
-		entries["previews"] = entries["previews"].([]models.Entry)[index:]
+		entries["previews"] = previews[index:]
 	}
 	return entries
 }
 
+/**
+Returns the name of the first GET parameter in the order of the query string.
+Used for routing, since the order of url.Values is random.
+ */
+func firstParameter(r *http.Request) (string, bool) {
+	for _, pair := range strings.Split(r.URL.RawQuery, "&") {
+		key, err := url.QueryUnescape(strings.SplitN(pair, "=", 2)[0])
+		if err == nil && key != "" {
+			return key, true
+		}
+	}
+	return "", false
+}
+
 /**
 Validates the transferred credentials and sets a session if successful.
  */
@@ -202,10 +706,13 @@ func loginUser(w http.ResponseWriter, r *http.Request) {
 	username := r.FormValue("username")
 	password := r.FormValue("password")
 	if backend.AuthenticateUser(username, password) {
-		fmt.Println("Login:", username)
+		slog.InfoContext(r.Context(), "Login", "user", username)
+		backend.ObserveLogin(true)
 		backend.SetSession(username, w)
 		w.Write([]byte("success"))
 	} else {
+		slog.WarnContext(r.Context(), "Login failed", "user", username)
+		backend.ObserveLogin(false)
 		w.Write([]byte("failed to login"))
 	}
 }
@@ -215,6 +722,6 @@ Eliminates the active session and redirects to the index page.
  */
 func logoutUser(w http.ResponseWriter, r *http.Request) {
 	backend.EndSession(r)
-	http.Redirect(w, r, "https://"+r.Host, http.StatusMovedPermanently)
+	http.Redirect(w, r, "https://"+r.Host, http.StatusFound)
 	return
 }
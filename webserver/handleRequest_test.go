@@ -1,6 +1,9 @@
 package webserver
 
 import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
 	"testing"
 	"net/http"
 	"github.com/stretchr/testify/assert"
@@ -11,6 +14,8 @@ import (
 	"crypto/tls"
 	"net/url"
 	"net/http/cookiejar"
+	"net/http/httptest"
+	"time"
 	"github.com/kherud/goblog/config"
 )
 
@@ -18,7 +23,22 @@ func TestMain(m *testing.M) {
 	config.TEMPLATE_PATH = config.TEST_TEMPLATE_PATH
 	config.ENTRIES_FILE_PATH = filepath.Join("..", "backend", "test_data", "entries.json")
 	config.USERS_FILE_PATH = filepath.Join("..", "backend", "test_data", "users.json")
-	os.Exit(m.Run())
+	config.INDEX_FILE_PATH = filepath.Join("..", "backend", "test_data", "index.json")
+	config.TAGS_FILE_PATH = filepath.Join("..", "backend", "test_data", "tags.json")
+	config.CATEGORIES_FILE_PATH = filepath.Join("..", "backend", "test_data", "categories.json")
+	config.MEDIA_FILE_PATH = filepath.Join("..", "backend", "test_data", "media.json")
+	config.MEDIA_PATH = filepath.Join("..", "backend", "test_data", "media")
+	config.SETTINGS_FILE_PATH = filepath.Join("..", "backend", "test_data", "settings.json")
+	config.THEMES_PATH = filepath.Join("..", "backend", "test_data", "themes")
+	code := m.Run()
+	os.Remove(config.INDEX_FILE_PATH)
+	os.Remove(config.TAGS_FILE_PATH)
+	os.Remove(config.CATEGORIES_FILE_PATH)
+	os.Remove(config.MEDIA_FILE_PATH)
+	os.RemoveAll(config.MEDIA_PATH)
+	os.Remove(config.SETTINGS_FILE_PATH)
+	os.RemoveAll(config.THEMES_PATH)
+	os.Exit(code)
 }
 
 // Backend logic is separately unit tested, thus only the delivery of proper content is tested
@@ -27,21 +47,46 @@ func TestMain(m *testing.M) {
 func TestReturnContentId(t *testing.T) {
 	body := testServerRequest(t, "https://localhost:8080", false)
 	assert.True(t, strings.Contains(string(body), "Recent posts"))
+	assert.True(t, strings.Contains(string(body), "<p class=\"post-excerpt\">Hello Hola Hallo</p>"))
+	assert.True(t, strings.Contains(string(body), "1 min read"))
+}
+
+func TestReturnContentIdComments(t *testing.T) {
+	body := testServerRequest(t, "https://localhost:8080?id=880156671", false)
+	assert.True(t, strings.Contains(string(body), "Verified"))
+	assert.False(t, strings.Contains(string(body), "Not Verified"))
+	assert.True(t, strings.Contains(string(body), "comment-parent-input"))
+	assert.True(t, strings.Contains(string(body), "<a class=\"btn btn-outline-secondary selectable-keyword\" href=\"/?tag=cde\">"))
 }
 
 func TestReturnContentComment(t *testing.T) {
-	body := testServerRequest(t, "https://localhost:8080?comment", false)
+	body := testServerRequestStatus(t, "https://localhost:8080?comment", false, http.StatusNotFound)
 	assert.True(t, strings.Contains(string(body), "404: Post not found."))
 }
 
+func TestReturnContentCommentRateLimited(t *testing.T) {
+	srv, client := getHTTPSServerClient(false)
+	defer srv.Close()
+	var res *http.Response
+	var err error
+	for idx := 0; idx <= config.COMMENT_BURST_IP; idx++ {
+		res, err = client.PostForm("https://localhost:8080?comment=0", url.Values{"text": {"Spam"}})
+		assert.NoError(t, err)
+	}
+	assert.EqualValues(t, res.StatusCode, http.StatusTooManyRequests)
+	assert.NotEmpty(t, res.Header.Get("Retry-After"))
+}
+
 func TestReturnContentPost(t *testing.T) {
 	body := testServerRequest(t, "https://localhost:8080?post", true)
 	assert.True(t, strings.Contains(string(body), "Create an entry..."))
+	assert.True(t, strings.Contains(string(body), "No cover image"))
+	assert.True(t, strings.Contains(string(body), "separate it by <!--more-->"))
 }
 
 func TestReturnContentPostInvalid(t *testing.T) {
-	body := testServerRequest(t, "https://localhost:8080?post", false)
-	assert.True(t, strings.Contains(string(body), "Recent posts"))
+	body := testServerRequestStatus(t, "https://localhost:8080?post", false, http.StatusUnauthorized)
+	assert.True(t, strings.Contains(string(body), "401: Please login to view this page."))
 }
 
 func TestReturnContentUser(t *testing.T) {
@@ -51,8 +96,8 @@ func TestReturnContentUser(t *testing.T) {
 }
 
 func TestReturnContentUserInvalid(t *testing.T) {
-	body := testServerRequest(t, "https://localhost:8080?account", false)
-	assert.True(t, strings.Contains(string(body), "Recent posts"))
+	body := testServerRequestStatus(t, "https://localhost:8080?account", false, http.StatusUnauthorized)
+	assert.True(t, strings.Contains(string(body), "401: Please login to view this page."))
 }
 
 func TestReturnContentSearch(t *testing.T) {
@@ -62,6 +107,299 @@ func TestReturnContentSearch(t *testing.T) {
 	assert.True(t, strings.Contains(string(body), "Hi friend"))
 }
 
+func TestReturnContentSearchPaginated(t *testing.T) {
+	body := testServerRequest(t, "https://localhost:8080?search=post", false)
+	assert.True(t, strings.Contains(string(body), "Search results for '"))
+	assert.True(t, strings.Contains(string(body), "Older Posts"))
+	assert.EqualValues(t, strings.Count(string(body), "class=\"post-preview\""), config.POSTS_PER_REQUESTS)
+	body = testServerRequest(t, "https://localhost:8080?more=5&search=post", false)
+	assert.EqualValues(t, strings.Count(string(body), "class=\"post-preview\""), 1)
+	assert.False(t, strings.Contains(string(body), "Older Posts"))
+}
+
+func TestReturnContentSearchSnippet(t *testing.T) {
+	body := testServerRequest(t, "https://localhost:8080?search=hola", false)
+	assert.True(t, strings.Contains(string(body), "class=\"post-snippet\""))
+	assert.True(t, strings.Contains(string(body), "<mark>Hola</mark>"))
+}
+
+func TestReturnContentSuggest(t *testing.T) {
+	srv, client := getHTTPSServerClient(false)
+	defer srv.Close()
+	res, err := client.Get("https://localhost:8080?suggest=as")
+	assert.NoError(t, err)
+	defer res.Body.Close()
+	assert.EqualValues(t, res.Header.Get("Content-Type"), "application/json")
+	body, err := ioutil.ReadAll(res.Body)
+	assert.NoError(t, err)
+	assert.True(t, strings.Contains(string(body), `"keywords":[{"keyword":"asd","slug":"asd","count":2}]`))
+	assert.True(t, strings.Contains(string(body), `"titles":[]`))
+}
+
+func TestReturnContentTag(t *testing.T) {
+	body := testServerRequest(t, "https://localhost:8080?tag=asd", false)
+	assert.True(t, strings.Contains(string(body), "Tag: <span class=\"font-italic\">asd</span>"))
+	assert.EqualValues(t, strings.Count(string(body), "class=\"post-preview\""), 2)
+	body = testServerRequestStatus(t, "https://localhost:8080?tag=unknown", false, http.StatusNotFound)
+	assert.True(t, strings.Contains(string(body), "404: Tag not found."))
+	body = testServerRequest(t, "https://localhost:8080?more=0&tag=cde", false)
+	assert.EqualValues(t, strings.Count(string(body), "class=\"post-preview\""), 1)
+}
+
+func TestReturnContentTagCloud(t *testing.T) {
+	body := testServerRequest(t, "https://localhost:8080", false)
+	assert.True(t, strings.Contains(string(body), "class=\"tag-cloud-5\" href=\"/?tag=asd\""))
+	body = testServerRequest(t, "https://localhost:8080?search=asd", false)
+	assert.False(t, strings.Contains(string(body), "class=\"tag-cloud\""))
+}
+
+func TestReturnContentTags(t *testing.T) {
+	body := testServerRequest(t, "https://localhost:8080?tags", true)
+	assert.True(t, strings.Contains(string(body), "Merge tags..."))
+	assert.True(t, strings.Contains(string(body), "value=\"asd\""))
+}
+
+func TestReturnContentTagsInvalid(t *testing.T) {
+	body := testServerRequestStatus(t, "https://localhost:8080?tags", false, http.StatusUnauthorized)
+	assert.True(t, strings.Contains(string(body), "401: Please login to view this page."))
+}
+
+func TestReturnContentCategory(t *testing.T) {
+	srv, client := getHTTPSServerClient(true)
+	res, err := client.PostForm("https://localhost:8080?newCategory", url.Values{"name": {"Engineering"}, "description": {"All things technical"}})
+	assert.NoError(t, err)
+	body, _ := ioutil.ReadAll(res.Body)
+	assert.Empty(t, string(body))
+	res, err = client.PostForm("https://localhost:8080?newCategory", url.Values{"name": {"Backend"}, "parent": {"engineering"}})
+	assert.NoError(t, err)
+	body, _ = ioutil.ReadAll(res.Body)
+	assert.Empty(t, string(body))
+	srv.Close()
+	body = testServerRequest(t, "https://localhost:8080?category=engineering", false)
+	assert.True(t, strings.Contains(string(body), "Category: <span class=\"font-italic\">Engineering</span>"))
+	assert.True(t, strings.Contains(string(body), "All things technical"))
+	assert.True(t, strings.Contains(string(body), "href=\"/?category=backend\""))
+	assert.True(t, strings.Contains(string(body), "No entries in 'Engineering' yet."))
+	body = testServerRequest(t, "https://localhost:8080?category=backend", false)
+	assert.True(t, strings.Contains(string(body), "class=\"breadcrumbs\""))
+	assert.True(t, strings.Contains(string(body), "href=\"/?category=engineering\">Engineering</a>"))
+	body = testServerRequestStatus(t, "https://localhost:8080?category=unknown", false, http.StatusNotFound)
+	assert.True(t, strings.Contains(string(body), "404: Category not found."))
+	body = testServerRequest(t, "https://localhost:8080?categories", true)
+	assert.True(t, strings.Contains(string(body), "Create a category..."))
+	assert.True(t, strings.Contains(string(body), "deleteCategory('backend')"))
+	body = testServerRequest(t, "https://localhost:8080?post", true)
+	assert.True(t, strings.Contains(string(body), "name=\"secondary\""))
+	assert.True(t, strings.Contains(string(body), "<option value=\"backend\">\u00a0\u00a0\u00a0\u00a0Backend</option>"))
+}
+
+func TestReturnContentCategoriesInvalid(t *testing.T) {
+	body := testServerRequestStatus(t, "https://localhost:8080?categories", false, http.StatusUnauthorized)
+	assert.True(t, strings.Contains(string(body), "401: Please login to view this page."))
+	srv, client := getHTTPSServerClient(false)
+	defer srv.Close()
+	res, err := client.PostForm("https://localhost:8080?newCategory", url.Values{"name": {"Unauthorized"}})
+	assert.NoError(t, err)
+	body, _ = ioutil.ReadAll(res.Body)
+	assert.EqualValues(t, string(body), "Something went wrong.\n")
+}
+
+func TestReturnContentArchive(t *testing.T) {
+	body := testServerRequest(t, "https://localhost:8080/archive/2018/01", false)
+	assert.True(t, strings.Contains(string(body), "Archive: <span class=\"font-italic\">January 2018</span>"))
+	assert.EqualValues(t, strings.Count(string(body), "class=\"post-preview\""), 5)
+	assert.True(t, strings.Contains(string(body), "month=1\\u0026year=2018"))
+	assert.True(t, strings.Contains(string(body), "href=\"/static/css/style.css?v="))
+	body = testServerRequest(t, "https://localhost:8080/archive/2018/", false)
+	assert.True(t, strings.Contains(string(body), "Archive: <span class=\"font-italic\">2018</span>"))
+	body = testServerRequest(t, "https://localhost:8080/archive/2017", false)
+	assert.True(t, strings.Contains(string(body), "No entries from 2017."))
+	body = testServerRequestStatus(t, "https://localhost:8080/archive/2018/13", false, http.StatusNotFound)
+	assert.True(t, strings.Contains(string(body), "404: Archive not found."))
+	body = testServerRequest(t, "https://localhost:8080?more=5&year=2018&month=1", false)
+	assert.EqualValues(t, strings.Count(string(body), "class=\"post-preview\""), 2)
+}
+
+func TestReturnContentArchiveWidget(t *testing.T) {
+	body := testServerRequest(t, "https://localhost:8080", false)
+	assert.True(t, strings.Contains(string(body), "<a href=\"/archive/2018/01\">January 2018</a> <small>(7)</small>"))
+	body = testServerRequest(t, "https://localhost:8080?search=hola", false)
+	assert.False(t, strings.Contains(string(body), "archive-widget"))
+}
+
+func TestParseArchivePath(t *testing.T) {
+	tests := []struct {
+		Path  string
+		Year  int
+		Month int
+		Valid bool
+	}{{Path: "/2026", Year: 2026, Valid: true},
+		{Path: "/2026/10/", Year: 2026, Month: 10, Valid: true},
+		{Path: "/2026/0", Valid: false},
+		{Path: "/2026/10/3", Valid: false},
+		{Path: "2026", Valid: false},
+		{Path: "", Valid: false},
+	}
+	for _, test := range tests {
+		year, month, err := parseArchivePath(test.Path)
+		assert.EqualValues(t, err == nil, test.Valid)
+		assert.EqualValues(t, year, test.Year)
+		assert.EqualValues(t, month, test.Month)
+	}
+}
+
+func TestReturnContentFeeds(t *testing.T) {
+	srv, client := getHTTPSServerClient(false)
+	defer srv.Close()
+	tests := map[string]string{
+		"/feed.xml":  "application/rss+xml; charset=utf-8",
+		"/atom.xml":  "application/atom+xml; charset=utf-8",
+		"/feed.json": "application/feed+json; charset=utf-8",
+	}
+	for path, contentType := range tests {
+		res, err := client.Get("https://localhost:8080" + path)
+		assert.NoError(t, err)
+		body, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		assert.EqualValues(t, res.StatusCode, http.StatusOK)
+		assert.EqualValues(t, res.Header.Get("Content-Type"), contentType)
+		assert.True(t, strings.Contains(string(body), "Hi friend"))
+		etag := res.Header.Get("ETag")
+		assert.NotEmpty(t, etag)
+		assert.NotEmpty(t, res.Header.Get("Last-Modified"))
+		req, _ := http.NewRequest("GET", "https://localhost:8080"+path, nil)
+		req.Header.Set("If-None-Match", etag)
+		res, err = client.Do(req)
+		assert.NoError(t, err)
+		res.Body.Close()
+		assert.EqualValues(t, res.StatusCode, http.StatusNotModified)
+	}
+	res, err := client.Get("https://localhost:8080/feed.xml?tag=cde")
+	assert.NoError(t, err)
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	assert.EqualValues(t, strings.Count(string(body), "<item>"), 1)
+	res, err = client.Get("https://localhost:8080/atom.xml?author=unknown")
+	assert.NoError(t, err)
+	res.Body.Close()
+	assert.EqualValues(t, res.StatusCode, http.StatusNotFound)
+}
+
+func TestReturnContentFeedDiscovery(t *testing.T) {
+	body := testServerRequest(t, "https://localhost:8080", false)
+	assert.True(t, strings.Contains(string(body), "type=\"application/rss+xml\" title=\"DMK Blog (RSS)\" href=\"/feed.xml\""))
+	body = testServerRequest(t, "https://localhost:8080?tag=asd", false)
+	assert.True(t, strings.Contains(string(body), "href=\"/feed.xml?tag=asd\""))
+}
+
+func TestReturnContentSitemap(t *testing.T) {
+	body := testServerRequest(t, "https://localhost:8080/sitemap.xml", false)
+	assert.True(t, strings.Contains(string(body), "<loc>https://localhost:8080/?id=2046379135</loc>"))
+	assert.True(t, strings.Contains(string(body), "<loc>https://localhost:8080/archive/2018/01</loc>"))
+	srv, client := getHTTPSServerClient(false)
+	defer srv.Close()
+	res, err := client.Get("https://localhost:8080/sitemap.xml?page=2")
+	assert.NoError(t, err)
+	res.Body.Close()
+	assert.EqualValues(t, res.StatusCode, http.StatusNotFound)
+}
+
+func TestReturnContentRobots(t *testing.T) {
+	body := testServerRequest(t, "https://localhost:8080/robots.txt", false)
+	assert.True(t, strings.HasPrefix(string(body), "User-agent: *"))
+	assert.True(t, strings.Contains(string(body), "Sitemap: https://localhost:8080/sitemap.xml"))
+}
+
+func TestReturnContentMetadata(t *testing.T) {
+	body := testServerRequest(t, "https://localhost:8080?id=2046379135", false)
+	assert.True(t, strings.Contains(string(body), "<title>Hi friend - DMK Blog</title>"))
+	assert.True(t, strings.Contains(string(body), "<meta property=\"og:type\" content=\"article\">"))
+	assert.True(t, strings.Contains(string(body), "<meta property=\"og:description\" content=\"Hello Hola Hallo\">"))
+	assert.True(t, strings.Contains(string(body), "<link rel=\"canonical\" href=\"https://localhost:8080/?id=2046379135\">"))
+	assert.True(t, strings.Contains(string(body), "<meta property=\"article:tag\" content=\"asd\">"))
+	assert.True(t, strings.Contains(string(body), "<script type=\"application/ld+json\">{\"@context\":\"https://schema.org\""))
+	body = testServerRequest(t, "https://localhost:8080", false)
+	assert.True(t, strings.Contains(string(body), "<meta property=\"og:type\" content=\"website\">"))
+	assert.False(t, strings.Contains(string(body), "application/ld+json"))
+}
+
+func TestReturnContentMedia(t *testing.T) {
+	srv, client := getHTTPSServerClient(true)
+	defer srv.Close()
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", "notes.txt")
+	part.Write([]byte("Some notes"))
+	writer.Close()
+	res, err := client.Post("https://localhost:8080?upload", writer.FormDataContentType(), body)
+	assert.NoError(t, err)
+	var result map[string]interface{}
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(&result))
+	res.Body.Close()
+	assert.EqualValues(t, result["error"], "")
+	assert.EqualValues(t, result["name"], "notes.txt")
+	mediaUrl, _ := result["url"].(string)
+	assert.True(t, strings.HasPrefix(mediaUrl, "/media/"))
+	res, err = client.Get("https://localhost:8080" + mediaUrl)
+	assert.NoError(t, err)
+	content, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	assert.EqualValues(t, string(content), "Some notes")
+	assert.EqualValues(t, res.Header.Get("Content-Type"), "text/plain")
+	assert.EqualValues(t, res.Header.Get("X-Content-Type-Options"), "nosniff")
+	// only processed images have variants, other files are served unchanged
+	res, err = client.Get("https://localhost:8080" + mediaUrl + "?size=thumbnail")
+	assert.NoError(t, err)
+	content, _ = ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	assert.EqualValues(t, string(content), "Some notes")
+	res, err = client.Get("https://localhost:8080/media/unknown.txt")
+	assert.NoError(t, err)
+	res.Body.Close()
+	assert.EqualValues(t, res.StatusCode, http.StatusNotFound)
+	page := testServerRequest(t, "https://localhost:8080?media", true)
+	assert.True(t, strings.Contains(string(page), "value=\"[notes.txt]("+mediaUrl+")\""))
+	assert.True(t, strings.Contains(string(page), "onclick=\"deleteMedia('"+strings.TrimPrefix(mediaUrl, "/media/")+"')\""))
+	page = testServerRequestStatus(t, "https://localhost:8080?media", false, http.StatusUnauthorized)
+	assert.True(t, strings.Contains(string(page), "401: Please login to view this page."))
+}
+
+func TestReturnContentConfirm(t *testing.T) {
+	// opening the link only displays the form
+	body := testServerRequest(t, "https://localhost:8080?confirm=unknown", false)
+	assert.True(t, strings.Contains(string(body), "<form action=\"/?confirm=unknown\" method=\"post\">"))
+	assert.False(t, strings.Contains(string(body), "The subscription could not be found."))
+	body = testServerRequest(t, "https://localhost:8080?unsubscribe=unknown", false)
+	assert.True(t, strings.Contains(string(body), "<form action=\"/?unsubscribe=unknown\" method=\"post\">"))
+	srv, client := getHTTPSServerClient(false)
+	defer srv.Close()
+	for _, page := range []string{"confirm", "unsubscribe"} {
+		res, err := client.PostForm("https://localhost:8080?"+page+"=unknown", url.Values{})
+		assert.NoError(t, err)
+		content, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		assert.True(t, strings.Contains(string(content), "The subscription could not be found."))
+		assert.False(t, strings.Contains(string(content), "<form action"))
+	}
+}
+
+func TestFirstParameter(t *testing.T) {
+	tests := []struct {
+		Query    string
+		Expected string
+	}{{Query: "more=5&search=post", Expected: "more"},
+		{Query: "search=post&more=5", Expected: "search"},
+		{Query: "&id=1", Expected: "id"},
+		{Query: "", Expected: ""},
+	}
+	for _, test := range tests {
+		req, _ := http.NewRequest("GET", "https://localhost:8080/?"+test.Query, nil)
+		key, found := firstParameter(req)
+		assert.EqualValues(t, key, test.Expected)
+		assert.EqualValues(t, found, test.Expected != "")
+	}
+}
+
 func TestReturnContentMore(t *testing.T) {
 	body := testServerRequest(t, "https://localhost:8080?more=0", false)
 	assert.True(t, strings.Contains(string(body), "Post #41"))
@@ -83,8 +421,8 @@ func TestReturnContentNewPost(t *testing.T) {
 }
 
 func TestReturnContentNewPostInvalid(t *testing.T) {
-	body := testServerRequest(t, "https://localhost:8080?newPost", false)
-	assert.True(t, strings.Contains(string(body), "Recent posts"))
+	body := testServerRequestStatus(t, "https://localhost:8080?newPost", false, http.StatusUnauthorized)
+	assert.True(t, strings.Contains(string(body), "401: Please login to view this page."))
 }
 
 func TestReturnContentNewUser(t *testing.T) {
@@ -108,23 +446,31 @@ func TestReturnContentDeleteInvalid(t *testing.T) {
 }
 
 func TestReturnContentEdit(t *testing.T) {
-	body := testServerRequest(t, "https://localhost:8080?edit=0", true)
+	body := testServerRequestStatus(t, "https://localhost:8080?edit=0", true, http.StatusNotFound)
 	assert.True(t, strings.Contains(string(body), "404: Post not found."))
 }
 
+func TestReturnContentEditForbidden(t *testing.T) {
+	body := testServerRequestStatus(t, "https://localhost:8080?edit=3973812664", true, http.StatusForbidden)
+	assert.True(t, strings.Contains(string(body), "403: You are not allowed to edit this post."))
+	assert.False(t, strings.Contains(string(body), "value=\"Post #10\""))
+	body = testServerRequestStatus(t, "https://localhost:8080?update=3973812664", true, http.StatusForbidden)
+	assert.True(t, strings.Contains(string(body), "403: You are not allowed to edit this post."))
+}
+
 func TestReturnContentEditInvalid(t *testing.T) {
-	body := testServerRequest(t, "https://localhost:8080?edit=0", false)
-	assert.True(t, strings.Contains(string(body), "Recent posts"))
+	body := testServerRequestStatus(t, "https://localhost:8080?edit=0", false, http.StatusUnauthorized)
+	assert.True(t, strings.Contains(string(body), "401: Please login to view this page."))
 }
 
 func TestReturnContentUpdate(t *testing.T) {
-	body := testServerRequest(t, "https://localhost:8080?update=0", true)
+	body := testServerRequestStatus(t, "https://localhost:8080?update=0", true, http.StatusNotFound)
 	assert.True(t, strings.Contains(string(body), "404: Post not found."))
 }
 
 func TestReturnContentUpdateInvalid(t *testing.T) {
-	body := testServerRequest(t, "https://localhost:8080?update=0", false)
-	assert.True(t, strings.Contains(string(body), "404: Post not found."))
+	body := testServerRequestStatus(t, "https://localhost:8080?update=0", false, http.StatusUnauthorized)
+	assert.True(t, strings.Contains(string(body), "401: Please login to edit this post."))
 }
 
 func TestReturnContentPassword(t *testing.T) {
@@ -147,11 +493,60 @@ func TestReturnContentVerifyInvalid(t *testing.T) {
 	assert.True(t, strings.Contains(string(body), "false"))
 }
 
+func TestReturnContentMarkSpam(t *testing.T) {
+	body := testServerRequest(t, "https://localhost:8080?markSpam", true)
+	assert.True(t, strings.Contains(string(body), "false"))
+}
+
+func TestReturnContentSpam(t *testing.T) {
+	body := testServerRequest(t, "https://localhost:8080?spam", true)
+	assert.True(t, strings.Contains(string(body), "Spam queue"))
+}
+
+func TestReturnContentSpamInvalid(t *testing.T) {
+	body := testServerRequestStatus(t, "https://localhost:8080?spam", false, http.StatusUnauthorized)
+	assert.True(t, strings.Contains(string(body), "401: Please login to view this page."))
+}
+
+func TestReturnContentUnknownPath(t *testing.T) {
+	body := testServerRequestStatus(t, "https://localhost:8080/unknown", false, http.StatusNotFound)
+	assert.True(t, strings.Contains(string(body), "404: Page not found."))
+	assert.True(t, strings.Contains(string(body), "href=\"/static/css/style.css?v="))
+}
+
+func TestLogoutUserRedirectStatus(t *testing.T) {
+	srv, client := getHTTPSServerClient(false)
+	defer srv.Close()
+	srv.Handler = http.HandlerFunc(logoutUser)
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	res, err := client.Get("https://localhost:8080/logout")
+	assert.NoError(t, err)
+	res.Body.Close()
+	assert.EqualValues(t, res.StatusCode, http.StatusFound)
+	assert.EqualValues(t, res.Header.Get("Location"), "https://localhost:8080")
+}
+
 func TestReturnContentVerifyDefault(t *testing.T) {
 	body := testServerRequest(t, "https://localhost:8080?Test", false)
 	assert.True(t, strings.Contains(string(body), "Recent posts"))
 }
 
+func TestNewServer(t *testing.T) {
+	server := newServer()
+	assert.EqualValues(t, server.Addr, ":"+config.DEFAULT_PORT)
+	assert.EqualValues(t, server.ReadHeaderTimeout, time.Duration(config.READ_HEADER_TIMEOUT)*time.Second)
+	assert.EqualValues(t, server.ReadTimeout, time.Duration(config.READ_TIMEOUT)*time.Second)
+	assert.EqualValues(t, server.WriteTimeout, time.Duration(config.WRITE_TIMEOUT)*time.Second)
+	assert.EqualValues(t, server.IdleTimeout, time.Duration(config.IDLE_TIMEOUT)*time.Second)
+	assert.EqualValues(t, server.MaxHeaderBytes, config.MAX_HEADER_BYTES)
+	recorder := httptest.NewRecorder()
+	server.Handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/healthz", nil))
+	assert.EqualValues(t, recorder.Code, http.StatusOK)
+	assert.NotEmpty(t, recorder.Header().Get("X-Request-Id"))
+}
+
 func TestLoginUserValid(t *testing.T) {
 	srv, cli := getHTTPSServerClient(false)
 	defer srv.Close()
@@ -201,11 +596,15 @@ func TestLogoutUser(t *testing.T) {
 }
 
 func testServerRequest(t *testing.T, url string, login bool) []byte {
+	return testServerRequestStatus(t, url, login, http.StatusOK)
+}
+
+func testServerRequestStatus(t *testing.T, url string, login bool, status int) []byte {
 	srv, client := getHTTPSServerClient(login)
 	defer srv.Close()
 	res, err := client.Get(url)
 	assert.NoError(t, err)
-	assert.EqualValues(t, res.StatusCode, http.StatusOK)
+	assert.EqualValues(t, res.StatusCode, status)
 	body, err := ioutil.ReadAll(res.Body)
 	assert.NoError(t, err)
 	assert.True(t, len(body) > 0)
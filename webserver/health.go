@@ -0,0 +1,139 @@
+package webserver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+	"github.com/kherud/goblog/backend"
+	"github.com/kherud/goblog/config"
+)
+
+var startTime = time.Now()
+
+// result of the last readiness checks, which is reused for config.READINESS_CACHE_TIME seconds
+var (
+	readinessMutex   sync.Mutex
+	readinessChecked time.Time
+	lastReadiness    Readiness
+)
+
+/**
+Result of a single readiness check, the message describes the problem or some details.
+ */
+type Check struct {
+	Name    string `json:"name"`
+	Ok      bool   `json:"ok"`
+	Message string `json:"message,omitempty"`
+}
+
+/**
+Result of all readiness checks. The status is "ok" if every check succeeded, otherwise "failing".
+ */
+type Readiness struct {
+	Status string  `json:"status"`
+	Checks []Check `json:"checks,omitempty"`
+}
+
+/**
+Checks whether the blog is able to serve requests:
+- the templates of the active theme can be parsed, thus it works without a running server as well (see command check)
+- the data files can be read and written (see backend.CheckStorage)
+- the certificate can be loaded and doesn't expire within config.CERT_EXPIRY_WARNING days
+ */
+func CheckReadiness() Readiness {
+	checks := []Check{checkTemplates(), checkStorage(), checkCertificate()}
+	readiness := Readiness{Status: "ok", Checks: checks}
+	for _, check := range checks {
+		if !check.Ok {
+			readiness.Status = "failing"
+		}
+	}
+	return readiness
+}
+
+/**
+Answers as long as the process is alive (path: /healthz), the uptime is returned along with the status.
+ */
+func serveHealth(w http.ResponseWriter, r *http.Request) {
+	writeJson(w, http.StatusOK, map[string]string{"status": "ok", "uptime": time.Since(startTime).Round(time.Second).String()})
+}
+
+/**
+Returns the status of the readiness checks (path: /readyz, see CheckReadiness). If a check fails a 503 is returned.
+The results of the single checks may contain paths, thus they are only returned to requests passing config.METRICS_TOKEN.
+Probes are frequent, thus the checks run at most once within config.READINESS_CACHE_TIME seconds.
+ */
+func serveReadiness(w http.ResponseWriter, r *http.Request) {
+	readiness := cachedReadiness()
+	status := http.StatusOK
+	if readiness.Status != "ok" {
+		status = http.StatusServiceUnavailable
+	}
+	if !tokenPassed(r) {
+		readiness = Readiness{Status: readiness.Status}
+	}
+	writeJson(w, status, readiness)
+}
+
+/**
+Returns the result of the last readiness checks or runs them again if it is outdated. Failing checks are logged,
+thus their details are available without the metrics token as well.
+ */
+func cachedReadiness() Readiness {
+	readinessMutex.Lock()
+	defer readinessMutex.Unlock()
+	if time.Since(readinessChecked) < time.Duration(config.READINESS_CACHE_TIME)*time.Second {
+		return lastReadiness
+	}
+	lastReadiness, readinessChecked = CheckReadiness(), time.Now()
+	for _, check := range lastReadiness.Checks {
+		if !check.Ok {
+			slog.Warn("Readiness check failed", "check", check.Name, "message", check.Message)
+		}
+	}
+	return lastReadiness
+}
+
+func writeJson(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+func checkTemplates() Check {
+	theme := activeTheme()
+	if _, err := parseTemplates(theme); err != nil {
+		return Check{Name: "templates", Message: err.Error()}
+	}
+	return Check{Name: "templates", Ok: true, Message: "theme " + theme.Slug}
+}
+
+func checkStorage() Check {
+	if err := backend.CheckStorage(); err != nil {
+		return Check{Name: "storage", Message: err.Error()}
+	}
+	return Check{Name: "storage", Ok: true}
+}
+
+func checkCertificate() Check {
+	pair, err := tls.LoadX509KeyPair(config.CERT_FILE, config.KEY_FILE)
+	if err != nil {
+		return Check{Name: "certificate", Message: err.Error()}
+	}
+	certificate, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return Check{Name: "certificate", Message: err.Error()}
+	}
+	remaining := time.Until(certificate.NotAfter)
+	message := fmt.Sprintf("expires %v", certificate.NotAfter.Format(time.RFC3339))
+	if remaining < time.Duration(config.CERT_EXPIRY_WARNING)*24*time.Hour {
+		return Check{Name: "certificate", Message: message}
+	}
+	return Check{Name: "certificate", Ok: true, Message: message}
+}
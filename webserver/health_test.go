@@ -0,0 +1,109 @@
+package webserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+	"github.com/kherud/goblog/config"
+)
+
+func TestServeHealth(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	serveHealth(recorder, httptest.NewRequest("GET", "/healthz", nil))
+	assert.EqualValues(t, recorder.Code, http.StatusOK)
+	assert.EqualValues(t, recorder.Header().Get("Content-Type"), "application/json")
+	assert.True(t, strings.HasPrefix(recorder.Body.String(), "{\"status\":\"ok\",\"uptime\":"))
+}
+
+func readinessRequest(token string) *http.Request {
+	r := httptest.NewRequest("GET", "/readyz", nil)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	return r
+}
+
+func TestServeReadiness(t *testing.T) {
+	config.CERT_FILE, config.KEY_FILE = filepath.Join("..", "server.crt"), filepath.Join("..", "server.key")
+	config.METRICS_TOKEN = "secret"
+	defer func() {
+		config.CERT_FILE, config.KEY_FILE = "server.crt", "server.key"
+		config.METRICS_TOKEN = ""
+		readinessChecked = time.Time{}
+	}()
+	LoadTemplates()
+	readinessChecked = time.Time{}
+	recorder := httptest.NewRecorder()
+	serveReadiness(recorder, readinessRequest(""))
+	assert.EqualValues(t, recorder.Code, http.StatusOK)
+	assert.EqualValues(t, recorder.Body.String(), "{\"status\":\"ok\"}\n")
+	recorder = httptest.NewRecorder()
+	serveReadiness(recorder, readinessRequest("secret"))
+	var readiness Readiness
+	assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&readiness))
+	assert.EqualValues(t, readiness.Status, "ok")
+	assert.EqualValues(t, readiness.Checks, []Check{
+		{Name: "templates", Ok: true, Message: "theme default"},
+		{Name: "storage", Ok: true},
+		readiness.Checks[2],
+	})
+	assert.True(t, readiness.Checks[2].Ok)
+	assert.True(t, strings.HasPrefix(readiness.Checks[2].Message, "expires "))
+}
+
+// the command check runs the checks without starting the server, thus no templates are cached
+func TestCheckReadinessWithoutServer(t *testing.T) {
+	config.CERT_FILE, config.KEY_FILE = filepath.Join("..", "server.crt"), filepath.Join("..", "server.key")
+	templateMutex.Lock()
+	cache := templateCache
+	templateCache = nil
+	templateMutex.Unlock()
+	defer func() {
+		config.CERT_FILE, config.KEY_FILE = "server.crt", "server.key"
+		templateMutex.Lock()
+		templateCache = cache
+		templateMutex.Unlock()
+	}()
+	readiness := CheckReadiness()
+	assert.EqualValues(t, readiness.Status, "ok")
+	assert.EqualValues(t, readiness.Checks[0], Check{Name: "templates", Ok: true, Message: "theme default"})
+}
+
+func TestServeReadinessFailing(t *testing.T) {
+	config.CERT_FILE, config.KEY_FILE = filepath.Join("..", "server.crt"), filepath.Join("..", "server.key")
+	config.CERT_EXPIRY_WARNING = 100 * 365
+	config.METRICS_TOKEN = "secret"
+	defer func() {
+		config.CERT_FILE, config.KEY_FILE = "server.crt", "server.key"
+		config.CERT_EXPIRY_WARNING = 14
+		config.METRICS_TOKEN = ""
+		readinessChecked = time.Time{}
+	}()
+	readiness := CheckReadiness()
+	assert.EqualValues(t, readiness.Status, "failing")
+	assert.False(t, readiness.Checks[2].Ok)
+	config.CERT_FILE = "missing.crt"
+	readinessChecked = time.Time{}
+	recorder := httptest.NewRecorder()
+	serveReadiness(recorder, readinessRequest(""))
+	assert.EqualValues(t, recorder.Code, http.StatusServiceUnavailable)
+	assert.EqualValues(t, recorder.Body.String(), "{\"status\":\"failing\"}\n")
+	recorder = httptest.NewRecorder()
+	serveReadiness(recorder, readinessRequest("secret"))
+	assert.True(t, strings.Contains(recorder.Body.String(), "missing.crt"))
+	// the result is reused until it is outdated
+	config.CERT_FILE = filepath.Join("..", "server.crt")
+	config.CERT_EXPIRY_WARNING = 14
+	recorder = httptest.NewRecorder()
+	serveReadiness(recorder, readinessRequest(""))
+	assert.EqualValues(t, recorder.Code, http.StatusServiceUnavailable)
+	readinessChecked = time.Time{}
+	recorder = httptest.NewRecorder()
+	serveReadiness(recorder, readinessRequest(""))
+	assert.EqualValues(t, recorder.Code, http.StatusOK)
+}
@@ -0,0 +1,61 @@
+package webserver
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"github.com/kherud/goblog/backend"
+	"github.com/kherud/goblog/config"
+)
+
+// paths routed by their full name
+var pathRoutes = map[string]bool{
+	"/feed.xml": true, "/atom.xml": true, "/feed.json": true, "/sitemap.xml": true, "/robots.txt": true,
+	"/login": true, "/logout": true, "/metrics": true, "/healthz": true, "/readyz": true,
+}
+
+/**
+Serves all metrics in the text based exposition format of Prometheus (see backend.WriteMetrics).
+If config.METRICS_TOKEN is set the request has to pass it as bearer token (header Authorization), otherwise a 401 is returned.
+ */
+func serveMetrics(w http.ResponseWriter, r *http.Request) {
+	if config.METRICS_TOKEN != "" && !tokenPassed(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	backend.WriteMetrics(w)
+}
+
+/**
+Checks whether a request passes config.METRICS_TOKEN as bearer token (header Authorization). Always false if no token is set.
+ */
+func tokenPassed(r *http.Request) bool {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return config.METRICS_TOKEN != "" && subtle.ConstantTimeCompare([]byte(token), []byte(config.METRICS_TOKEN)) == 1
+}
+
+/**
+Returns the name of the route a request belongs to, e.g. /?id for posts or /static for static files.
+Unknown paths are grouped, thus arbitrary requests can't create an unlimited number of metrics.
+ */
+func routeName(r *http.Request) string {
+	path := r.URL.Path
+	switch {
+	case strings.HasPrefix(path, "/static/"):
+		return "/static"
+	case strings.HasPrefix(path, "/media/"):
+		return "/media"
+	case strings.HasPrefix(path, "/archive"):
+		return "/archive"
+	case pathRoutes[path]:
+		return path
+	case path == "/" || path == "":
+		if page, found := firstParameter(r); found && pageRoutes[page] != nil {
+			return "/?" + page
+		}
+		return "/"
+	}
+	return "other"
+}
@@ -0,0 +1,61 @@
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/kherud/goblog/config"
+)
+
+func TestRouteName(t *testing.T) {
+	tests := []struct {
+		Url   string
+		Route string
+	}{{Url: "/?id=2046379135", Route: "/?id"},
+		{Url: "/?more=5&tag=asd", Route: "/?more"},
+		{Url: "/?arbitrary", Route: "/"},
+		{Url: "/", Route: "/"},
+		{Url: "/static/css/style.css?v=1", Route: "/static"},
+		{Url: "/archive/2018/01", Route: "/archive"},
+		{Url: "/feed.xml", Route: "/feed.xml"},
+		{Url: "/unknown", Route: "other"},
+	}
+	for _, test := range tests {
+		assert.EqualValues(t, routeName(httptest.NewRequest("GET", test.Url, nil)), test.Route, test.Url)
+	}
+}
+
+func TestServeMetrics(t *testing.T) {
+	handler := accessLog(http.HandlerFunc(returnContent))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/?id=2046379135", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/unknown", nil))
+	recorder := httptest.NewRecorder()
+	serveMetrics(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	assert.EqualValues(t, recorder.Code, http.StatusOK)
+	assert.True(t, strings.HasPrefix(recorder.Header().Get("Content-Type"), "text/plain; version=0.0.4"))
+	metrics := recorder.Body.String()
+	assert.True(t, strings.Contains(metrics, "goblog_http_requests_total{route=\"/?id\",method=\"GET\",status=\"200\"}"))
+	assert.True(t, strings.Contains(metrics, "goblog_http_requests_total{route=\"other\",method=\"GET\",status=\"404\"}"))
+	assert.True(t, strings.Contains(metrics, "goblog_storage_duration_seconds_count{operation=\"read\",file=\"entries.json\"}"))
+}
+
+func TestServeMetricsToken(t *testing.T) {
+	config.METRICS_TOKEN = "secret"
+	defer func() { config.METRICS_TOKEN = "" }()
+	recorder := httptest.NewRecorder()
+	serveMetrics(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	assert.EqualValues(t, recorder.Code, http.StatusUnauthorized)
+	assert.False(t, strings.Contains(recorder.Body.String(), "goblog_"))
+	r := httptest.NewRequest("GET", "/metrics", nil)
+	r.Header.Set("Authorization", "Bearer wrong")
+	recorder = httptest.NewRecorder()
+	serveMetrics(recorder, r)
+	assert.EqualValues(t, recorder.Code, http.StatusUnauthorized)
+	r.Header.Set("Authorization", "Bearer secret")
+	recorder = httptest.NewRecorder()
+	serveMetrics(recorder, r)
+	assert.EqualValues(t, recorder.Code, http.StatusOK)
+	assert.True(t, strings.Contains(recorder.Body.String(), "# TYPE goblog_stored_sessions gauge"))
+}
@@ -17,6 +17,10 @@ $(document).ready(function () {
         event.preventDefault();
         changePassword();
     });
+    $('#notification-form').on('submit', function (event) {
+        event.preventDefault();
+        changeNotifications();
+    });
     $('.user-creation-input').on('keyup', function (event) {
         $("#user-creation-error").hide();
     });
@@ -62,6 +66,11 @@ function addTag() {
         document.getElementById("keyword-input").style.border = "1px solid red";
         return;
     }
+    $("#keyword-suggestions option").each(function () { // prefer the spelling of an existing keyword
+        if (this.value.toLowerCase() === input.value.trim().toLowerCase()) {
+            input.value = this.value;
+        }
+    });
     var elementCount = document.getElementById("tag-container").childElementCount;
     var container = document.createElement("div");
     container.classList.add("input-group");
@@ -89,6 +98,39 @@ function addTag() {
     document.getElementById("tag-container").appendChild(container);
 }
 
+var suggestionTimer;
+
+function requestSuggestions(input, listId, includeTitles) {
+    clearTimeout(suggestionTimer);
+    suggestionTimer = setTimeout(function () {
+        var list = $("#" + listId);
+        if (input.value.trim().length === 0) {
+            list.empty();
+            return;
+        }
+        $.ajax({
+            url: "/?suggest=" + encodeURIComponent(input.value),
+            type: "GET",
+            dataType: "json",
+            success: function (result) {
+                list.empty();
+                result.keywords.forEach(function (suggestion) {
+                    var value = suggestion.keyword;
+                    if (includeTitles) {
+                        value = "tag:" + (value.indexOf(" ") >= 0 ? '"' + value + '"' : value);
+                    }
+                    list.append($("<option>").val(value).text(suggestion.keyword + " (" + suggestion.count + ")"));
+                });
+                if (includeTitles) {
+                    result.titles.forEach(function (title) {
+                        list.append($("<option>").val(title));
+                    });
+                }
+            }
+        });
+    }, 200);
+}
+
 function resetTagInput() {
     document.getElementById("keyword-input").style.border = "1px solid rgba(0,0,0,.15)";
 }
@@ -107,9 +149,21 @@ function getCookie(name) {
     if (match) return match[1];
 }
 
+function replyToComment(commentId, author) {
+    document.getElementById("comment-parent-input").value = commentId;
+    document.getElementById("comment-reply-author").textContent = author;
+    $("#comment-reply-note").show();
+    document.getElementById("comment-input-area").focus();
+}
+
+function cancelReply() {
+    document.getElementById("comment-parent-input").value = "";
+    $("#comment-reply-note").hide();
+}
+
 function verifyComment(postId, commentId) {
     $.ajax({
-        url: "?verify",
+        url: "/?verify",
         type: "POST",
         data: {"postId": postId, "commentId": commentId},
         success: function (result) {
@@ -123,9 +177,25 @@ function verifyComment(postId, commentId) {
     });
 }
 
+function markCommentSpam(postId, commentId) {
+    $.ajax({
+        url: "/?markSpam",
+        type: "POST",
+        data: {"postId": postId, "commentId": commentId},
+        success: function (result) {
+            if (result === "true") {
+                $("#comment-" + commentId).closest(".comment-thread").remove();
+            }
+        },
+        error: function (err) {
+            alert(err);
+        }
+    });
+}
+
 function deletePost(postId) {
     $.ajax({
-        url: "?delete",
+        url: "/?delete",
         type: "POST",
         data: {"postId": postId},
         success: function (result) {
@@ -144,7 +214,7 @@ function deletePost(postId) {
 
 function createUser() {
     $.ajax({
-        url: "?newUser",
+        url: "/?newUser",
         type: "POST",
         data: $('#user-creation-form').serialize(),
         success: function (result) {
@@ -166,7 +236,7 @@ function createUser() {
 
 function changePassword() {
     $.ajax({
-        url: "?password",
+        url: "/?password",
         type: "POST",
         data: $('#change-password-form').serialize(),
         success: function (result) {
@@ -185,9 +255,172 @@ function changePassword() {
     });
 }
 
-function requestMorePosts(index){
+function changeNotifications() {
     $.ajax({
-        url: "?more=" + index,
+        url: "/?notifications",
+        type: "POST",
+        data: $('#notification-form').serialize(),
+        success: function (result) {
+            var err = $("#notification-error");
+            if (result.length === 0){
+                err.hide();
+                alert("Notification settings saved!");
+            } else {
+                err.text(result);
+                err.css('display','block');
+            }
+        },
+        error: function (err) {
+            alert(err);
+        }
+    });
+}
+
+function updateTag(form) {
+    $.ajax({
+        url: "/?updateTag",
+        type: "POST",
+        data: $(form).serialize(),
+        success: function (result) {
+            if (result.length === 0) {
+                location.reload();
+            } else {
+                alert(result);
+            }
+        },
+        error: function (err) {
+            alert(err);
+        }
+    });
+    return false;
+}
+
+function mergeTags() {
+    $.ajax({
+        url: "/?mergeTags",
+        type: "POST",
+        data: $('#tag-merge-form').serialize(),
+        success: function (result) {
+            if (result.length === 0) {
+                location.reload();
+            } else {
+                var err = $("#tag-merge-error");
+                err.text(result);
+                err.css('display','block');
+            }
+        },
+        error: function (err) {
+            alert(err);
+        }
+    });
+    return false;
+}
+
+function createCategory() {
+    $.ajax({
+        url: "/?newCategory",
+        type: "POST",
+        data: $('#category-creation-form').serialize(),
+        success: function (result) {
+            if (result.length === 0) {
+                location.reload();
+            } else {
+                var err = $("#category-creation-error");
+                err.text(result);
+                err.css('display','block');
+            }
+        },
+        error: function (err) {
+            alert(err);
+        }
+    });
+    return false;
+}
+
+function deleteCategory(slug) {
+    if (!confirm("Delete this category? Its subcategories and posts are moved to its parent.")) {
+        return;
+    }
+    $.ajax({
+        url: "/?deleteCategory",
+        type: "POST",
+        data: {category: slug},
+        success: function (result) {
+            if (result.length === 0) {
+                location.reload();
+            } else {
+                alert(result);
+            }
+        },
+        error: function (err) {
+            alert(err);
+        }
+    });
+}
+
+function uploadMedia(inputId, textareaId) {
+    var input = document.getElementById(inputId);
+    var err = $("#media-upload-error");
+    if (input.files.length === 0) {
+        return;
+    }
+    var data = new FormData();
+    data.append("file", input.files[0]);
+    $.ajax({
+        url: "/?upload",
+        type: "POST",
+        data: data,
+        processData: false,
+        contentType: false,
+        success: function (result) {
+            if (result.error) {
+                err.text(result.error);
+                err.css('display','block');
+            } else if (textareaId) {
+                // insert a reference to the uploaded file at the cursor position
+                var area = document.getElementById(textareaId);
+                var reference = (result.image ? "!" : "") + "[" + result.name + "](" + result.url + ")";
+                area.value = area.value.substring(0, area.selectionStart) + reference + area.value.substring(area.selectionEnd);
+                input.value = "";
+                err.css('display','none');
+            } else {
+                location.reload();
+            }
+        },
+        error: function (err) {
+            alert(err);
+        }
+    });
+}
+
+function deleteMedia(file) {
+    if (!confirm("Delete this file? Posts referencing it will show a broken link.")) {
+        return;
+    }
+    $.ajax({
+        url: "/?deleteMedia",
+        type: "POST",
+        data: {media: file},
+        success: function (result) {
+            if (result.length === 0) {
+                location.reload();
+            } else {
+                alert(result);
+            }
+        },
+        error: function (err) {
+            alert(err);
+        }
+    });
+}
+
+function requestMorePosts(index, filter){
+    var url = "/?more=" + index;
+    if (filter) {
+        url += "&" + filter;
+    }
+    $.ajax({
+        url: url,
         type: "GET",
         success: function (result) {
             $("#more-content-placeholder").replaceWith(result)
@@ -198,3 +431,21 @@ function requestMorePosts(index){
     });
 }
 
+
+function activateTheme(slug) {
+    $.ajax({
+        url: "/?activateTheme",
+        type: "POST",
+        data: {theme: slug},
+        success: function (result) {
+            if (result.length === 0) {
+                location.reload();
+            } else {
+                alert(result);
+            }
+        },
+        error: function (err) {
+            alert(err);
+        }
+    });
+}
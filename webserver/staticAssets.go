@@ -0,0 +1,157 @@
+package webserver
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"github.com/kherud/goblog/config"
+)
+
+// static files compiled into the binary (stylesheets, scripts, images and vendor libraries)
+//go:embed static
+var embeddedStatic embed.FS
+
+var (
+	assetMutex    sync.Mutex
+	assetVersions = map[string]string{}
+)
+
+/**
+A file system that prefers the files of an override directory and falls back to the embedded ones for all others.
+ */
+type overlayFS struct {
+	override fs.FS
+	base     fs.FS
+}
+
+func (overlay overlayFS) Open(name string) (fs.File, error) {
+	if overlay.override != nil {
+		if file, err := overlay.override.Open(name); err == nil {
+			return file, nil
+		}
+	}
+	return overlay.base.Open(name)
+}
+
+/**
+Lists the files of both directories, the overriding ones replace embedded files of the same name.
+ */
+func (overlay overlayFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, err := fs.ReadDir(overlay.base, name)
+	if overlay.override == nil {
+		return entries, err
+	}
+	overrides, overrideErr := fs.ReadDir(overlay.override, name)
+	if err != nil && overrideErr != nil {
+		return nil, err
+	}
+	merged := map[string]fs.DirEntry{}
+	for _, entry := range append(entries, overrides...) {
+		merged[entry.Name()] = entry
+	}
+	result := make([]fs.DirEntry, 0, len(merged))
+	for _, entry := range merged {
+		result = append(result, entry)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name() < result[j].Name() })
+	return result, nil
+}
+
+/**
+Returns the files of a directory (static or templates). In development mode they are read from the working directory
+(see config.STATIC_FILE_PATH and config.TEMPLATE_PATH), otherwise from the binary. Static files of the active theme
+replace the default ones and files within the same directory below config.OVERRIDE_PATH replace both,
+thus single files can be customized without a rebuild.
+ */
+func assetSource(directory string) fs.FS {
+	var source fs.FS
+	switch {
+	case config.DEV_MODE && directory == "static":
+		source = os.DirFS(config.STATIC_FILE_PATH)
+	case config.DEV_MODE:
+		source = os.DirFS(config.TEMPLATE_PATH)
+	case directory == "static":
+		source, _ = fs.Sub(embeddedStatic, directory)
+	default:
+		source, _ = fs.Sub(embeddedTemplates, directory)
+	}
+	if directory == "static" {
+		templateMutex.RLock()
+		theme := currentTheme
+		templateMutex.RUnlock()
+		if static := themeStatic(theme); static != nil {
+			source = overlayFS{override: static, base: source}
+		}
+	}
+	if config.OVERRIDE_PATH == "" {
+		return source
+	}
+	return overlayFS{override: os.DirFS(filepath.Join(config.OVERRIDE_PATH, directory)), base: source}
+}
+
+/**
+Returns the url of a static file (param: path below the static directory, e.g. css/style.css) including a hash of its content,
+e.g. /static/css/style.css?v=1a2b3c4d5e. Changing the file changes its url, thus it can be cached by browsers indefinitely.
+Files that don't exist are referenced without a version.
+ */
+func assetUrl(file string) string {
+	file = strings.TrimPrefix(path.Clean("/"+file), "/")
+	if version := assetVersion(file); version != "" {
+		return "/static/" + file + "?v=" + version
+	}
+	return "/static/" + file
+}
+
+/**
+Returns the hash of the content of a static file (shortened to 10 hex digits) or an empty string if it can't be read.
+The hashes are cached unless the development mode is enabled, since files may change in the meantime.
+ */
+func assetVersion(file string) string {
+	assetMutex.Lock()
+	defer assetMutex.Unlock()
+	if version, found := assetVersions[file]; found && !config.DEV_MODE {
+		return version
+	}
+	content, err := fs.ReadFile(assetSource("static"), file)
+	if err != nil {
+		return ""
+	}
+	hash := sha256.Sum256(content)
+	assetVersions[file] = hex.EncodeToString(hash[:])[:10]
+	return assetVersions[file]
+}
+
+/**
+Serves static files (path: /static/<file>). Versioned urls (see assetUrl) matching the current content are cached
+by browsers for a year, all others have to be revalidated. Directory listings aren't served.
+ */
+func serveStatic(w http.ResponseWriter, r *http.Request) {
+	file := strings.TrimPrefix(path.Clean(r.URL.Path), "/static/")
+	info, err := fs.Stat(assetSource("static"), file)
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+	version := assetVersion(file)
+	w.Header().Set("ETag", "\""+version+"\"")
+	if requested := r.URL.Query().Get("v"); requested != "" && requested == version {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	content, err := fs.ReadFile(assetSource("static"), file)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	http.ServeContent(w, r, file, info.ModTime(), bytes.NewReader(content))
+}
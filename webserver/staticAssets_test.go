@@ -0,0 +1,76 @@
+package webserver
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/kherud/goblog/config"
+)
+
+func TestAssetUrl(t *testing.T) {
+	url := assetUrl("css/style.css")
+	assert.True(t, strings.HasPrefix(url, "/static/css/style.css?v="))
+	assert.Len(t, strings.TrimPrefix(url, "/static/css/style.css?v="), 10)
+	assert.EqualValues(t, assetUrl("/css/../css/style.css"), url)
+	assert.EqualValues(t, assetUrl("css/unknown.css"), "/static/css/unknown.css")
+	body := testServerRequest(t, "https://localhost:8080", false)
+	assert.True(t, strings.Contains(string(body), "href=\""+url+"\""))
+}
+
+func TestServeStatic(t *testing.T) {
+	url := assetUrl("css/style.css")
+	recorder := httptest.NewRecorder()
+	serveStatic(recorder, httptest.NewRequest("GET", url, nil))
+	assert.EqualValues(t, recorder.Code, http.StatusOK)
+	assert.EqualValues(t, recorder.Header().Get("Cache-Control"), "public, max-age=31536000, immutable")
+	assert.True(t, strings.HasPrefix(recorder.Header().Get("Content-Type"), "text/css"))
+	expected, _ := ioutil.ReadFile(filepath.Join("static", "css", "style.css"))
+	assert.EqualValues(t, recorder.Body.String(), string(expected))
+	// outdated or missing versions have to be revalidated
+	recorder = httptest.NewRecorder()
+	serveStatic(recorder, httptest.NewRequest("GET", "/static/css/style.css?v=outdated", nil))
+	assert.EqualValues(t, recorder.Header().Get("Cache-Control"), "no-cache")
+	request := httptest.NewRequest("GET", "/static/css/style.css", nil)
+	request.Header.Set("If-None-Match", recorder.Header().Get("ETag"))
+	recorder = httptest.NewRecorder()
+	serveStatic(recorder, request)
+	assert.EqualValues(t, recorder.Code, http.StatusNotModified)
+	for _, path := range []string{"/static/css/unknown.css", "/static/css", "/static/../templates/index.html"} {
+		recorder = httptest.NewRecorder()
+		serveStatic(recorder, httptest.NewRequest("GET", path, nil))
+		assert.EqualValues(t, recorder.Code, http.StatusNotFound)
+	}
+}
+
+func TestOverridePath(t *testing.T) {
+	directory, _ := ioutil.TempDir("", "override")
+	defer os.RemoveAll(directory)
+	os.MkdirAll(filepath.Join(directory, "static", "css"), os.ModePerm)
+	os.MkdirAll(filepath.Join(directory, "templates"), os.ModePerm)
+	ioutil.WriteFile(filepath.Join(directory, "static", "css", "style.css"), []byte("body { color: red; }"), 0644)
+	ioutil.WriteFile(filepath.Join(directory, "templates", "post.html"), []byte(`{{ define "mainContent" }}Customized{{ end }}`), 0644)
+	original := assetUrl("css/style.css")
+	config.OVERRIDE_PATH = directory
+	assetVersions = map[string]string{}
+	defer func() {
+		config.OVERRIDE_PATH = ""
+		assetVersions = map[string]string{}
+		LoadTemplates()
+	}()
+	assert.NoError(t, LoadTemplates())
+	assert.NotEqual(t, assetUrl("css/style.css"), original)
+	recorder := httptest.NewRecorder()
+	serveStatic(recorder, httptest.NewRequest("GET", "/static/css/style.css", nil))
+	assert.EqualValues(t, recorder.Body.String(), "body { color: red; }")
+	// files that aren't overridden are still served from the binary
+	assert.True(t, strings.Contains(assetUrl("js/main.js"), "?v="))
+	body := testServerRequest(t, "https://localhost:8080?id=2046379135", false)
+	assert.True(t, strings.Contains(string(body), "Customized"))
+	body = testServerRequest(t, "https://localhost:8080", false)
+	assert.True(t, strings.Contains(string(body), "Recent posts"))
+}
@@ -0,0 +1,134 @@
+package webserver
+
+import (
+	"embed"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"github.com/kherud/goblog/config"
+)
+
+// templates compiled into the binary, thus the server doesn't depend on its working directory
+//go:embed templates
+var embeddedTemplates embed.FS
+
+var (
+	templateMutex sync.RWMutex
+	templateCache map[string]*template.Template
+	currentTheme  Theme // theme the cached templates belong to
+)
+
+/**
+Parses all templates once and replaces the cached set. Every page template is combined with the static content (index.html)
+and the blocks of the active theme (see applyTheme).
+The templates are read from the binary unless they are overridden or the development mode is enabled (see assetSource).
+If a template can't be parsed an error is returned and the previously cached set is kept.
+ */
+func LoadTemplates() error {
+	theme := activeTheme()
+	cache, err := parseTemplates(theme)
+	if err != nil {
+		return err
+	}
+	templateMutex.Lock()
+	templateCache = cache
+	currentTheme = theme
+	templateMutex.Unlock()
+	assetMutex.Lock()
+	assetVersions = map[string]string{} // static files of the theme may differ
+	assetMutex.Unlock()
+	return nil
+}
+
+/**
+Parses the template sets of all pages using the blocks of a theme.
+ */
+func parseTemplates(theme Theme) (map[string]*template.Template, error) {
+	source := assetSource("templates")
+	names, err := fs.Glob(source, "*.html")
+	if err != nil {
+		return nil, err
+	}
+	cache := map[string]*template.Template{}
+	for _, name := range names {
+		if name == "index.html" {
+			continue
+		}
+		tmpl, err := template.New("index.html").Funcs(templateFunctions).ParseFS(source, "index.html", name)
+		if err != nil {
+			return nil, err
+		}
+		if err := applyTheme(tmpl, theme, name, names); err != nil {
+			return nil, fmt.Errorf("theme %v: %v", theme.Slug, err)
+		}
+		cache[name] = tmpl
+	}
+	return cache, nil
+}
+
+/**
+Returns the cached template set of a page (param: name of the template determining the main content, e.g. post.html).
+The templates are loaded on first use if LoadTemplates wasn't called beforehand.
+ */
+func getTemplate(name string) (*template.Template, error) {
+	templateMutex.RLock()
+	cache := templateCache
+	templateMutex.RUnlock()
+	if cache == nil {
+		if err := LoadTemplates(); err != nil {
+			return nil, err
+		}
+		return getTemplate(name)
+	}
+	tmpl, found := cache[name]
+	if !found {
+		return nil, fmt.Errorf("template %v not found", name)
+	}
+	return tmpl, nil
+}
+
+/**
+Re-parses the templates whenever a file within config.TEMPLATE_PATH changes (development mode only).
+The directory is polled every config.TEMPLATE_POLL_INTERVAL milliseconds; parse errors are logged and the last working set is kept.
+ */
+func watchTemplates() {
+	lastChange := templatesModified()
+	for range time.Tick(time.Duration(config.TEMPLATE_POLL_INTERVAL) * time.Millisecond) {
+		if modified := templatesModified(); !modified.Equal(lastChange) {
+			lastChange = modified
+			if err := LoadTemplates(); err != nil {
+				slog.Error("Templates could not be reloaded", "error", err)
+			} else {
+				slog.Info("Templates reloaded")
+			}
+		}
+	}
+}
+
+/**
+Returns the latest modification of a template within config.TEMPLATE_PATH. Renamed or deleted files change the result as well.
+ */
+func templatesModified() (latest time.Time) {
+	files, err := os.ReadDir(config.TEMPLATE_PATH)
+	if err != nil {
+		return
+	}
+	count := 0
+	for _, file := range files {
+		info, err := file.Info()
+		if err != nil || !strings.HasSuffix(file.Name(), ".html") {
+			continue
+		}
+		count++
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	// encode the number of templates as well, thus deleting an old file is noticed
+	return latest.Add(time.Duration(count))
+}
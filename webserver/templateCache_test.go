@@ -0,0 +1,70 @@
+package webserver
+
+import (
+	"errors"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+	"github.com/kherud/goblog/config"
+)
+
+func TestLoadTemplates(t *testing.T) {
+	assert.NoError(t, LoadTemplates())
+	tmpl, err := getTemplate("post.html")
+	assert.NoError(t, err)
+	assert.NotNil(t, tmpl.Lookup("mainContent"))
+	assert.NotNil(t, tmpl.Lookup("index.html"))
+	_, err = getTemplate("index.html")
+	assert.Error(t, err)
+	_, err = getTemplate("unknown.html")
+	assert.Error(t, err)
+}
+
+func TestLoadTemplatesDevMode(t *testing.T) {
+	directory, _ := ioutil.TempDir("", "templates")
+	defer os.RemoveAll(directory)
+	index, _ := ioutil.ReadFile(filepath.Join("templates", "index.html"))
+	ioutil.WriteFile(filepath.Join(directory, "index.html"), index, 0644)
+	ioutil.WriteFile(filepath.Join(directory, "post.html"), []byte(`{{ define "mainContent" }}First{{ end }}`), 0644)
+	config.DEV_MODE, config.TEMPLATE_PATH = true, directory
+	defer func() {
+		config.DEV_MODE, config.TEMPLATE_PATH = false, config.TEST_TEMPLATE_PATH
+		LoadTemplates()
+	}()
+	assert.NoError(t, LoadTemplates())
+	before := templatesModified()
+	assert.False(t, before.IsZero())
+	body := testServerRequest(t, "https://localhost:8080?id=2046379135", false)
+	assert.True(t, strings.Contains(string(body), "First"))
+	// a broken template keeps the last working set
+	ioutil.WriteFile(filepath.Join(directory, "post.html"), []byte(`{{ define "mainContent" }}{{ end`), 0644)
+	assert.Error(t, LoadTemplates())
+	body = testServerRequest(t, "https://localhost:8080?id=2046379135", false)
+	assert.True(t, strings.Contains(string(body), "First"))
+	ioutil.WriteFile(filepath.Join(directory, "post.html"), []byte(`{{ define "mainContent" }}Second{{ end }}`), 0644)
+	os.Chtimes(filepath.Join(directory, "post.html"), time.Now(), before.Add(time.Minute))
+	assert.False(t, templatesModified().Equal(before))
+	assert.NoError(t, LoadTemplates())
+	body = testServerRequest(t, "https://localhost:8080?id=2046379135", false)
+	assert.True(t, strings.Contains(string(body), "Second"))
+}
+
+func TestExecuteTemplateError(t *testing.T) {
+	tmpl := template.Must(template.New("index.html").Parse(`Partial {{ template "missing" }}`))
+	recorder := httptest.NewRecorder()
+	assert.Error(t, executeTemplate(recorder, tmpl, "index.html", map[string]interface{}{}, http.StatusOK))
+	assert.Empty(t, recorder.Body.String())
+	status, err := internalError(recorder, httptest.NewRequest("GET", "/", nil), errors.New("broken template"))
+	assert.Error(t, err)
+	assert.EqualValues(t, status, http.StatusInternalServerError)
+	assert.EqualValues(t, recorder.Code, http.StatusInternalServerError)
+	assert.True(t, strings.Contains(recorder.Body.String(), "500: Something went wrong."))
+	assert.False(t, strings.Contains(recorder.Body.String(), "Partial"))
+}
@@ -0,0 +1,108 @@
+package webserver
+
+import (
+	"errors"
+	"html/template"
+	"regexp"
+	"strings"
+	"github.com/kherud/goblog/backend"
+	"github.com/kherud/goblog/backend/models"
+	"github.com/kherud/goblog/util"
+)
+
+/**
+Functions that are available within all templates.
+ */
+var templateFunctions = template.FuncMap{
+	"dict":        dict,
+	"slug":        util.Slugify,
+	"indent":      indent,
+	"contains":    contains,
+	"jsonLD":      jsonLD,
+	"postText":    postText,
+	"mediaUrl":    mediaUrl,
+	"asset":       assetUrl,
+	"srcset":      backend.ImageSrcset,
+	"summary":     backend.PostSummary,
+	"readingTime": backend.ReadingTime,
+}
+
+// markdown references to uploaded media within escaped post texts, e.g. ![alt](/media/<file>) or [name](/media/<file>)
+var mediaReferencePattern = regexp.MustCompile(`(!?)\[([^\]\n]*)\]\((/media/[0-9a-f]{64}\.[a-z0-9]+)\)`)
+
+/**
+Assembles a map of alternating keys and values.
+Used to pass multiple values to a nested template, e.g. {{ template "comment" dict "thread" . "root" $ }}
+ */
+func dict(values ...interface{}) (map[string]interface{}, error) {
+	if len(values)%2 != 0 {
+		return nil, errors.New("dict requires an even amount of arguments")
+	}
+	result := make(map[string]interface{}, len(values)/2)
+	for idx := 0; idx < len(values); idx += 2 {
+		key, ok := values[idx].(string)
+		if !ok {
+			return nil, errors.New("dict keys must be strings")
+		}
+		result[key] = values[idx+1]
+	}
+	return result, nil
+}
+
+/**
+Returns non-breaking spaces to indent an entry of a tree by its depth, e.g. categories within a select element.
+ */
+func indent(depth int) string {
+	if depth < 0 {
+		return ""
+	}
+	return strings.Repeat("\u00a0", 4*depth)
+}
+
+/**
+Checks whether a slice of strings contains a value, e.g. to preselect the categories of a post.
+ */
+func contains(values []string, value string) bool {
+	for _, existing := range values {
+		if existing == value {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+Marks JSON-LD (e.g. backend.PageMeta) as safe to be embedded into a script element.
+Only pass JSON encoded by encoding/json, since it escapes <, > and &.
+ */
+func jsonLD(raw string) template.JS {
+	return template.JS(raw)
+}
+
+/**
+Escapes the text of a post and renders markdown references to uploaded media: images (![alt](/media/<file>)) are embedded,
+processed images including a srcset of their variants, other files ([name](/media/<file>)) are linked. Any other markup remains escaped.
+The more marker separating the excerpt (see backend.MoreMarker) is dropped.
+ */
+func postText(text string) template.HTML {
+	escaped := template.HTMLEscapeString(strings.Replace(text, backend.MoreMarker, "", -1))
+	return template.HTML(mediaReferencePattern.ReplaceAllStringFunc(escaped, func(reference string) string {
+		match := mediaReferencePattern.FindStringSubmatch(reference)
+		if match[1] == "!" && (strings.HasSuffix(match[3], ".jpg") || strings.HasSuffix(match[3], ".png")) {
+			// processed images are available in different sizes, browsers choose the appropriate one
+			return `<img class="post-media" src="` + match[3] + `?size=large" srcset="` + backend.ImageSrcset(match[3]) +
+				`" sizes="(max-width: 768px) 100vw, 750px" alt="` + match[2] + `">`
+		}
+		if match[1] == "!" {
+			return `<img class="post-media" src="` + match[3] + `" alt="` + match[2] + `">`
+		}
+		return `<a href="` + match[3] + `">` + match[2] + `</a>`
+	}))
+}
+
+/**
+Returns the url of an uploaded file by its file name, e.g. the cover image of a post.
+ */
+func mediaUrl(file string) string {
+	return backend.MediaUrl(models.Media{File: file})
+}
@@ -0,0 +1,53 @@
+package webserver
+
+import (
+	"strings"
+	"testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/kherud/goblog/backend"
+)
+
+func TestDict(t *testing.T) {
+	result, err := dict("a", 1, "b", "c")
+	assert.NoError(t, err)
+	assert.EqualValues(t, result["a"], 1)
+	assert.EqualValues(t, result["b"], "c")
+	_, err = dict("a")
+	assert.NotNil(t, err)
+	_, err = dict(1, "a")
+	assert.NotNil(t, err)
+}
+
+func TestIndent(t *testing.T) {
+	assert.Equal(t, "", indent(0))
+	assert.Equal(t, "", indent(-1))
+	assert.Equal(t, strings.Repeat("\u00a0", 8), indent(2))
+}
+
+func TestContains(t *testing.T) {
+	assert.True(t, contains([]string{"a", "b"}, "b"))
+	assert.False(t, contains([]string{"a", "b"}, "c"))
+	assert.False(t, contains(nil, "a"))
+}
+
+func TestJsonLD(t *testing.T) {
+	assert.EqualValues(t, jsonLD(`{"a":"b"}`), `{"a":"b"}`)
+}
+
+func TestPostText(t *testing.T) {
+	file := strings.Repeat("a", 64)
+	assert.EqualValues(t, postText("<b>Hi</b>"), "&lt;b&gt;Hi&lt;/b&gt;")
+	assert.EqualValues(t, postText("Intro"+backend.MoreMarker+"rest"), "Introrest")
+	assert.EqualValues(t, postText("See ![A \"cat\"](/media/"+file+".gif)!"),
+		"See <img class=\"post-media\" src=\"/media/"+file+".gif\" alt=\"A &#34;cat&#34;\">!")
+	assert.EqualValues(t, postText("![Photo](/media/"+file+".jpg)"),
+		"<img class=\"post-media\" src=\"/media/"+file+".jpg?size=large\" srcset=\""+backend.ImageSrcset("/media/"+file+".jpg")+
+			"\" sizes=\"(max-width: 768px) 100vw, 750px\" alt=\"Photo\">")
+	assert.EqualValues(t, postText("[Notes](/media/"+file+".pdf)"), "<a href=\"/media/"+file+".pdf\">Notes</a>")
+	assert.EqualValues(t, postText("![x](https://example.com/a.png)"), "![x](https://example.com/a.png)")
+}
+
+func TestMediaUrl(t *testing.T) {
+	file := strings.Repeat("a", 64) + ".png"
+	assert.EqualValues(t, mediaUrl(file), "/media/"+file)
+}
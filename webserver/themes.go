@@ -0,0 +1,210 @@
+package webserver
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"os"
+	"regexp"
+	"github.com/kherud/goblog/config"
+	"github.com/kherud/goblog/backend"
+)
+
+// themes shipped with the binary, every directory contains a theme.json manifest, templates/ and static/
+//go:embed themes
+var embeddedThemes embed.FS
+
+// directory names of themes, which identify them
+var themeNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+/**
+A theme as described by its manifest (theme.json). It provides the template blocks it declares (e.g. masthead or footer)
+and static files, everything else falls back to the default theme. Its directory name serves as identifier.
+ */
+type Theme struct {
+	Slug        string   `json:"-"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Author      string   `json:"author"`
+	Version     string   `json:"version"`
+	Blocks      []string `json:"blocks"`
+	Embedded    bool     `json:"-"`
+	files       fs.FS
+}
+
+/**
+Returns all available themes: the default theme, the themes shipped with the binary and the ones installed within
+config.THEMES_PATH (one directory per theme). Installed themes replace shipped ones of the same name.
+Directories without a valid manifest are skipped.
+ */
+func ListThemes() []Theme {
+	themes := []Theme{defaultTheme()}
+	shipped, _ := fs.Sub(embeddedThemes, "themes")
+	themes = append(themes, readThemes(shipped, true)...)
+	for _, theme := range readThemes(os.DirFS(config.THEMES_PATH), false) {
+		if idx := findTheme(themes, theme.Slug); idx > 0 {
+			themes[idx] = theme
+		} else if idx < 0 {
+			themes = append(themes, theme)
+		}
+	}
+	return themes
+}
+
+/**
+Returns the selected theme (see backend.ActiveTheme). If it isn't available anymore the default theme is used.
+ */
+func activeTheme() Theme {
+	themes := ListThemes()
+	if idx := findTheme(themes, backend.ActiveTheme()); idx >= 0 {
+		return themes[idx]
+	}
+	return themes[0]
+}
+
+/**
+Returns the names of all available themes.
+ */
+func themeNames() (names []string) {
+	for _, theme := range ListThemes() {
+		names = append(names, theme.Slug)
+	}
+	return
+}
+
+/**
+Replaces the blocks of a parsed page (param: name of the page template, e.g. post.html) by the ones the theme declares.
+Templates of the theme named like a page (param: names of all page templates) only apply to that page, all others to every page.
+Declared blocks the theme doesn't define keep their default. Helper templates that don't exist within the default theme are added as well.
+ */
+func applyTheme(tmpl *template.Template, theme Theme, page string, pages []string) error {
+	if theme.files == nil {
+		return nil
+	}
+	source, err := fs.Sub(theme.files, "templates")
+	if err != nil {
+		return err
+	}
+	files, err := fs.Glob(source, "*.html")
+	if err != nil {
+		return err
+	}
+	// the theme is parsed for every page, since html/template escapes the parse trees in place on execution
+	themeTemplates := template.New("theme").Funcs(templateFunctions)
+	for _, file := range files {
+		if file != page && contains(pages, file) {
+			continue // belongs to another page
+		}
+		if _, err := themeTemplates.ParseFS(source, file); err != nil {
+			return err
+		}
+	}
+	declared := map[string]bool{}
+	for _, block := range theme.Blocks {
+		declared[block] = true
+	}
+	for _, defined := range themeTemplates.Templates() {
+		name := defined.Name()
+		if defined.Tree == nil || name == "theme" || name == page || (!declared[name] && tmpl.Lookup(name) != nil) {
+			continue
+		}
+		if _, err := tmpl.AddParseTree(name, defined.Tree); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/**
+Selects the active theme (see backend.UpdateTheme) and reloads the templates accordingly.
+Themes whose templates can't be parsed are rejected beforehand, thus a broken theme never becomes active.
+Returns a string that is determined to be displayed in the frontend. If it is empty everything went well.
+ */
+func activateTheme(r *http.Request) string {
+	themes := ListThemes()
+	idx := findTheme(themes, r.FormValue("theme"))
+	if user, loggedIn := backend.CheckAuthentication(r); loggedIn && user.Admin && idx >= 0 {
+		if _, err := parseTemplates(themes[idx]); err != nil {
+			slog.WarnContext(r.Context(), "Theme rejected", "theme", themes[idx].Slug, "error", err)
+			return "The theme could not be loaded.\n"
+		}
+	}
+	if err := backend.UpdateTheme(r, themeNames()); err != "" {
+		return err
+	}
+	if err := LoadTemplates(); err != nil {
+		slog.ErrorContext(r.Context(), "Templates could not be reloaded", "error", err)
+		return "The theme could not be loaded.\n"
+	}
+	return ""
+}
+
+func defaultTheme() Theme {
+	return Theme{Slug: config.DEFAULT_THEME, Name: "Clean Blog", Author: "Start Bootstrap",
+		Description: "The default theme of the blog.", Embedded: true}
+}
+
+/**
+Reads the manifests of all themes within a directory (one subdirectory per theme).
+ */
+func readThemes(directory fs.FS, embedded bool) (themes []Theme) {
+	entries, err := fs.ReadDir(directory, ".")
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || !themeNamePattern.MatchString(entry.Name()) || entry.Name() == config.DEFAULT_THEME {
+			continue
+		}
+		files, _ := fs.Sub(directory, entry.Name())
+		theme, err := readManifest(files)
+		if err != nil {
+			continue
+		}
+		theme.Slug, theme.Embedded, theme.files = entry.Name(), embedded, files
+		themes = append(themes, theme)
+	}
+	return
+}
+
+func readManifest(files fs.FS) (Theme, error) {
+	raw, err := fs.ReadFile(files, "theme.json")
+	if err != nil {
+		return Theme{}, err
+	}
+	var theme Theme
+	if err := json.Unmarshal(raw, &theme); err != nil {
+		return Theme{}, err
+	}
+	if theme.Name == "" {
+		return Theme{}, errors.New("the manifest of a theme requires a name")
+	}
+	return theme, nil
+}
+
+/**
+Returns the static files of a theme or nil if it has none.
+ */
+func themeStatic(theme Theme) fs.FS {
+	if theme.files == nil {
+		return nil
+	}
+	if _, err := fs.Stat(theme.files, "static"); err != nil {
+		return nil
+	}
+	static, _ := fs.Sub(theme.files, "static")
+	return static
+}
+
+func findTheme(themes []Theme, slug string) int {
+	for idx, theme := range themes {
+		if theme.Slug == slug {
+			return idx
+		}
+	}
+	return -1
+}
@@ -0,0 +1,138 @@
+package webserver
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/kherud/goblog/config"
+	"github.com/kherud/goblog/backend"
+)
+
+func installTheme(name, manifest string, templates map[string]string) {
+	directory := filepath.Join(config.THEMES_PATH, name)
+	os.MkdirAll(filepath.Join(directory, "templates"), os.ModePerm)
+	ioutil.WriteFile(filepath.Join(directory, "theme.json"), []byte(manifest), 0644)
+	for file, content := range templates {
+		ioutil.WriteFile(filepath.Join(directory, "templates", file), []byte(content), 0644)
+	}
+}
+
+func themeRequest(theme string, loggedIn bool) string {
+	srv, client := getHTTPSServerClient(loggedIn)
+	defer srv.Close()
+	res, err := client.PostForm("https://localhost:8080?activateTheme", url.Values{"theme": {theme}})
+	if err != nil {
+		return err.Error()
+	}
+	defer res.Body.Close()
+	body, _ := ioutil.ReadAll(res.Body)
+	return string(body)
+}
+
+// revokes the admin rights of the test user, the returned function restores them
+func revokeAdmin() func() {
+	raw, _ := ioutil.ReadFile(config.USERS_FILE_PATH)
+	ioutil.WriteFile(config.USERS_FILE_PATH, []byte(strings.Replace(string(raw), "\"admin\":true", "\"admin\":false", 1)), 0644)
+	return func() { ioutil.WriteFile(config.USERS_FILE_PATH, raw, 0644) }
+}
+
+func resetThemes() {
+	os.RemoveAll(config.THEMES_PATH)
+	os.Remove(config.SETTINGS_FILE_PATH)
+	LoadTemplates()
+}
+
+func TestListThemes(t *testing.T) {
+	defer resetThemes()
+	installTheme("minimal", `{"name": "Minimal", "blocks": ["footer"]}`, nil)
+	installTheme("invalid", `{"description": "No name"}`, nil)
+	installTheme("Upper", `{"name": "Upper"}`, nil)
+	themes := ListThemes()
+	assert.Len(t, themes, 3)
+	assert.EqualValues(t, themes[0].Slug, config.DEFAULT_THEME)
+	assert.EqualValues(t, themes[1].Slug, "dark")
+	assert.True(t, themes[1].Embedded)
+	assert.EqualValues(t, themes[2].Name, "Minimal")
+	assert.False(t, themes[2].Embedded)
+	assert.EqualValues(t, activeTheme().Slug, config.DEFAULT_THEME)
+	body := testServerRequest(t, "https://localhost:8080?themes", true)
+	assert.True(t, strings.Contains(string(body), "activateTheme('minimal')"))
+	assert.False(t, strings.Contains(string(body), "activateTheme('default')"))
+}
+
+func TestActivateTheme(t *testing.T) {
+	defer resetThemes()
+	assert.EqualValues(t, themeRequest("dark", false), "Something went wrong.\n")
+	assert.EqualValues(t, themeRequest("unknown", true), "The theme could not be found.\n")
+	assert.Empty(t, themeRequest("dark", true))
+	assert.EqualValues(t, backend.ActiveTheme(), "dark")
+	body := string(testServerRequest(t, "https://localhost:8080?id=2046379135", false))
+	assert.True(t, strings.Contains(body, "/static/css/dark.css?v="))
+	assert.True(t, strings.Contains(body, "masthead-dark"))
+	assert.True(t, strings.Contains(body, "<span class=\"subheading\">Hi friend</span>"))
+	// blocks the theme doesn't declare are taken from the default theme
+	assert.True(t, strings.Contains(body, "<p class=\"copyright text-muted\">"))
+	recorder := httptest.NewRecorder()
+	serveStatic(recorder, httptest.NewRequest("GET", "/static/css/dark.css", nil))
+	assert.EqualValues(t, recorder.Code, 200)
+	assert.True(t, strings.Contains(recorder.Body.String(), "masthead-dark"))
+	assert.Empty(t, themeRequest(config.DEFAULT_THEME, true))
+	body = string(testServerRequest(t, "https://localhost:8080?id=2046379135", false))
+	assert.False(t, strings.Contains(body, "masthead-dark"))
+}
+
+func TestActivateThemeNoAdmin(t *testing.T) {
+	defer resetThemes()
+	restore := revokeAdmin()
+	defer restore()
+	assert.EqualValues(t, themeRequest("dark", true), "Only admins can change the theme.\n")
+	assert.EqualValues(t, backend.ActiveTheme(), config.DEFAULT_THEME)
+	body := testServerRequestStatus(t, "https://localhost:8080?themes", true, http.StatusForbidden)
+	assert.True(t, strings.Contains(string(body), "403: Only admins can change the theme."))
+	body = testServerRequestStatus(t, "https://localhost:8080?tags", true, http.StatusForbidden)
+	assert.True(t, strings.Contains(string(body), "403: Only admins can change tags."))
+	body = testServerRequestStatus(t, "https://localhost:8080?categories", true, http.StatusForbidden)
+	assert.True(t, strings.Contains(string(body), "403: Only admins can change categories."))
+	body = testServerRequest(t, "https://localhost:8080", true)
+	assert.False(t, strings.Contains(string(body), "href=\"/?themes\""))
+	assert.False(t, strings.Contains(string(body), "href=\"/?tags\""))
+	assert.False(t, strings.Contains(string(body), "href=\"/?categories\""))
+	restore()
+	body = testServerRequest(t, "https://localhost:8080", true)
+	assert.True(t, strings.Contains(string(body), "href=\"/?themes\""))
+}
+
+func TestThemeBlocks(t *testing.T) {
+	defer resetThemes()
+	installTheme("custom", `{"name": "Custom", "blocks": ["mainContent", "footer", "masthead"]}`, map[string]string{
+		"post.html":   `{{ define "mainContent" }}<article>{{ .post.Title }}</article>{{ end }}`,
+		"layout.html": `{{ define "footer" }}<footer>{{ template "signature" }}</footer>{{ end }}{{ define "signature" }}Custom footer{{ end }}`,
+	})
+	assert.Empty(t, themeRequest("custom", true))
+	body := string(testServerRequest(t, "https://localhost:8080?id=2046379135", false))
+	assert.True(t, strings.Contains(body, "<article>Hi friend</article>"))
+	assert.True(t, strings.Contains(body, "<footer>Custom footer</footer>"))
+	// the masthead is declared but not defined, thus the default one is used
+	assert.True(t, strings.Contains(body, "class=\"masthead\""))
+	// templates named like a page only apply to that page
+	body = string(testServerRequest(t, "https://localhost:8080", false))
+	assert.True(t, strings.Contains(body, "Recent posts"))
+	assert.True(t, strings.Contains(body, "<footer>Custom footer</footer>"))
+}
+
+func TestActivateBrokenTheme(t *testing.T) {
+	defer resetThemes()
+	installTheme("broken", `{"name": "Broken", "blocks": ["footer"]}`, map[string]string{
+		"layout.html": `{{ define "footer" }}{{ end`,
+	})
+	assert.EqualValues(t, themeRequest("broken", true), "The theme could not be loaded.\n")
+	assert.EqualValues(t, backend.ActiveTheme(), config.DEFAULT_THEME)
+	body := string(testServerRequest(t, "https://localhost:8080", false))
+	assert.True(t, strings.Contains(body, "Recent posts"))
+}